@@ -0,0 +1,134 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// maxAuditEvents is the most audit events returned at once.
+const maxAuditEvents = 500
+
+// adminEmailsFromEnv reads the email addresses of the admins from
+// ADMIN_EMAILS, separated by commas.
+func adminEmailsFromEnv() map[string]bool {
+	admins := map[string]bool{}
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			admins[email] = true
+		}
+	}
+	return admins
+}
+
+// currentAdmin returns the signed-in user if they are an admin. Their
+// address must be verified, or anyone could sign up with it before they do.
+// It writes the error response itself.
+func (cfg *apiConfig) currentAdmin(c *gin.Context) (database.User, bool) {
+	user, ok := cfg.currentUser(c)
+	if !ok {
+		return user, false
+	}
+	if !cfg.adminEmails[user.Email] || !user.EmailVerifiedAt.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can do this"})
+		return user, false
+	}
+	return user, true
+}
+
+// handlerAdminUnlock lets an email address, an IP address or both log in
+// again, forgetting their failed logins.
+func (cfg *apiConfig) handlerAdminUnlock(c *gin.Context) {
+	type parameters struct {
+		Email string `json:"email"`
+		IP    string `json:"ip"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
+		return
+	}
+	admin, ok := cfg.currentAdmin(c)
+	if !ok {
+		return
+	}
+	if params.Email == "" && params.IP == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email or IP address is required"})
+		return
+	}
+	ctx := c.Request.Context()
+	unlocked := false
+	if params.Email != "" {
+		deleted, err := cfg.db.DeleteLoginFailures(ctx, accountLockoutKey(params.Email))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't unlock account"})
+			return
+		}
+		if deleted > 0 {
+			var userID sql.NullString
+			if user, err := cfg.db.GetUserByEmail(ctx, params.Email); err == nil {
+				userID = nullString(user.ID)
+			}
+			if err := cfg.audit(ctx, auditAccountUnlocked, userID, params.Email, "", "Unlocked by "+admin.Email); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't record unlock"})
+				return
+			}
+			unlocked = true
+		}
+	}
+	if params.IP != "" {
+		deleted, err := cfg.db.DeleteLoginFailures(ctx, ipLockoutKey(params.IP))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't unlock IP address"})
+			return
+		}
+		if deleted > 0 {
+			if err := cfg.audit(ctx, auditIPUnlocked, sql.NullString{}, "", params.IP, "Unlocked by "+admin.Email); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't record unlock"})
+				return
+			}
+			unlocked = true
+		}
+	}
+	if !unlocked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No failed logins found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Unlocked successfully"})
+}
+
+// handlerAdminAuditEvents lists the latest events in the audit log, newest
+// first.
+func (cfg *apiConfig) handlerAdminAuditEvents(c *gin.Context) {
+	if _, ok := cfg.currentAdmin(c); !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > maxAuditEvents {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be from 1 to 500"})
+		return
+	}
+	rows, err := cfg.db.GetAuditEvents(c.Request.Context(), int64(limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve audit events"})
+		return
+	}
+	events := make([]gin.H, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, gin.H{
+			"id":         row.ID,
+			"created_at": row.CreatedAt,
+			"event":      row.Event,
+			"user_id":    row.UserID.String,
+			"email":      row.Email,
+			"ip":         row.Ip,
+			"detail":     row.Detail,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
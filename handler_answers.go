@@ -0,0 +1,83 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/Corogura/quizmaker/internal/quizio"
+	"github.com/gin-gonic/gin"
+)
+
+// handlerQuestionsAnswer grades a single answer, so that a quiz's owner can
+// check how a question is graded. It reveals the answer, so takers answer
+// through their attempt instead.
+func (cfg *apiConfig) handlerQuestionsAnswer(c *gin.Context) {
+	questionNumber, err := strconv.Atoi(c.Param("question_number"))
+	if err != nil || questionNumber <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question number"})
+		return
+	}
+	quiz, ok := cfg.ownedQuiz(c, auth.ScopeReadQuizzes)
+	if !ok {
+		return
+	}
+	row, err := cfg.db.GetQuestionFromQuestionNumber(c.Request.Context(), database.GetQuestionFromQuestionNumberParams{
+		QuestionNumber: int64(questionNumber),
+		QuizID:         quiz.ID,
+	})
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve question"})
+		return
+	}
+	if row.DeletedAt.Valid {
+		c.JSON(http.StatusGone, gin.H{"error": "Question has been deleted"})
+		return
+	}
+	type parameters struct {
+		Answer     int    `json:"answer"`
+		AnswerText string `json:"answer_text"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parameters"})
+		return
+	}
+	question := questionFromDB(row)
+	if question.HasChoices() && question.Choice(params.Answer) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Answer does not refer to a choice"})
+		return
+	}
+	c.JSON(http.StatusOK, gradeAnswer(question, params.Answer, params.AnswerText))
+}
+
+// gradedAnswer is the result of grading one answer.
+type gradedAnswer struct {
+	Correct bool `json:"correct"`
+	// CorrectAnswer is the 1-based correct choice of questions with choices.
+	CorrectAnswer int `json:"correct_answer,omitempty"`
+	// CorrectAnswerText is the correct choice, the first accepted answer or
+	// the numeric answer.
+	CorrectAnswerText string `json:"correct_answer_text"`
+	Explanation       string `json:"explanation,omitempty"`
+	// Feedback is the feedback for the chosen choice, if it has any.
+	Feedback string `json:"feedback,omitempty"`
+}
+
+func gradeAnswer(q quizio.Question, choice int, text string) gradedAnswer {
+	graded := gradedAnswer{
+		Correct:           q.Check(choice, text),
+		CorrectAnswerText: q.CorrectAnswerText(),
+		Explanation:       q.Explanation,
+	}
+	if q.HasChoices() {
+		graded.CorrectAnswer = q.Answer
+		graded.Feedback = q.Feedback(choice)
+	}
+	return graded
+}
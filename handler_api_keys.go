@@ -0,0 +1,245 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxAPIKeys is the most API keys a user can have at once.
+	maxAPIKeys = 20
+	// maxAPIKeyDays is the longest an API key that expires can last.
+	maxAPIKeyDays = 365
+	// apiKeyUseInterval is how often a key's last use is recorded.
+	apiKeyUseInterval = time.Minute
+)
+
+type apiKeyResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"created_at"`
+	ExpiresAt  string   `json:"expires_at"`
+	LastUsedAt string   `json:"last_used_at"`
+	Expired    bool     `json:"expired"`
+}
+
+func apiKeyFromDB(key database.ApiKey, now time.Time) apiKeyResponse {
+	return apiKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Scopes:     strings.Fields(key.Scopes),
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt.String,
+		LastUsedAt: key.LastUsedAt.String,
+		Expired:    apiKeyExpired(key, now),
+	}
+}
+
+// handlerGetAPIKeys lists the caller's API keys. The keys themselves are
+// only shown when they are created.
+func (cfg *apiConfig) handlerGetAPIKeys(c *gin.Context) {
+	user, ok := cfg.currentUser(c)
+	if !ok {
+		return
+	}
+	rows, err := cfg.db.GetUserAPIKeys(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve API keys"})
+		return
+	}
+	now := time.Now().UTC()
+	keys := make([]apiKeyResponse, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, apiKeyFromDB(row, now))
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// handlerCreateAPIKey creates an API key for scripts to use in place of an
+// access token. It can only do what its scopes allow, and never expires if
+// expires_in_days is 0. The response is the only time the key is shown.
+func (cfg *apiConfig) handlerCreateAPIKey(c *gin.Context) {
+	type parameters struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
+		return
+	}
+	user, ok := cfg.currentUser(c)
+	if !ok {
+		return
+	}
+	if user.GuestExpiresAt.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Guest accounts can't create API keys"})
+		return
+	}
+	name := strings.TrimSpace(params.Name)
+	if name == "" || len(name) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name must be 1 to 100 characters"})
+		return
+	}
+	for _, requested := range params.Scopes {
+		if !auth.ValidScope(requested) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope " + requested})
+			return
+		}
+	}
+	// Scopes are stored once each, in the usual order.
+	var scopes []string
+	for _, scope := range auth.Scopes {
+		if hasScope(params.Scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "API key needs at least one scope"})
+		return
+	}
+	if params.ExpiresInDays < 0 || params.ExpiresInDays > maxAPIKeyDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "API keys can last at most 365 days"})
+		return
+	}
+	count, err := cfg.db.CountUserAPIKeys(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't count API keys"})
+		return
+	}
+	if count >= maxAPIKeys {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You have too many API keys, delete one first"})
+		return
+	}
+
+	now := time.Now().UTC()
+	key, id := auth.GenerateAPIKey()
+	row := database.ApiKey{
+		ID:        id,
+		UserID:    user.ID,
+		Name:      name,
+		HashedKey: auth.HashAPIKey(key),
+		Scopes:    strings.Join(scopes, " "),
+		CreatedAt: now.Format(time.RFC3339),
+	}
+	if params.ExpiresInDays > 0 {
+		row.ExpiresAt = sql.NullString{String: now.AddDate(0, 0, params.ExpiresInDays).Format(time.RFC3339), Valid: true}
+	}
+	err = cfg.db.CreateAPIKey(c.Request.Context(), database.CreateAPIKeyParams{
+		ID:        row.ID,
+		UserID:    row.UserID,
+		Name:      row.Name,
+		HashedKey: row.HashedKey,
+		Scopes:    row.Scopes,
+		CreatedAt: row.CreatedAt,
+		ExpiresAt: row.ExpiresAt,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create API key"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"api_key": apiKeyFromDB(row, now), "key": key})
+}
+
+// handlerDeleteAPIKey deletes one of the caller's API keys, which stops
+// working straight away.
+func (cfg *apiConfig) handlerDeleteAPIKey(c *gin.Context) {
+	user, ok := cfg.currentUser(c)
+	if !ok {
+		return
+	}
+	deleted, err := cfg.db.DeleteAPIKey(c.Request.Context(), database.DeleteAPIKeyParams{
+		ID:     c.Param("id"),
+		UserID: user.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't delete API key"})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key deleted successfully"})
+}
+
+// authorize returns the user a request is made as, from either an access
+// token or an API key. Access tokens can do anything, but an API key needs
+// the scope. It writes the error response itself.
+func (cfg *apiConfig) authorize(c *gin.Context, scope string) (uuid.UUID, bool) {
+	bearer, err := auth.GetBearerToken(c.Request.Header)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header"})
+		return uuid.Nil, false
+	}
+	if !auth.IsAPIKey(bearer) {
+		userID, err := auth.ValidateJWT(bearer, cfg.jwtKeys, cfg.revocations)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return uuid.Nil, false
+		}
+		return userID, true
+	}
+
+	id, err := auth.ParseAPIKey(bearer)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		return uuid.Nil, false
+	}
+	key, err := cfg.db.GetAPIKey(c.Request.Context(), id)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't check API key"})
+		return uuid.Nil, false
+	}
+	if err != nil || !auth.CheckAPIKeyHash(key.HashedKey, bearer) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		return uuid.Nil, false
+	}
+	now := time.Now().UTC()
+	if apiKeyExpired(key, now) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key has expired"})
+		return uuid.Nil, false
+	}
+	if !hasScope(strings.Fields(key.Scopes), scope) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key doesn't have the " + scope + " scope"})
+		return uuid.Nil, false
+	}
+	userID, err := uuid.Parse(key.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't check API key"})
+		return uuid.Nil, false
+	}
+	// Failing to record the use shouldn't fail the request.
+	cfg.db.UseAPIKey(c.Request.Context(), database.UseAPIKeyParams{
+		LastUsedAt:   sql.NullString{String: now.Format(time.RFC3339), Valid: true},
+		ID:           key.ID,
+		LastUsedAt_2: sql.NullString{String: now.Add(-apiKeyUseInterval).Format(time.RFC3339), Valid: true},
+	})
+	return userID, true
+}
+
+func apiKeyExpired(key database.ApiKey, now time.Time) bool {
+	if !key.ExpiresAt.Valid {
+		return false
+	}
+	expiresAt, err := time.Parse(time.RFC3339, key.ExpiresAt.String)
+	return err != nil || !now.Before(expiresAt)
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// resumedAnswer is an answer already given in an attempt, graded as it was
+// when it was submitted.
+type resumedAnswer struct {
+	Position int `json:"position"`
+	// Answer is the 1-based choice as displayed in the attempt.
+	Answer     int    `json:"answer,omitempty"`
+	AnswerText string `json:"answer_text,omitempty"`
+	gradedAnswer
+}
+
+// handlerAttemptsGet returns an attempt as far as it has got, so it can be
+// continued after the page is closed, including from another device.
+func (cfg *apiConfig) handlerAttemptsGet(c *gin.Context) {
+	attempt, ok := cfg.getAttempt(c)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	if err := cfg.finishExpired(c.Request.Context(), &attempt, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't finish attempt"})
+		return
+	}
+	state, err := cfg.attemptState(c.Request.Context(), attempt, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't read attempt"})
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+// openAttempt returns the unfinished attempt at a quiz of a signed-in or
+// nickname taker, if they have one. Attempts whose time has run out are
+// finished rather than returned.
+func (cfg *apiConfig) openAttempt(ctx context.Context, quizID string, taker attemptTaker, now time.Time) (database.Attempt, bool, error) {
+	if !taker.UserID.Valid && !taker.TakerID.Valid {
+		return database.Attempt{}, false, nil
+	}
+	attempt, err := cfg.db.GetOpenAttempt(ctx, database.GetOpenAttemptParams{
+		QuizID:  quizID,
+		UserID:  taker.UserID,
+		TakerID: taker.TakerID,
+	})
+	if err == sql.ErrNoRows {
+		return attempt, false, nil
+	} else if err != nil {
+		return attempt, false, err
+	}
+	if err := cfg.finishExpired(ctx, &attempt, now); err != nil {
+		return attempt, false, err
+	}
+	return attempt, !attempt.FinishedAt.Valid, nil
+}
+
+// finishExpired finishes an attempt, at its deadline, once that has passed.
+func (cfg *apiConfig) finishExpired(ctx context.Context, attempt *database.Attempt, now time.Time) error {
+	if attempt.FinishedAt.Valid || !attempt.Deadline.Valid || !pastDeadline(attempt.Deadline.String, now) {
+		return nil
+	}
+	deadline, err := time.Parse(time.RFC3339, attempt.Deadline.String)
+	if err != nil {
+		return err
+	}
+	_, _, err = cfg.finishAttempt(ctx, attempt, deadline)
+	return err
+}
+
+// attemptState describes an attempt with its questions, the answers given so
+// far and, for timed attempts that are still running, the time left.
+func (cfg *apiConfig) attemptState(ctx context.Context, attempt database.Attempt, now time.Time) (gin.H, error) {
+	rows, err := cfg.db.GetAllQuestionsInQuiz(ctx, attempt.QuizID)
+	if err != nil {
+		return nil, err
+	}
+	questions, err := attemptQuestions(attempt, rows)
+	if err != nil {
+		return nil, err
+	}
+	var order []string
+	if err := json.Unmarshal([]byte(attempt.QuestionOrder), &order); err != nil {
+		return nil, err
+	}
+	timers, err := cfg.db.GetAttemptQuestionTimers(ctx, attempt.ID)
+	if err != nil {
+		return nil, err
+	}
+	answers, err := cfg.db.GetAttemptAnswers(ctx, attempt.ID)
+	if err != nil {
+		return nil, err
+	}
+	byQuestion := make(map[string]database.AttemptAnswer, len(answers))
+	for _, a := range answers {
+		byQuestion[a.QuestionID] = a
+	}
+	// Questions are graded before the timers hide the text of unopened ones;
+	// answered questions have always been opened.
+	given := []resumedAnswer{}
+	for _, q := range questions {
+		a, ok := byQuestion[q.id]
+		if !ok {
+			continue
+		}
+		displayed := q.displayed()
+		choice := 0
+		for i, stored := range q.order {
+			if int64(stored) == a.Answer {
+				choice = i + 1
+			}
+		}
+		graded := gradeAnswer(displayed, choice, a.AnswerText)
+		// The answer counts as it was graded, even if the question has been
+		// edited since.
+		graded.Correct = a.Correct
+		given = append(given, resumedAnswer{
+			Position:     q.Position,
+			Answer:       choice,
+			AnswerText:   a.AnswerText,
+			gradedAnswer: graded,
+		})
+	}
+	state := gin.H{
+		"attempt_id": attempt.ID,
+		"started_at": attempt.CreatedAt,
+		"questions":  withTimers(questions, timers),
+		"answers":    given,
+		"total":      len(order),
+	}
+	if attempt.FinishedAt.Valid {
+		state["finished_at"] = attempt.FinishedAt.String
+		state["score"] = attempt.Score.Int64
+	} else if attempt.Deadline.Valid {
+		state["deadline"] = attempt.Deadline.String
+		state["remaining_seconds"] = remainingSeconds(attempt.Deadline.String, now)
+	}
+	return state, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// deadlineGrace is allowed past every deadline for the time a request spends
+// in transit, so an answer sent in the last second still counts.
+const deadlineGrace = 2 * time.Second
+
+// expiredAttemptsInterval is how often attempts whose time has run out are
+// looked for, to finish those the taker never came back to.
+const expiredAttemptsInterval = time.Minute
+
+// handlerAttemptsOpenQuestion starts the clock of a question with a time
+// limit and returns the question in full. Opening a question again returns
+// it with the deadline it was first given.
+func (cfg *apiConfig) handlerAttemptsOpenQuestion(c *gin.Context) {
+	attempt, ok := cfg.getAttempt(c)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	if !cfg.checkDeadline(c, &attempt, now) {
+		return
+	}
+	if attempt.FinishedAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Attempt is already finished"})
+		return
+	}
+	position, err := strconv.Atoi(c.Param("position"))
+	if err != nil || position <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid position"})
+		return
+	}
+	rows, err := cfg.db.GetAllQuestionsInQuiz(c.Request.Context(), attempt.QuizID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve questions"})
+		return
+	}
+	questions, err := attemptQuestions(attempt, rows)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't read attempt"})
+		return
+	}
+	var question *attemptQuestion
+	for i := range questions {
+		if questions[i].Position == position {
+			question = &questions[i]
+		}
+	}
+	if question == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+	if question.TimeLimitSeconds > 0 {
+		// A question can't outlast the attempt it is part of.
+		deadline := now.Add(time.Duration(question.TimeLimitSeconds) * time.Second)
+		if attempt.Deadline.Valid {
+			if attemptDeadline, err := time.Parse(time.RFC3339, attempt.Deadline.String); err == nil && attemptDeadline.Before(deadline) {
+				deadline = attemptDeadline
+			}
+		}
+		err := cfg.db.CreateAttemptQuestionTimer(c.Request.Context(), database.CreateAttemptQuestionTimerParams{
+			AttemptID:  attempt.ID,
+			QuestionID: question.id,
+			OpenedAt:   now.Format(time.RFC3339),
+			Deadline:   deadline.Format(time.RFC3339),
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't open question"})
+			return
+		}
+	}
+	timers, err := cfg.db.GetAttemptQuestionTimers(c.Request.Context(), attempt.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve question timers"})
+		return
+	}
+	opened := withTimers([]attemptQuestion{*question}, timers)[0]
+	response := gin.H{"question": opened}
+	if opened.Deadline != "" {
+		response["remaining_seconds"] = remainingSeconds(opened.Deadline, now)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// checkDeadline finishes an attempt whose deadline has passed, as if it had
+// been submitted at the deadline, and tells the taker their time is up. It
+// writes the error response itself and returns false if it did.
+func (cfg *apiConfig) checkDeadline(c *gin.Context, attempt *database.Attempt, now time.Time) bool {
+	if attempt.FinishedAt.Valid || !attempt.Deadline.Valid || !pastDeadline(attempt.Deadline.String, now) {
+		return true
+	}
+	deadline, err := time.Parse(time.RFC3339, attempt.Deadline.String)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't read attempt"})
+		return false
+	}
+	score, total, err := cfg.finishAttempt(c.Request.Context(), attempt, deadline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't finish attempt"})
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "Time is up", "score": score, "total": total})
+	return false
+}
+
+// finishExpiredAttempts finishes, every expiredAttemptsInterval until ctx is
+// done, the attempts whose deadline has passed. Takers who come back have
+// theirs finished straight away, but without this the attempts of those who
+// closed the page would never reach results, leaderboards or the feed.
+func (cfg *apiConfig) finishExpiredAttempts(ctx context.Context) {
+	ticker := time.NewTicker(expiredAttemptsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := cfg.finishAllExpired(ctx, time.Now().UTC()); err != nil && ctx.Err() == nil {
+			log.Printf("Couldn't finish expired attempts: %v", err)
+		}
+	}
+}
+
+// finishAllExpired finishes, at their deadline, the attempts whose deadline
+// had passed by now.
+func (cfg *apiConfig) finishAllExpired(ctx context.Context, now time.Time) error {
+	cutoff := sql.NullString{String: now.Add(-deadlineGrace).Format(time.RFC3339), Valid: true}
+	attempts, err := cfg.db.GetExpiredAttempts(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	for i := range attempts {
+		if err := cfg.finishExpired(ctx, &attempts[i], now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withTimers applies the question timers of an attempt to its questions.
+// Opened questions get their deadline; timed questions that haven't been
+// opened are locked and lose their text and choices, so no time can be spent
+// reading them before their clock starts.
+func withTimers(questions []attemptQuestion, timers []database.AttemptQuestionTimer) []attemptQuestion {
+	deadlines := make(map[string]string, len(timers))
+	for _, timer := range timers {
+		deadlines[timer.QuestionID] = timer.Deadline
+	}
+	for i := range questions {
+		q := &questions[i]
+		if deadline, ok := deadlines[q.id]; ok {
+			q.Deadline = deadline
+		} else if q.TimeLimitSeconds > 0 {
+			q.Locked = true
+			q.QuestionText = ""
+			q.Choices = nil
+		}
+	}
+	return questions
+}
+
+// pastDeadline reports whether now is later than the RFC 3339 deadline,
+// allowing deadlineGrace. Deadlines that can't be parsed never pass.
+func pastDeadline(deadline string, now time.Time) bool {
+	t, err := time.Parse(time.RFC3339, deadline)
+	return err == nil && now.After(t.Add(deadlineGrace))
+}
+
+// remainingSeconds returns the whole seconds left until the RFC 3339
+// deadline, rounded up and never negative.
+func remainingSeconds(deadline string, now time.Time) int64 {
+	t, err := time.Parse(time.RFC3339, deadline)
+	if err != nil || !t.After(now) {
+		return 0
+	}
+	return int64(math.Ceil(t.Sub(now).Seconds()))
+}
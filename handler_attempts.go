@@ -0,0 +1,536 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/Corogura/quizmaker/internal/quizio"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// attemptSection records a section used by an attempt and the number of
+// questions it drew.
+type attemptSection struct {
+	quizio.Section
+	Questions int `json:"questions"`
+}
+
+// attemptQuestion is a question as it is shown in one attempt.
+type attemptQuestion struct {
+	Position       int                 `json:"position"`
+	Section        string              `json:"section,omitempty"`
+	QuestionNumber int64               `json:"question_number"`
+	QuestionType   quizio.QuestionType `json:"question_type"`
+	QuestionText   string              `json:"question_text"`
+	Choices        []string            `json:"choices,omitempty"`
+	// Questions with a time limit are locked, showing neither text nor
+	// choices, until they are opened; Deadline is set once they are.
+	TimeLimitSeconds int    `json:"time_limit_seconds,omitempty"`
+	Locked           bool   `json:"locked,omitempty"`
+	Deadline         string `json:"deadline,omitempty"`
+
+	id       string
+	question quizio.Question
+	// order holds the stored 1-based choice numbers in displayed order.
+	order []int
+}
+
+// displayed returns the question with its choices in displayed order.
+func (q attemptQuestion) displayed() quizio.Question {
+	if !q.question.HasChoices() {
+		return q.question
+	}
+	return q.question.Reordered(q.order)
+}
+
+func (cfg *apiConfig) handlerAttemptsStart(c *gin.Context) {
+	quiz, err := cfg.db.GetQuizIDFromPath(c.Request.Context(), c.Param("path"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+	if quiz.DeletedAt.Valid {
+		c.JSON(http.StatusGone, gin.H{"error": "Quiz has been deleted"})
+		return
+	}
+	taker, ok := cfg.callerTaker(c)
+	if !ok {
+		return
+	}
+	settings, err := cfg.db.GetQuizSettings(c.Request.Context(), quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve quiz settings"})
+		return
+	}
+	start := time.Now().UTC()
+	switch availability(settings, start) {
+	case quizNotYetOpen:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Quiz is not open yet", "opens_at": settings.OpensAt.String})
+		return
+	case quizClosed:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Quiz is closed", "closes_at": settings.ClosesAt.String})
+		return
+	}
+	// Takers who can be recognised continue their unfinished attempt, which
+	// may have been started on another device, instead of starting over.
+	open, ok, err := cfg.openAttempt(c.Request.Context(), quiz.ID, taker, start)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve attempts"})
+		return
+	}
+	if ok {
+		state, err := cfg.attemptState(c.Request.Context(), open, start)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't read attempt"})
+			return
+		}
+		state["resumed"] = true
+		c.JSON(http.StatusOK, state)
+		return
+	}
+	if !cfg.checkAttemptLimits(c, quiz.ID, taker.UserID, settings, start) {
+		return
+	}
+	rows, err := cfg.db.GetAllQuestionsInQuiz(c.Request.Context(), quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve questions"})
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quiz has no questions"})
+		return
+	}
+
+	seed, err := newSeed()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't start attempt"})
+		return
+	}
+	sectionRows, err := cfg.db.GetQuizSections(c.Request.Context(), quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve quiz sections"})
+		return
+	}
+	pool := make([]quizio.PoolQuestion, len(rows))
+	for i, row := range rows {
+		pool[i] = quizio.PoolQuestion{ID: row.ID, Tags: questionFromDB(row).Tags}
+	}
+	sections := quizSections(sectionRows)
+	drawn := quizio.DrawQuestions(pool, sections, seed, settings.ShuffleQuestions)
+	var ids []string
+	used := []attemptSection{}
+	for i, sectionIDs := range drawn {
+		ids = append(ids, sectionIDs...)
+		if len(sections) > 0 {
+			used = append(used, attemptSection{Section: sections[i], Questions: len(sectionIDs)})
+		}
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quiz sections don't match any questions"})
+		return
+	}
+	order, err := json.Marshal(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't start attempt"})
+		return
+	}
+	usedSections, err := json.Marshal(used)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't start attempt"})
+		return
+	}
+	now := start.Format(time.RFC3339)
+	var deadline sql.NullString
+	if settings.TimeLimitSeconds.Valid {
+		deadline = sql.NullString{
+			String: start.Add(time.Duration(settings.TimeLimitSeconds.Int64) * time.Second).Format(time.RFC3339),
+			Valid:  true,
+		}
+	}
+	attempt := database.Attempt{
+		ID:             uuid.New().String(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		QuizID:         quiz.ID,
+		UserID:         taker.UserID,
+		Seed:           int64(seed),
+		QuestionOrder:  string(order),
+		ShuffleChoices: settings.ShuffleChoices,
+		Sections:       string(usedSections),
+		Deadline:       deadline,
+		TakerID:        taker.TakerID,
+	}
+	created, err := cfg.db.CreateAttempt(c.Request.Context(), database.CreateAttemptParams{
+		ID:             attempt.ID,
+		CreatedAt:      attempt.CreatedAt,
+		UpdatedAt:      attempt.UpdatedAt,
+		QuizID:         attempt.QuizID,
+		UserID:         attempt.UserID,
+		Seed:           attempt.Seed,
+		QuestionOrder:  attempt.QuestionOrder,
+		ShuffleChoices: attempt.ShuffleChoices,
+		Sections:       attempt.Sections,
+		Deadline:       attempt.Deadline,
+		TakerID:        attempt.TakerID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't start attempt"})
+		return
+	}
+	// The limits are checked again as the attempt is created, in case
+	// another attempt was started since they were checked above.
+	if created == 0 {
+		if cfg.checkAttemptLimits(c, quiz.ID, taker.UserID, settings, start) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Another attempt was started at the same time"})
+		}
+		return
+	}
+	questions, err := attemptQuestions(attempt, rows)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't start attempt"})
+		return
+	}
+	cfg.publishAttempt(c.Request.Context(), eventAttemptStarted, attempt, len(ids))
+	response := gin.H{"attempt_id": attempt.ID, "questions": withTimers(questions, nil)}
+	if attempt.Deadline.Valid {
+		response["deadline"] = attempt.Deadline.String
+		response["remaining_seconds"] = remainingSeconds(attempt.Deadline.String, start)
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+func (cfg *apiConfig) handlerAttemptsAnswer(c *gin.Context) {
+	attempt, ok := cfg.getAttempt(c)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	if !cfg.checkDeadline(c, &attempt, now) {
+		return
+	}
+	if attempt.FinishedAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Attempt is already finished"})
+		return
+	}
+	type parameters struct {
+		Position   int    `json:"position"`
+		Answer     int    `json:"answer"`
+		AnswerText string `json:"answer_text"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parameters"})
+		return
+	}
+	rows, err := cfg.db.GetAllQuestionsInQuiz(c.Request.Context(), attempt.QuizID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve questions"})
+		return
+	}
+	questions, err := attemptQuestions(attempt, rows)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't read attempt"})
+		return
+	}
+	var question *attemptQuestion
+	for i := range questions {
+		if questions[i].Position == params.Position {
+			question = &questions[i]
+		}
+	}
+	if question == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+	answers, err := cfg.db.GetAttemptAnswers(c.Request.Context(), attempt.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve answers"})
+		return
+	}
+	for _, a := range answers {
+		if a.QuestionID == question.id {
+			c.JSON(http.StatusConflict, gin.H{"error": "Question has already been answered"})
+			return
+		}
+	}
+	timers, err := cfg.db.GetAttemptQuestionTimers(c.Request.Context(), attempt.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve question timers"})
+		return
+	}
+	timed := withTimers([]attemptQuestion{*question}, timers)[0]
+	if timed.Locked {
+		c.JSON(http.StatusConflict, gin.H{"error": "Question has not been opened"})
+		return
+	}
+	if timed.Deadline != "" && pastDeadline(timed.Deadline, now) {
+		// Record the question as unanswered so it can't be retried.
+		_, err := cfg.db.CreateAttemptAnswer(c.Request.Context(), database.CreateAttemptAnswerParams{
+			AttemptID:  attempt.ID,
+			QuestionID: question.id,
+			CreatedAt:  now.Format(time.RFC3339),
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't save answer"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "Time is up for this question"})
+		return
+	}
+
+	displayed := question.displayed()
+	// Store the choice as numbered in the question, not as displayed.
+	stored := 0
+	if displayed.HasChoices() {
+		if displayed.Choice(params.Answer) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Answer does not refer to a choice"})
+			return
+		}
+		stored = question.order[params.Answer-1]
+	}
+	graded := gradeAnswer(displayed, params.Answer, params.AnswerText)
+	saved, err := cfg.db.CreateAttemptAnswer(c.Request.Context(), database.CreateAttemptAnswerParams{
+		AttemptID:  attempt.ID,
+		QuestionID: question.id,
+		CreatedAt:  now.Format(time.RFC3339),
+		Answer:     int64(stored),
+		AnswerText: params.AnswerText,
+		Correct:    graded.Correct,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't save answer"})
+		return
+	}
+	// Another request may have answered the question since it was checked.
+	if saved == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Question has already been answered"})
+		return
+	}
+	response := attemptAnswer{gradedAnswer: graded}
+	if attempt.Deadline.Valid {
+		remaining := remainingSeconds(attempt.Deadline.String, now)
+		response.RemainingSeconds = &remaining
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// attemptAnswer is the response to an answer given in an attempt.
+type attemptAnswer struct {
+	gradedAnswer
+	// RemainingSeconds is the time left in a timed attempt.
+	RemainingSeconds *int64 `json:"remaining_seconds,omitempty"`
+}
+
+func (cfg *apiConfig) handlerAttemptsFinish(c *gin.Context) {
+	attempt, ok := cfg.getAttempt(c)
+	if !ok {
+		return
+	}
+	if attempt.FinishedAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Attempt is already finished"})
+		return
+	}
+	// An attempt finished after its deadline counts as finished at the
+	// deadline.
+	finishedAt := time.Now().UTC()
+	if attempt.Deadline.Valid && pastDeadline(attempt.Deadline.String, finishedAt) {
+		finishedAt, _ = time.Parse(time.RFC3339, attempt.Deadline.String)
+	}
+	score, total, err := cfg.finishAttempt(c.Request.Context(), &attempt, finishedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't finish attempt"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"score": score, "total": total})
+}
+
+// finishAttempt scores the answers given in an attempt and marks it finished
+// at finishedAt. It returns the score and the number of questions.
+func (cfg *apiConfig) finishAttempt(ctx context.Context, attempt *database.Attempt, finishedAt time.Time) (int, int, error) {
+	var order []string
+	if err := json.Unmarshal([]byte(attempt.QuestionOrder), &order); err != nil {
+		return 0, 0, err
+	}
+	answers, err := cfg.db.GetAttemptAnswers(ctx, attempt.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	score := 0
+	for _, a := range answers {
+		if a.Correct {
+			score++
+		}
+	}
+	attempt.FinishedAt = sql.NullString{String: finishedAt.Format(time.RFC3339), Valid: true}
+	attempt.Score = sql.NullInt64{Int64: int64(score), Valid: true}
+	finished, err := cfg.db.FinishAttempt(ctx, database.FinishAttemptParams{
+		ID:         attempt.ID,
+		FinishedAt: attempt.FinishedAt,
+		UpdatedAt:  time.Now().UTC().Format(time.RFC3339),
+		Score:      attempt.Score,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	// Expired attempts are also finished in the background, so only announce
+	// the attempt if this was what finished it.
+	if finished > 0 {
+		cfg.publishAttempt(ctx, eventAttemptFinished, *attempt, len(order))
+	}
+	return score, len(order), nil
+}
+
+// checkAttemptLimits enforces the maximum number of attempts and the
+// cooldown between them. Both are counted per user, so a quiz that has
+// either can only be taken signed in; nickname takers could simply join
+// again under another nickname. It writes the error response itself
+// and returns false if no attempt may be started.
+func (cfg *apiConfig) checkAttemptLimits(c *gin.Context, quizID string, userID sql.NullString, settings database.GetQuizSettingsRow, now time.Time) bool {
+	if !settings.MaxAttempts.Valid && !settings.AttemptCooldownSeconds.Valid {
+		return true
+	}
+	if !userID.Valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign in to take this quiz"})
+		return false
+	}
+	stats, err := cfg.db.GetUserAttemptStats(c.Request.Context(), database.GetUserAttemptStatsParams{
+		QuizID: quizID,
+		UserID: userID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve attempts"})
+		return false
+	}
+	if settings.MaxAttempts.Valid && stats.Attempts >= settings.MaxAttempts.Int64 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No attempts left", "max_attempts": settings.MaxAttempts.Int64})
+		return false
+	}
+	if settings.AttemptCooldownSeconds.Valid && stats.LastStartedAt != "" {
+		last, err := time.Parse(time.RFC3339, stats.LastStartedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve attempts"})
+			return false
+		}
+		next := last.Add(time.Duration(settings.AttemptCooldownSeconds.Int64) * time.Second)
+		if now.Before(next) {
+			wait := remainingSeconds(next.Format(time.RFC3339), now)
+			c.Header("Retry-After", strconv.FormatInt(wait, 10))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Wait before starting another attempt", "retry_after_seconds": wait})
+			return false
+		}
+	}
+	return true
+}
+
+// getAttempt loads the attempt named in the request path and checks that the
+// caller may use it. Attempts started while signed in belong to that user;
+// anonymous attempts are available to anyone holding the attempt ID. It
+// writes the error response itself and returns false on failure.
+func (cfg *apiConfig) getAttempt(c *gin.Context) (database.Attempt, bool) {
+	attempt, err := cfg.db.GetAttempt(c.Request.Context(), c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attempt not found"})
+		return attempt, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve attempt"})
+		return attempt, false
+	}
+	if !attempt.UserID.Valid && !attempt.TakerID.Valid {
+		return attempt, true
+	}
+	if c.GetHeader("Authorization") == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header"})
+		return attempt, false
+	}
+	taker, ok := cfg.callerTaker(c)
+	if !ok {
+		return attempt, false
+	}
+	if taker.UserID != attempt.UserID || taker.TakerID != attempt.TakerID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this attempt"})
+		return attempt, false
+	}
+	return attempt, true
+}
+
+// attemptQuestions returns the questions of an attempt in the order they
+// are shown, labelled with the title of the section that drew them.
+// Positions follow the order stored when the attempt started, so they stay
+// stable if questions are deleted later; deleted questions are left out.
+func attemptQuestions(attempt database.Attempt, rows []database.QuizQuestion) ([]attemptQuestion, error) {
+	var order []string
+	if err := json.Unmarshal([]byte(attempt.QuestionOrder), &order); err != nil {
+		return nil, err
+	}
+	var sections []attemptSection
+	if err := json.Unmarshal([]byte(attempt.Sections), &sections); err != nil {
+		return nil, err
+	}
+	titles := make([]string, len(order))
+	next := 0
+	for _, section := range sections {
+		for n := 0; n < section.Questions && next < len(titles); n++ {
+			titles[next] = section.Title
+			next++
+		}
+	}
+	byID := make(map[string]database.QuizQuestion, len(rows))
+	for _, row := range rows {
+		byID[row.ID] = row
+	}
+	var questions []attemptQuestion
+	for i, id := range order {
+		row, ok := byID[id]
+		if !ok {
+			continue
+		}
+		q := attemptQuestion{
+			Position:       i + 1,
+			Section:        titles[i],
+			QuestionNumber: row.QuestionNumber,
+			QuestionType:   quizio.QuestionType(row.QuestionType),
+			QuestionText:   row.QuestionText,
+			id:             row.ID,
+			question:       questionFromDB(row),
+		}
+		q.TimeLimitSeconds = q.question.TimeLimit
+		if q.question.HasChoices() {
+			if attempt.ShuffleChoices {
+				q.order = q.question.ShuffledChoiceNumbers(uint64(attempt.Seed), uint64(row.QuestionNumber))
+			} else {
+				q.order = q.question.ChoiceNumbers()
+			}
+			q.Choices = q.displayed().Choices
+		}
+		questions = append(questions, q)
+	}
+	return questions, nil
+}
+
+func newSeed() (uint64, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b[:]), nil
+}
+
+func quizSections(rows []database.QuizSection) []quizio.Section {
+	var sections []quizio.Section
+	for _, row := range rows {
+		sections = append(sections, quizio.Section{
+			Title: row.Title,
+			Tag:   row.Tag,
+			Draw:  int(row.DrawCount),
+		})
+	}
+	return sections
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/Corogura/quizmaker/internal/mail"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	verifyEmailTokenDuration   = 48 * time.Hour
+	resetPasswordTokenDuration = time.Hour
+	// sendEmailTimeout bounds sending an email in the background.
+	sendEmailTimeout = time.Minute
+)
+
+// validEmail reports whether email is a bare address, without a display
+// name or anything else around it.
+func validEmail(email string) bool {
+	addr, err := netmail.ParseAddress(email)
+	return err == nil && addr.Name == "" && addr.Address == email
+}
+
+// handlerRequestEmailVerification sends the caller a new link to verify
+// their email address.
+func (cfg *apiConfig) handlerRequestEmailVerification(c *gin.Context) {
+	user, ok := cfg.currentUser(c)
+	if !ok {
+		return
+	}
+	if user.GuestExpiresAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Guest accounts have no email"})
+		return
+	}
+	if user.EmailVerifiedAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already verified"})
+		return
+	}
+	if err := cfg.sendVerificationEmail(c.Request.Context(), user.ID, user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't send verification email"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
+
+// handlerVerifyEmail marks the email address a verification link was sent
+// to as verified. Each link works once, and only while the account still
+// has that address.
+func (cfg *apiConfig) handlerVerifyEmail(c *gin.Context) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
+		return
+	}
+	token, err := auth.ValidateSingleUseJWT(auth.TokenTypeVerifyEmail, params.Token, cfg.jwtKeys)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	now := time.Now().UTC()
+	tx, err := cfg.dbConn.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't start transaction"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	if !useToken(c, qtx, token, now) {
+		return
+	}
+	verified, err := qtx.VerifyUserEmail(c.Request.Context(), database.VerifyUserEmailParams{
+		EmailVerifiedAt: sql.NullString{String: now.Format(time.RFC3339), Valid: true},
+		UpdatedAt:       now.Format(time.RFC3339),
+		ID:              token.UserID.String(),
+		Email:           token.Email,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't verify email"})
+		return
+	}
+	if verified == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The account no longer has this email"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't verify email"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully", "email": token.Email})
+}
+
+// handlerRequestPasswordReset emails a link to reset the password of the
+// account with the email, if there is one. The response is the same either
+// way, and the email is sent in the background so that it takes as long
+// too, so it doesn't tell who has an account.
+func (cfg *apiConfig) handlerRequestPasswordReset(c *gin.Context) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
+		return
+	}
+	user, err := cfg.db.GetUserByEmail(c.Request.Context(), params.Email)
+	if err == nil && !user.GuestExpiresAt.Valid {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), sendEmailTimeout)
+			defer cancel()
+			if err := cfg.sendPasswordResetEmail(ctx, user); err != nil {
+				log.Printf("Couldn't send password reset email: %v", err)
+			}
+		}()
+	} else if err != nil && err != sql.ErrNoRows {
+		log.Printf("Couldn't look up user for password reset: %v", err)
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email belongs to an account, a link to reset its password has been sent"})
+}
+
+// handlerResetPassword sets a new password with a reset link. Each link
+// works once, and only until the password changes. Every session is logged
+// out and every API key deleted, and the email counts as verified, since
+// the link reached it.
+func (cfg *apiConfig) handlerResetPassword(c *gin.Context) {
+	type parameters struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
+		return
+	}
+	if params.NewPassword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New password is required"})
+		return
+	}
+	token, err := auth.ValidateSingleUseJWT(auth.TokenTypeResetPassword, params.Token, cfg.jwtKeys)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	hashedPassword, ok := cfg.hashNewPassword(c, params.NewPassword)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	tx, err := cfg.dbConn.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't start transaction"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	if !useToken(c, qtx, token, now) {
+		return
+	}
+	verified, err := qtx.VerifyUserEmail(c.Request.Context(), database.VerifyUserEmailParams{
+		EmailVerifiedAt: sql.NullString{String: now.Format(time.RFC3339), Valid: true},
+		UpdatedAt:       now.Format(time.RFC3339),
+		ID:              token.UserID.String(),
+		Email:           token.Email,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't reset password"})
+		return
+	}
+	if verified == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The account no longer has this email"})
+		return
+	}
+	user, err := qtx.GetUser(c.Request.Context(), token.UserID.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't reset password"})
+		return
+	}
+	if auth.PasswordFingerprint(user.HashedPw) != token.Password {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The password has changed since this link was sent"})
+		return
+	}
+	reset, err := qtx.ResetUserPassword(c.Request.Context(), database.ResetUserPasswordParams{
+		HashedPw:   hashedPassword,
+		UpdatedAt:  now.Format(time.RFC3339),
+		ID:         user.ID,
+		HashedPw_2: user.HashedPw,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't reset password"})
+		return
+	}
+	if reset == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The password has changed since this link was sent"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't reset password"})
+		return
+	}
+	keysDeleted, err := cfg.revokeOtherAccess(c, token.UserID.String(), "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't revoke sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully", "api_keys_deleted": keysDeleted})
+}
+
+// useToken marks a single use token as used, refusing one that already
+// was. It writes the error response itself.
+func useToken(c *gin.Context, qtx *database.Queries, token auth.SingleUseToken, now time.Time) bool {
+	used, err := qtx.UseToken(c.Request.Context(), database.UseTokenParams{
+		ID:        token.TokenID,
+		RevokedAt: now.Format(time.RFC3339),
+		ExpiresAt: token.ExpiresAt.Format(time.RFC3339),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't use token"})
+		return false
+	}
+	if used == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token has already been used"})
+		return false
+	}
+	return true
+}
+
+func (cfg *apiConfig) sendVerificationEmail(ctx context.Context, userID, email string) error {
+	token, err := auth.MakeSingleUseJWT(auth.TokenTypeVerifyEmail, uuid.MustParse(userID), email, cfg.jwtKeys, verifyEmailTokenDuration)
+	if err != nil {
+		return err
+	}
+	return cfg.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Verify your email for Quiz Maker",
+		Body: fmt.Sprintf("Open this link to verify your email address:\n\n%s\n\nThe link expires in %d hours.\n",
+			cfg.publicURL+"/?verify_email="+url.QueryEscape(token), int(verifyEmailTokenDuration.Hours())),
+	})
+}
+
+func (cfg *apiConfig) sendPasswordResetEmail(ctx context.Context, user database.User) error {
+	token, err := auth.MakePasswordResetJWT(uuid.MustParse(user.ID), user.Email, user.HashedPw, cfg.jwtKeys, resetPasswordTokenDuration)
+	if err != nil {
+		return err
+	}
+	return cfg.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Quiz Maker password",
+		Body: fmt.Sprintf("Open this link to choose a new password:\n\n%s\n\nThe link expires in %d minutes. If you didn't ask to reset your password, you can ignore this email.\n",
+			cfg.publicURL+"/?reset_password="+url.QueryEscape(token), int(resetPasswordTokenDuration.Minutes())),
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestResetPasswordAfterPasswordChange(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := createTestUser(t, cfg, "alice@example.com", "correct horse battery")
+	r := gin.New()
+	r.POST("/users/reset-password", cfg.handlerResetPassword)
+
+	stale, err := auth.MakePasswordResetJWT(uuid.MustParse(user.ID), user.Email, user.HashedPw, cfg.jwtKeys, resetPasswordTokenDuration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashedPassword, err := cfg.passwords.Hash("a different password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.db.UpdatePassword(t.Context(), database.UpdatePasswordParams{
+		HashedPw:  hashedPassword,
+		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+		ID:        user.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, body := doRequest(t, r, http.MethodPost, "/users/reset-password", "", map[string]string{
+		"token":        stale,
+		"new_password": "a brand new password",
+	})
+	if status != http.StatusBadRequest {
+		t.Fatalf("reset with a link sent before the change: status %d, body %v, want %d", status, body, http.StatusBadRequest)
+	}
+
+	current, err := auth.MakePasswordResetJWT(uuid.MustParse(user.ID), user.Email, hashedPassword, cfg.jwtKeys, resetPasswordTokenDuration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, body = doRequest(t, r, http.MethodPost, "/users/reset-password", "", map[string]string{
+		"token":        current,
+		"new_password": "a brand new password",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("reset: status %d, body %v", status, body)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// guestDuration is how long a guest account lasts unless it is upgraded
+	// to a full account.
+	guestDuration = 7 * 24 * time.Hour
+	// maxGuestQuizzes and maxGuestQuestions limit what a guest can create.
+	maxGuestQuizzes   = 5
+	maxGuestQuestions = 50
+	// maxGuestsPerIP guest accounts can be created from one IP address
+	// every guestRateWindow, so the quotas can't be escaped by simply
+	// making another guest.
+	maxGuestsPerIP  = 10
+	guestRateWindow = time.Hour
+)
+
+// handlerUsersGuest creates a guest account of its own for the caller and
+// logs them in. Guests have no password; once the account expires it is
+// deleted along with its quizzes, unless it has been upgraded.
+func (cfg *apiConfig) handlerUsersGuest(c *gin.Context) {
+	now := time.Now().UTC()
+	if !cfg.checkGuestRateLimit(c, now) {
+		return
+	}
+	// Expired guests are cleaned up as new ones arrive.
+	if err := cfg.deleteExpiredGuests(c.Request.Context(), now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't remove expired guests"})
+		return
+	}
+	userID := uuid.New()
+	expiresAt := now.Add(guestDuration)
+	err := cfg.db.CreateGuestUser(c.Request.Context(), database.CreateGuestUserParams{
+		ID:             userID.String(),
+		CreatedAt:      now.Format(time.RFC3339),
+		UpdatedAt:      now.Format(time.RFC3339),
+		Email:          "guest-" + strings.ReplaceAll(userID.String(), "-", ""),
+		GuestExpiresAt: sql.NullString{String: expiresAt.Format(time.RFC3339), Valid: true},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create guest"})
+		return
+	}
+	sessionID := uuid.New().String()
+	token, access, err := auth.MakeJWT(userID, sessionID, cfg.jwtKeys, cfg.accessTokenTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create token"})
+		return
+	}
+	refreshExpiresAt := now.Add(cfg.refreshTokenTTL)
+	if expiresAt.Before(refreshExpiresAt) {
+		refreshExpiresAt = expiresAt
+	}
+	rt, _ := auth.MakeRefreshToken()
+	rToken, err := cfg.db.CreateRefreshToken(c.Request.Context(), database.CreateRefreshTokenParams{
+		Token:            rt,
+		UserID:           userID.String(),
+		CreatedAt:        now.Format(time.RFC3339),
+		UpdatedAt:        now.Format(time.RFC3339),
+		ExpiresAt:        refreshExpiresAt.Format(time.RFC3339),
+		FamilyID:         sessionID,
+		SessionStartedAt: now.Format(time.RFC3339),
+		UserAgent:        c.Request.UserAgent(),
+		Ip:               c.ClientIP(),
+		AccessTokenID:    access.TokenID,
+		AccessExpiresAt:  access.ExpiresAt.Format(time.RFC3339),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create refresh token"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"id":               userID.String(),
+		"token":            token,
+		"refresh_token":    rToken.Token,
+		"expires_in":       int(cfg.accessTokenTTL.Seconds()),
+		"guest_expires_at": expiresAt.Format(time.RFC3339),
+	})
+}
+
+// handlerUsersUpgrade turns the caller's guest account into a full account
+// with an email and password. Its quizzes are kept.
+func (cfg *apiConfig) handlerUsersUpgrade(c *gin.Context) {
+	type parameters struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
+		return
+	}
+	if params.Email == "" || params.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email and password are required"})
+		return
+	}
+	if !validEmail(params.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+		return
+	}
+	user, ok := cfg.currentUser(c)
+	if !ok {
+		return
+	}
+	if !user.GuestExpiresAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Account is not a guest account"})
+		return
+	}
+	if _, err := cfg.db.GetUserByEmail(c.Request.Context(), params.Email); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already in use"})
+		return
+	} else if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't check email"})
+		return
+	}
+	hashedPassword, ok := cfg.hashNewPassword(c, params.Password)
+	if !ok {
+		return
+	}
+	err := cfg.db.UpgradeGuestUser(c.Request.Context(), database.UpgradeGuestUserParams{
+		Email:     params.Email,
+		HashedPw:  hashedPassword,
+		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+		ID:        user.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't upgrade account"})
+		return
+	}
+	if err := cfg.sendVerificationEmail(c.Request.Context(), user.ID, params.Email); err != nil {
+		log.Printf("Couldn't send verification email: %v", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"id": user.ID, "email": params.Email})
+}
+
+// checkGuestRateLimit counts a new guest account against the caller's IP
+// address and refuses it if too many have been created from there lately.
+// It writes the error response itself.
+func (cfg *apiConfig) checkGuestRateLimit(c *gin.Context, now time.Time) bool {
+	ctx := c.Request.Context()
+	windowStart := now.Add(-guestRateWindow).Format(time.RFC3339)
+	// Windows that are over are cleaned up as new ones start.
+	if err := cfg.db.DeleteExpiredRateLimits(ctx, windowStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't check rate limit"})
+		return false
+	}
+	count, err := cfg.db.CountRateLimited(ctx, database.CountRateLimitedParams{
+		Key:         "guest-ip:" + c.ClientIP(),
+		Now:         now.Format(time.RFC3339),
+		WindowStart: windowStart,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't check rate limit"})
+		return false
+	}
+	if count > maxGuestsPerIP {
+		c.Header("Retry-After", strconv.Itoa(int(guestRateWindow.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many guest accounts have been created from your address; try again later or create an account"})
+		return false
+	}
+	return true
+}
+
+// deleteExpiredGuests deletes the guest accounts that expired before now.
+// Their quizzes go with them, but the attempts they made at other users'
+// quizzes are kept for those users' results and leaderboards, under a
+// nickname taker that has the guest's display name if they chose one.
+func (cfg *apiConfig) deleteExpiredGuests(ctx context.Context, now time.Time) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	cutoff := sql.NullString{String: now.Format(time.RFC3339), Valid: true}
+	if err := qtx.CreateTakersForExpiredGuests(ctx, cutoff); err != nil {
+		return err
+	}
+	if err := qtx.MoveExpiredGuestAttempts(ctx, cutoff); err != nil {
+		return err
+	}
+	if err := qtx.DeleteExpiredGuestUsers(ctx, cutoff); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// currentUser returns the signed-in user, refusing guests whose account
+// has expired.
+func (cfg *apiConfig) currentUser(c *gin.Context) (database.User, bool) {
+	bearer, err := auth.GetBearerToken(c.Request.Header)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header"})
+		return database.User{}, false
+	}
+	userID, err := auth.ValidateJWT(bearer, cfg.jwtKeys, cfg.revocations)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return database.User{}, false
+	}
+	return cfg.activeUser(c, userID.String())
+}
+
+// activeUser looks up a user, refusing guests whose account has expired.
+func (cfg *apiConfig) activeUser(c *gin.Context, userID string) (database.User, bool) {
+	user, err := cfg.db.GetUser(c.Request.Context(), userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found"})
+		return user, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't find user"})
+		return user, false
+	}
+	if guestExpired(user.GuestExpiresAt, time.Now().UTC()) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Guest account has expired"})
+		return user, false
+	}
+	return user, true
+}
+
+// checkQuizQuota refuses a new quiz to a guest who already has the most
+// they may create. It writes the error response itself.
+func (cfg *apiConfig) checkQuizQuota(c *gin.Context, userID string) bool {
+	user, ok := cfg.activeUser(c, userID)
+	if !ok {
+		return false
+	}
+	if !user.GuestExpiresAt.Valid {
+		return true
+	}
+	quizzes, err := cfg.db.GetAllQuizzesByUserID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve quizzes"})
+		return false
+	}
+	if len(quizzes) >= maxGuestQuizzes {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Guests can create at most %d quizzes; create an account to add more", maxGuestQuizzes)})
+		return false
+	}
+	return true
+}
+
+// checkQuestionQuota refuses to let a guest grow a quiz with existing
+// questions by adding more than the quota allows. It writes the error
+// response itself.
+func (cfg *apiConfig) checkQuestionQuota(c *gin.Context, userID string, existing, adding int64) bool {
+	user, ok := cfg.activeUser(c, userID)
+	if !ok {
+		return false
+	}
+	if !user.GuestExpiresAt.Valid || existing+adding <= maxGuestQuestions {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Guests can add at most %d questions to a quiz; create an account to add more", maxGuestQuestions)})
+	return false
+}
+
+// guestExpired reports whether a guest account with the expiry has expired.
+// Full accounts have no expiry.
+func guestExpired(expiresAt sql.NullString, now time.Time) bool {
+	if !expiresAt.Valid {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339, expiresAt.String)
+	return err != nil || !now.Before(t)
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handlerJWKS serves the public keys tokens are signed with, so other
+// services can verify them. Keys stay listed while they are still accepted.
+func (cfg *apiConfig) handlerJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, cfg.jwtKeys.JWKS())
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxLeaderboardSize is the largest number of entries a leaderboard can
+	// show.
+	maxLeaderboardSize = 100
+	// maxDisplayName is the maximum length of a display name in characters.
+	maxDisplayName = maxTakerNickname
+)
+
+type leaderboardEntry struct {
+	Rank         int    `json:"rank"`
+	Name         string `json:"name"`
+	Score        int64  `json:"score"`
+	Total        int64  `json:"total"`
+	SecondsTaken int64  `json:"seconds_taken"`
+	FinishedAt   string `json:"finished_at"`
+	// You marks the entry of the caller.
+	You bool `json:"you,omitempty"`
+}
+
+// handlerGetLeaderboard returns the best score of each signed-in or nickname
+// taker. The owner can always see it, with names; everyone else only when the
+// owner has enabled it, and with pseudonyms unless the owner chose to show
+// names. Names are display names and nicknames, never email addresses, so
+// users who haven't chosen a display name keep their pseudonym.
+func (cfg *apiConfig) handlerGetLeaderboard(c *gin.Context) {
+	quiz, err := cfg.db.GetQuizIDFromPath(c.Request.Context(), c.Param("path"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+	if quiz.DeletedAt.Valid {
+		c.JSON(http.StatusGone, gin.H{"error": "Quiz has been deleted"})
+		return
+	}
+	// Takers never have API keys, but scripts may use one to read the
+	// leaderboard as its owner.
+	caller := attemptTaker{}
+	ok := false
+	if bearer, err := auth.GetBearerToken(c.Request.Header); err == nil && auth.IsAPIKey(bearer) {
+		var userID uuid.UUID
+		userID, ok = cfg.authorize(c, auth.ScopeReadResults)
+		caller.UserID = sql.NullString{String: userID.String(), Valid: ok}
+	} else {
+		caller, ok = cfg.callerTaker(c)
+	}
+	if !ok {
+		return
+	}
+	isOwner := caller.UserID.Valid && caller.UserID.String == quiz.UserID
+	settings, err := cfg.db.GetQuizSettings(c.Request.Context(), quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve quiz settings"})
+		return
+	}
+	if !settings.LeaderboardEnabled && !isOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Leaderboard is not enabled"})
+		return
+	}
+	rows, err := cfg.db.GetQuizLeaderboard(c.Request.Context(), database.GetQuizLeaderboardParams{
+		QuizID: quiz.ID,
+		Limit:  settings.LeaderboardSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve leaderboard"})
+		return
+	}
+	showNames := settings.LeaderboardShowNames || isOwner
+	entries := make([]leaderboardEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = leaderboardEntry{
+			Rank:         i + 1,
+			Name:         row.Name,
+			Score:        row.Score.Int64,
+			Total:        row.Total,
+			SecondsTaken: row.SecondsTaken,
+			FinishedAt:   row.FinishedAt.String,
+			You:          (caller.UserID.Valid && caller.UserID == row.UserID) || (caller.TakerID.Valid && caller.TakerID == row.TakerID),
+		}
+		takerID := row.UserID.String + row.TakerID.String
+		if showNames {
+			entries[i].Name = takerName(quiz.ID, takerID, row.Name)
+		} else {
+			entries[i].Name = pseudonym(quiz.ID, takerID)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":    settings.LeaderboardEnabled,
+		"show_names": showNames,
+		"entries":    entries,
+	})
+}
+
+// handlerUpdateDisplayName sets the name the caller is shown by on
+// leaderboards that show names. An empty name removes it.
+func (cfg *apiConfig) handlerUpdateDisplayName(c *gin.Context) {
+	type parameters struct {
+		DisplayName string `json:"display_name"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
+		return
+	}
+	displayName := strings.Join(strings.Fields(params.DisplayName), " ")
+	if utf8.RuneCountInString(displayName) > maxDisplayName {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Display name must be at most 20 characters"})
+		return
+	}
+	user, ok := cfg.currentUser(c)
+	if !ok {
+		return
+	}
+	err := cfg.db.UpdateDisplayName(c.Request.Context(), database.UpdateDisplayNameParams{
+		DisplayName: sql.NullString{String: displayName, Valid: displayName != ""},
+		UpdatedAt:   time.Now().UTC().Format(time.RFC3339),
+		ID:          user.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't update display name"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"display_name": displayName})
+}
+
+// takerName names a taker of a quiz by their display name or nickname, or
+// by their pseudonym if they have neither. Email addresses are never shown.
+func takerName(quizID, takerID, name string) string {
+	if name == "" {
+		return pseudonym(quizID, takerID)
+	}
+	return name
+}
+
+// pseudonym names a taker on the leaderboard of one quiz. It stays the same
+// for the quiz but differs between quizzes, so takers can't be followed from
+// one leaderboard to another.
+func pseudonym(quizID, takerID string) string {
+	sum := sha256.Sum256([]byte(quizID + ":" + takerID))
+	return "Taker " + strings.ToUpper(hex.EncodeToString(sum[:3]))
+}
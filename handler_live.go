@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/quizio"
+	"github.com/coder/websocket"
+	"github.com/gin-gonic/gin"
+)
+
+// handlerLiveStart starts a live session of a quiz. The host key in the
+// response is the only way to connect as the host, so it is only given to
+// the quiz owner.
+func (cfg *apiConfig) handlerLiveStart(c *gin.Context) {
+	quiz, ok := cfg.ownedQuiz(c, auth.ScopeWriteQuizzes)
+	if !ok {
+		return
+	}
+	rows, err := cfg.db.GetAllQuestionsInQuiz(c.Request.Context(), quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve questions"})
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quiz has no questions"})
+		return
+	}
+	questions := make([]quizio.Question, len(rows))
+	for i, row := range rows {
+		questions[i] = questionFromDB(row)
+	}
+	session, hostKey, err := cfg.live.Start(quiz.ID, questions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't start live session"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"code": session.Code, "host_key": hostKey})
+}
+
+// handlerLiveHost connects the host to a live session. The host key is
+// checked once it arrives in the first message over the WebSocket.
+func (cfg *apiConfig) handlerLiveHost(c *gin.Context) {
+	session, ok := cfg.live.Session(c.Param("code"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Live session not found"})
+		return
+	}
+	conn, err := websocket.Accept(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	session.ServeHost(c.Request.Context(), conn)
+}
+
+// handlerLivePlay joins a player to a live session. Errors such as a taken
+// nickname are reported over the WebSocket, since browsers don't expose the
+// response to a failed upgrade.
+func (cfg *apiConfig) handlerLivePlay(c *gin.Context) {
+	session, ok := cfg.live.Session(c.Param("code"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Live session not found"})
+		return
+	}
+	conn, err := websocket.Accept(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	session.ServePlayer(c.Request.Context(), conn)
+}
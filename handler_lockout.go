@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	// accountLockout slows down guessing the password of one account.
+	accountLockout = auth.LockoutPolicy{
+		FreeAttempts: 5,
+		BaseLockout:  30 * time.Second,
+		MaxLockout:   time.Hour,
+		Window:       24 * time.Hour,
+	}
+	// ipLockout slows down trying passwords against many accounts from one
+	// address. It allows more, as many users can share an address.
+	ipLockout = auth.LockoutPolicy{
+		FreeAttempts: 20,
+		BaseLockout:  30 * time.Second,
+		MaxLockout:   time.Hour,
+		Window:       24 * time.Hour,
+	}
+)
+
+// Types of the events in the audit log.
+const (
+	auditAccountLocked   = "account_locked"
+	auditIPLocked        = "ip_locked"
+	auditAccountUnlocked = "account_unlocked"
+	auditIPUnlocked      = "ip_unlocked"
+)
+
+func accountLockoutKey(email string) string { return "email:" + email }
+func ipLockoutKey(ip string) string         { return "ip:" + ip }
+
+// loginAttempt is a login counted against an email address and the
+// caller's IP address before its password or code is checked. It counts
+// as a failure unless refunded.
+type loginAttempt struct {
+	email string
+	ip    string
+	locks []attemptLock
+}
+
+// attemptLock is the count of one key of a login attempt, and the lockout
+// counting it started, if any.
+type attemptLock struct {
+	key         string
+	event       string
+	failures    int64
+	lockout     time.Duration
+	lockedUntil sql.NullString
+}
+
+// startLoginAttempt counts a login attempt, refusing it while the email
+// address or the caller's IP address is locked out. Counting and locking
+// out are done in one transaction before the password is checked, so that
+// attempts made at the same time can't all get past a lockout. It writes
+// the error response itself.
+func (cfg *apiConfig) startLoginAttempt(c *gin.Context, email string, now time.Time) (loginAttempt, bool) {
+	ctx := c.Request.Context()
+	// Failures old enough to be forgotten are cleaned up as new ones come.
+	if err := cfg.db.DeleteExpiredLoginFailures(ctx, now.Add(-max(accountLockout.Window, ipLockout.Window)).Format(time.RFC3339)); err != nil {
+		log.Printf("Couldn't delete expired failed logins: %v", err)
+	}
+	attempt := loginAttempt{email: email, ip: c.ClientIP()}
+	policies := []struct {
+		key    string
+		policy auth.LockoutPolicy
+		event  string
+	}{
+		{accountLockoutKey(email), accountLockout, auditAccountLocked},
+		{ipLockoutKey(attempt.ip), ipLockout, auditIPLocked},
+	}
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't check login attempts"})
+		return loginAttempt{}, false
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	var wait time.Duration
+	for _, p := range policies {
+		failures, err := qtx.StartLoginAttempt(ctx, database.StartLoginAttemptParams{
+			Key:          p.key,
+			Now:          now.Format(time.RFC3339),
+			ForgetBefore: now.Add(-p.policy.Window).Format(time.RFC3339),
+		})
+		if err == sql.ErrNoRows {
+			// The key is locked out, so nothing was counted.
+			locked, err := qtx.GetLoginFailures(ctx, p.key)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't check login attempts"})
+				return loginAttempt{}, false
+			}
+			if lockedUntil, err := time.Parse(time.RFC3339, locked.LockedUntil.String); err == nil {
+				wait = max(wait, lockedUntil.Sub(now))
+			}
+			continue
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't check login attempts"})
+			return loginAttempt{}, false
+		}
+		lock := attemptLock{key: p.key, event: p.event, failures: failures, lockout: p.policy.Lockout(failures)}
+		if lock.lockout > 0 {
+			lock.lockedUntil = sql.NullString{String: now.Add(lock.lockout).Format(time.RFC3339), Valid: true}
+			err := qtx.LockLogin(ctx, database.LockLoginParams{
+				LockedUntil: lock.lockedUntil,
+				Key:         p.key,
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't check login attempts"})
+				return loginAttempt{}, false
+			}
+		}
+		attempt.locks = append(attempt.locks, lock)
+	}
+	if wait > 0 {
+		// Nothing is counted while either key is locked out.
+		tx.Rollback()
+		seconds := max(int(wait.Round(time.Second).Seconds()), 1)
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       fmt.Sprintf("Too many failed login attempts. Try again in %s.", wait.Round(time.Second)),
+			"retry_after": seconds,
+		})
+		return loginAttempt{}, false
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't check login attempts"})
+		return loginAttempt{}, false
+	}
+	return attempt, true
+}
+
+// loginFailed records in the audit log the lockouts a failed login attempt
+// started. userID is the user with the address, if there is one.
+func (cfg *apiConfig) loginFailed(ctx context.Context, attempt loginAttempt, userID sql.NullString) error {
+	for _, lock := range attempt.locks {
+		if lock.lockout == 0 {
+			continue
+		}
+		detail := fmt.Sprintf("Locked out for %s after %d failed logins", lock.lockout, lock.failures)
+		if err := cfg.audit(ctx, lock.event, userID, attempt.email, attempt.ip, detail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refundLoginAttempt takes back a login attempt that didn't fail, such as
+// one with the right password, so that it isn't counted as a failure.
+func (cfg *apiConfig) refundLoginAttempt(ctx context.Context, attempt loginAttempt) {
+	for _, lock := range attempt.locks {
+		err := cfg.db.RefundLoginAttempt(ctx, database.RefundLoginAttemptParams{
+			LockedUntil: lock.lockedUntil,
+			Key:         lock.key,
+		})
+		if err != nil {
+			log.Printf("Couldn't take back login attempt: %v", err)
+		}
+	}
+}
+
+// clearLoginFailures forgets the failed logins with an email address once
+// its user has logged in. Those from the IP address are kept, as a guesser
+// could otherwise clear them by logging in to an account of their own.
+func (cfg *apiConfig) clearLoginFailures(ctx context.Context, email string) {
+	if _, err := cfg.db.DeleteLoginFailures(ctx, accountLockoutKey(email)); err != nil {
+		log.Printf("Couldn't clear failed logins: %v", err)
+	}
+}
+
+// audit adds an event to the audit log.
+func (cfg *apiConfig) audit(ctx context.Context, event string, userID sql.NullString, email, ip, detail string) error {
+	return cfg.db.CreateAuditEvent(ctx, database.CreateAuditEventParams{
+		ID:        uuid.New().String(),
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Event:     event,
+		UserID:    userID,
+		Email:     email,
+		Ip:        ip,
+		Detail:    detail,
+	})
+}
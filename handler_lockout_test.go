@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// loginStatus logs in with the email and password and returns the status.
+func loginStatus(t *testing.T, h http.Handler, email, password string) int {
+	t.Helper()
+	status, _ := doRequest(t, h, http.MethodPost, "/users/login", "", map[string]string{
+		"email":    email,
+		"password": password,
+	})
+	return status
+}
+
+func TestLoginLockout(t *testing.T) {
+	cfg := newTestConfig(t)
+	createTestUser(t, cfg, "alice@example.com", "correct horse battery")
+	r := newSessionRouter(cfg)
+
+	// The free attempts and the one after them fail normally; that one
+	// locks the account out.
+	for i := 0; i <= accountLockout.FreeAttempts; i++ {
+		if status := loginStatus(t, r, "alice@example.com", "wrong password"); status != http.StatusUnauthorized {
+			t.Fatalf("failed login %d: status %d, want %d", i+1, status, http.StatusUnauthorized)
+		}
+	}
+	status, body := doRequest(t, r, http.MethodPost, "/users/login", "", map[string]string{
+		"email":    "alice@example.com",
+		"password": "correct horse battery",
+	})
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("login while locked out: status %d, body %v, want %d", status, body, http.StatusTooManyRequests)
+	}
+	if body["retry_after"] == nil {
+		t.Errorf("login while locked out: no retry_after in %v", body)
+	}
+}
+
+func TestLoginLockoutParallel(t *testing.T) {
+	cfg := newTestConfig(t)
+	// A slower hash leaves the guesses time to overlap.
+	cfg.passwords = auth.BcryptHasher{Cost: 10}
+	createTestUser(t, cfg, "alice@example.com", "correct horse battery")
+	r := newSessionRouter(cfg)
+
+	const guesses = 20
+	statuses := make(chan int, guesses)
+	var wg sync.WaitGroup
+	for range guesses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- loginStatus(t, r, "alice@example.com", "wrong password")
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+	checked := 0
+	for status := range statuses {
+		switch status {
+		case http.StatusUnauthorized:
+			checked++
+		case http.StatusTooManyRequests:
+		default:
+			t.Errorf("parallel guess: status %d", status)
+		}
+	}
+	if checked > accountLockout.FreeAttempts+1 {
+		t.Errorf("%d parallel guesses were checked, want at most %d", checked, accountLockout.FreeAttempts+1)
+	}
+}
+
+func TestLoginSuccessNotCounted(t *testing.T) {
+	cfg := newTestConfig(t)
+	createTestUser(t, cfg, "alice@example.com", "correct horse battery")
+	r := newSessionRouter(cfg)
+
+	for range 3 {
+		loginStatus(t, r, "alice@example.com", "wrong password")
+	}
+	if status := loginStatus(t, r, "alice@example.com", "correct horse battery"); status != http.StatusOK {
+		t.Fatalf("login: status %d", status)
+	}
+	if _, err := cfg.db.GetLoginFailures(t.Context(), accountLockoutKey("alice@example.com")); err == nil {
+		t.Error("failed logins with the email are kept after logging in")
+	}
+	failures, err := cfg.db.GetLoginFailures(t.Context(), ipLockoutKey("192.0.2.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failures.Failures != 3 {
+		t.Errorf("IP address has %d failed logins, want 3", failures.Failures)
+	}
+}
+
+func TestTrustedProxiesFromEnv(t *testing.T) {
+	tests := []struct {
+		proxies string
+		want    string
+	}{
+		{"", "192.0.2.1"},
+		{"198.51.100.0/24", "192.0.2.1"},
+		{"192.0.2.1, 198.51.100.7", "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		t.Setenv("TRUSTED_PROXIES", tt.proxies)
+		r := gin.New()
+		if err := r.SetTrustedProxies(trustedProxiesFromEnv()); err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		r.GET("/", func(c *gin.Context) { got = c.ClientIP() })
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", "203.0.113.9")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		if got != tt.want {
+			t.Errorf("TRUSTED_PROXIES=%q: ClientIP() = %q, want %q", tt.proxies, got, tt.want)
+		}
+	}
+}
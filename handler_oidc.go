@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/Corogura/quizmaker/internal/oidc"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// oidcFlowCookie holds the OIDC flow token while the user is at the
+	// identity provider.
+	oidcFlowCookie   = "oidc_flow"
+	oidcFlowDuration = 10 * time.Minute
+	// oidcLoginDuration is how long the page has to trade the login token
+	// it is sent back with for a session.
+	oidcLoginDuration = 2 * time.Minute
+)
+
+// oidcLoginError is an error logging in with the identity provider whose
+// message can be shown to the user.
+type oidcLoginError string
+
+func (e oidcLoginError) Error() string { return string(e) }
+
+// handlerOIDCConfig tells the login page whether there is an identity
+// provider to log in with, and what to call it.
+func (cfg *apiConfig) handlerOIDCConfig(c *gin.Context) {
+	name := cfg.oidcName
+	if name == "" {
+		name = "Single Sign-On"
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": cfg.oidc != nil, "name": name})
+}
+
+// handlerOIDCLogin sends the user to the identity provider to log in. What
+// is needed to check the response is kept in a cookie, which ties it to
+// this browser.
+func (cfg *apiConfig) handlerOIDCLogin(c *gin.Context) {
+	if cfg.oidc == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not set up"})
+		return
+	}
+	flow := oidc.NewFlow()
+	authURL, err := cfg.oidc.AuthCodeURL(c.Request.Context(), flow)
+	if err != nil {
+		log.Printf("Couldn't reach identity provider: %v", err)
+		cfg.redirectOIDCError(c, "Couldn't reach the identity provider")
+		return
+	}
+	token, err := auth.MakeOIDCFlowJWT(flow.State, flow.Nonce, flow.Verifier, cfg.jwtKeys, oidcFlowDuration)
+	if err != nil {
+		cfg.redirectOIDCError(c, "Couldn't start logging in")
+		return
+	}
+	cfg.setOIDCFlowCookie(c, token, int(oidcFlowDuration.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// handlerOIDCCallback is where the identity provider sends the user back.
+// The ID token is verified and its identity linked to a user, and the page
+// is given a login token to trade for a session.
+func (cfg *apiConfig) handlerOIDCCallback(c *gin.Context) {
+	if cfg.oidc == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not set up"})
+		return
+	}
+	flowToken, _ := c.Cookie(oidcFlowCookie)
+	cfg.setOIDCFlowCookie(c, "", -1)
+	if providerError := c.Query("error"); providerError != "" {
+		log.Printf("Identity provider returned error: %s %s", providerError, c.Query("error_description"))
+		cfg.redirectOIDCError(c, "The identity provider didn't log you in")
+		return
+	}
+	state, nonce, verifier, err := auth.ValidateOIDCFlowJWT(flowToken, cfg.jwtKeys)
+	if err != nil || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		cfg.redirectOIDCError(c, "Login expired, please try again")
+		return
+	}
+	claims, err := cfg.oidc.Exchange(c.Request.Context(), c.Query("code"), oidc.Flow{State: state, Nonce: nonce, Verifier: verifier})
+	if err != nil {
+		log.Printf("Couldn't log in with identity provider: %v", err)
+		cfg.redirectOIDCError(c, "Couldn't log in with the identity provider")
+		return
+	}
+	user, err := cfg.linkIdentity(c.Request.Context(), claims)
+	var loginErr oidcLoginError
+	if errors.As(err, &loginErr) {
+		cfg.redirectOIDCError(c, loginErr.Error())
+		return
+	} else if err != nil {
+		log.Printf("Couldn't link identity: %v", err)
+		cfg.redirectOIDCError(c, "Couldn't log in")
+		return
+	}
+	token, err := auth.MakeSingleUseJWT(auth.TokenTypeOIDCLogin, uuid.MustParse(user.ID), user.Email, cfg.jwtKeys, oidcLoginDuration)
+	if err != nil {
+		cfg.redirectOIDCError(c, "Couldn't log in")
+		return
+	}
+	c.Redirect(http.StatusFound, cfg.publicURL+"/?oidc_login="+url.QueryEscape(token))
+}
+
+// handlerLoginOIDC trades the login token from logging in with the
+// identity provider for a session, or for a login challenge if the user has
+// two-factor authentication.
+func (cfg *apiConfig) handlerLoginOIDC(c *gin.Context) {
+	type parameters struct {
+		LoginToken string `json:"login_token"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
+		return
+	}
+	token, err := auth.ValidateSingleUseJWT(auth.TokenTypeOIDCLogin, params.LoginToken, cfg.jwtKeys)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, log in again"})
+		return
+	}
+	user, err := cfg.db.GetUser(c.Request.Context(), token.UserID.String())
+	if err != nil || user.Email != token.Email {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, log in again"})
+		return
+	}
+	used, err := cfg.db.UseToken(c.Request.Context(), database.UseTokenParams{
+		ID:        token.TokenID,
+		RevokedAt: time.Now().UTC().Format(time.RFC3339),
+		ExpiresAt: token.ExpiresAt.Format(time.RFC3339),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't use token"})
+		return
+	}
+	if used == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, log in again"})
+		return
+	}
+	cfg.completeLogin(c, user)
+}
+
+// linkIdentity returns the user an identity at the provider belongs to.
+// The first time an identity logs in, it is linked to the user with its
+// email address, who is created if there is none. The provider is trusted
+// to have verified the address, so only addresses it says it has are
+// accepted, and they count as verified here too.
+func (cfg *apiConfig) linkIdentity(ctx context.Context, claims oidc.Claims) (database.User, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	identity, err := cfg.db.GetUserIdentity(ctx, database.GetUserIdentityParams{
+		Issuer:  cfg.oidc.Issuer(),
+		Subject: claims.Subject,
+	})
+	if err == nil {
+		err = cfg.db.UpdateUserIdentityLogin(ctx, database.UpdateUserIdentityLoginParams{
+			Email:       claims.Email,
+			LastLoginAt: now,
+			ID:          identity.ID,
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+		return cfg.db.GetUser(ctx, identity.UserID)
+	} else if err != sql.ErrNoRows {
+		return database.User{}, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified || !validEmail(claims.Email) {
+		return database.User{}, oidcLoginError("The identity provider didn't share a verified email address")
+	}
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	user, err := qtx.GetUserByEmail(ctx, claims.Email)
+	if err == sql.ErrNoRows {
+		err = qtx.CreateUser(ctx, database.CreateUserParams{
+			ID:        uuid.New().String(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			Email:     claims.Email,
+			// Without a password, the user logs in with the provider
+			// until they set one by resetting it.
+			HashedPw: "",
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+		user, err = qtx.GetUserByEmail(ctx, claims.Email)
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+	if user.GuestExpiresAt.Valid {
+		return database.User{}, oidcLoginError("The email address belongs to a guest account")
+	}
+	if !user.EmailVerifiedAt.Valid {
+		_, err := qtx.VerifyUserEmail(ctx, database.VerifyUserEmailParams{
+			EmailVerifiedAt: sql.NullString{String: now, Valid: true},
+			UpdatedAt:       now,
+			ID:              user.ID,
+			Email:           user.Email,
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+	}
+	err = qtx.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+		ID:          uuid.New().String(),
+		UserID:      user.ID,
+		Issuer:      cfg.oidc.Issuer(),
+		Subject:     claims.Subject,
+		Email:       claims.Email,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return database.User{}, err
+	}
+	return cfg.db.GetUser(ctx, user.ID)
+}
+
+func (cfg *apiConfig) setOIDCFlowCookie(c *gin.Context, value string, maxAge int) {
+	// Lax, so the cookie comes back with the provider's redirect.
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, value, maxAge, "/auth/oidc", "", strings.HasPrefix(cfg.publicURL, "https://"), true)
+}
+
+func (cfg *apiConfig) redirectOIDCError(c *gin.Context, message string) {
+	c.Redirect(http.StatusFound, cfg.publicURL+"/?oidc_error="+url.QueryEscape(message))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/Corogura/quizmaker/internal/feed"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// feedKeepAlive is how often an idle event stream gets a comment, so that
+// proxies don't time it out.
+const feedKeepAlive = 30 * time.Second
+
+// Types of the events in a quiz's feed.
+const (
+	eventAttemptStarted  = "attempt_started"
+	eventAttemptFinished = "attempt_finished"
+)
+
+// handlerQuizEvents streams the attempts on a quiz to its owner as
+// Server-Sent Events while they happen. Browsers' EventSource can't send an
+// Authorization header, so the page reads the stream with fetch instead.
+func (cfg *apiConfig) handlerQuizEvents(c *gin.Context) {
+	quiz, ok := cfg.ownedQuiz(c, auth.ScopeReadResults)
+	if !ok {
+		return
+	}
+	events, unsubscribe := cfg.feed.Subscribe(quiz.ID)
+	defer unsubscribe()
+	keepAlive := time.NewTicker(feedKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.Render(-1, sse.Event{Event: e.Type, Data: e.Data})
+			return true
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// publishAttempt tells the owner of a quiz about an attempt that started or
+// finished.
+func (cfg *apiConfig) publishAttempt(ctx context.Context, eventType string, attempt database.Attempt, total int) {
+	if !cfg.feed.Subscribed(attempt.QuizID) {
+		return
+	}
+	data := gin.H{
+		"attempt_id": attempt.ID,
+		"taker":      cfg.attemptTakerName(ctx, attempt),
+		"started_at": attempt.CreatedAt,
+		"questions":  total,
+	}
+	if attempt.FinishedAt.Valid {
+		data["finished_at"] = attempt.FinishedAt.String
+		data["score"] = attempt.Score.Int64
+	}
+	cfg.feed.Publish(attempt.QuizID, feed.Event{Type: eventType, Data: data})
+}
+
+// attemptTakerName names the taker of an attempt for the quiz owner, the
+// same way the leaderboard does.
+func (cfg *apiConfig) attemptTakerName(ctx context.Context, attempt database.Attempt) string {
+	switch {
+	case attempt.UserID.Valid:
+		name := ""
+		if user, err := cfg.db.GetUser(ctx, attempt.UserID.String); err == nil {
+			name = user.DisplayName.String
+		}
+		return takerName(attempt.QuizID, attempt.UserID.String, name)
+	case attempt.TakerID.Valid:
+		name := ""
+		if taker, err := cfg.db.GetTaker(ctx, attempt.TakerID.String); err == nil {
+			name = taker.Nickname
+		}
+		return takerName(attempt.QuizID, attempt.TakerID.String, name)
+	}
+	return "Anonymous"
+}
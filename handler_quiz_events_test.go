@@ -0,0 +1,33 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/database"
+)
+
+func TestAttemptTakerName(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := createTestUser(t, cfg, "alice@example.com", "correct horse battery")
+	attempt := database.Attempt{
+		QuizID: "quiz",
+		UserID: sql.NullString{String: user.ID, Valid: true},
+	}
+
+	if got, want := cfg.attemptTakerName(t.Context(), attempt), pseudonym("quiz", user.ID); got != want {
+		t.Errorf("attemptTakerName() without a display name = %q, want %q", got, want)
+	}
+	err := cfg.db.UpdateDisplayName(t.Context(), database.UpdateDisplayNameParams{
+		DisplayName: sql.NullString{String: "Alice", Valid: true},
+		UpdatedAt:   time.Now().UTC().Format(time.RFC3339),
+		ID:          user.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.attemptTakerName(t.Context(), attempt); got != "Alice" {
+		t.Errorf("attemptTakerName() = %q, want %q", got, "Alice")
+	}
+}
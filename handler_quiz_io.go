@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/Corogura/quizmaker/internal/quizio"
+	"github.com/Corogura/quizmaker/internal/worksheet"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerQuestionsImportCSV(c *gin.Context) {
+	path := c.Param("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Path is required"})
+		return
+	}
+	quiz, err := cfg.db.GetQuizIDFromPath(c.Request.Context(), path)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+	if quiz.DeletedAt.Valid {
+		c.JSON(http.StatusGone, gin.H{"error": "Quiz has been deleted"})
+		return
+	}
+	userID, ok := cfg.authorize(c, auth.ScopeWriteQuizzes)
+	if !ok {
+		return
+	}
+	if quiz.UserID != userID.String() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to add questions to this quiz"})
+		return
+	}
+	body, err := uploadReader(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload: " + err.Error()})
+		return
+	}
+	reader, err := quizio.NewCSVReader(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV: " + err.Error()})
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't start transaction"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	questionCount, err := qtx.GetQuestionCountInQuiz(c.Request.Context(), quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve question count"})
+		return
+	}
+
+	rowErrors := []*quizio.RowError{}
+	imported := 0
+	for {
+		question, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *quizio.RowError
+		if errors.As(err, &rowErr) {
+			rowErrors = append(rowErrors, rowErr)
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Couldn't read upload"})
+			return
+		}
+		err = createQuestion(c.Request.Context(), qtx, quiz.ID, questionCount+int64(imported)+1, question)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create question"})
+			return
+		}
+		imported++
+	}
+	if imported == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No valid rows to import", "errors": rowErrors})
+		return
+	}
+	if !cfg.checkQuestionQuota(c, userID.String(), questionCount, int64(imported)) {
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't import questions"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"imported": imported, "errors": rowErrors})
+}
+
+func (cfg *apiConfig) handlerQuestionsImportGIFT(c *gin.Context) {
+	importQuestions(cfg, c, quizio.ParseGIFT)
+}
+
+func (cfg *apiConfig) handlerQuestionsImportAiken(c *gin.Context) {
+	importQuestions(cfg, c, quizio.ParseAiken)
+}
+
+func (cfg *apiConfig) handlerQuestionsImportQTI(c *gin.Context) {
+	importQuestions(cfg, c, quizio.ParseQTI)
+}
+
+// importQuestions parses an uploaded question bank and adds the valid
+// questions to the quiz in one transaction. With ?dry_run=true nothing is
+// stored and the parsed questions are returned as a preview instead. E is
+// the format's error type, reported back to the client as is.
+func importQuestions[E any](cfg *apiConfig, c *gin.Context, parse func(io.Reader) ([]quizio.Question, []E, error)) {
+	path := c.Param("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Path is required"})
+		return
+	}
+	quiz, err := cfg.db.GetQuizIDFromPath(c.Request.Context(), path)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+	if quiz.DeletedAt.Valid {
+		c.JSON(http.StatusGone, gin.H{"error": "Quiz has been deleted"})
+		return
+	}
+	userID, ok := cfg.authorize(c, auth.ScopeWriteQuizzes)
+	if !ok {
+		return
+	}
+	if quiz.UserID != userID.String() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to add questions to this quiz"})
+		return
+	}
+	body, err := uploadReader(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload: " + err.Error()})
+		return
+	}
+	questions, rowErrors, err := parse(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Couldn't read upload: " + err.Error()})
+		return
+	}
+	if c.Query("dry_run") == "true" {
+		c.JSON(http.StatusOK, gin.H{"questions": questions, "errors": rowErrors})
+		return
+	}
+	if len(questions) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No valid questions to import", "errors": rowErrors})
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't start transaction"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	questionCount, err := qtx.GetQuestionCountInQuiz(c.Request.Context(), quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve question count"})
+		return
+	}
+	if !cfg.checkQuestionQuota(c, userID.String(), questionCount, int64(len(questions))) {
+		return
+	}
+	for i, question := range questions {
+		err = createQuestion(c.Request.Context(), qtx, quiz.ID, questionCount+int64(i)+1, question)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create question"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't import questions"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"imported": len(questions), "errors": rowErrors})
+}
+
+func (cfg *apiConfig) handlerQuestionsExportCSV(c *gin.Context) {
+	cfg.exportQuestions(c, "text/csv; charset=utf-8", ".csv", withoutTitle(quizio.WriteCSV))
+}
+
+func (cfg *apiConfig) handlerQuestionsExportGIFT(c *gin.Context) {
+	cfg.exportQuestions(c, "text/plain; charset=utf-8", ".gift.txt", withoutTitle(quizio.WriteGIFT))
+}
+
+func (cfg *apiConfig) handlerQuestionsExportAiken(c *gin.Context) {
+	cfg.exportQuestions(c, "text/plain; charset=utf-8", ".aiken.txt", withoutTitle(quizio.WriteAiken))
+}
+
+func (cfg *apiConfig) handlerQuestionsExportQTI(c *gin.Context) {
+	cfg.exportQuestions(c, "application/zip", ".qti.zip", quizio.WriteQTI)
+}
+
+// exportQuestions sends every question in the quiz to its owner as a file
+// download written by write, which is given the quiz title.
+func (cfg *apiConfig) exportQuestions(c *gin.Context, contentType, extension string, write func(io.Writer, string, []quizio.Question) error) {
+	path := c.Param("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Path is required"})
+		return
+	}
+	quiz, err := cfg.db.GetQuizIDFromPath(c.Request.Context(), path)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+	if quiz.DeletedAt.Valid {
+		c.JSON(http.StatusGone, gin.H{"error": "Quiz has been deleted"})
+		return
+	}
+	userID, ok := cfg.authorize(c, auth.ScopeReadQuizzes)
+	if !ok {
+		return
+	}
+	if quiz.UserID != userID.String() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to export this quiz"})
+		return
+	}
+	questions, err := cfg.db.GetAllQuestionsInQuiz(c.Request.Context(), quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve questions"})
+		return
+	}
+	exported := make([]quizio.Question, 0, len(questions))
+	for _, q := range questions {
+		exported = append(exported, questionFromDB(q))
+	}
+	var buf bytes.Buffer
+	if err := write(&buf, quiz.Title, exported); errors.Is(err, quizio.ErrUnsupportedQuestion) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Quiz contains questions this format can't represent: " + err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't export questions"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+path+extension+`"`)
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// handlerPrintQuiz renders the quiz as a printable worksheet, or as its
+// answer key, for the owner. Takers don't get it, as it would show every
+// question at once, whatever the pools, timers and open hours. Passing the
+// same seed shuffles the worksheet and the answer key the same way.
+func (cfg *apiConfig) handlerPrintQuiz(c *gin.Context) {
+	quiz, ok := cfg.ownedQuiz(c, auth.ScopeReadQuizzes)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "html")
+	if format != "html" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be html or pdf"})
+		return
+	}
+	answers, err := strconv.ParseBool(c.DefaultQuery("answers", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid answers parameter"})
+		return
+	}
+	questions, err := cfg.db.GetAllQuestionsInQuiz(c.Request.Context(), quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve questions"})
+		return
+	}
+	ws := worksheet.Worksheet{
+		Title:     quiz.Title,
+		AnswerKey: answers,
+	}
+	for _, q := range questions {
+		ws.Questions = append(ws.Questions, questionFromDB(q))
+	}
+	if seedParam := c.Query("seed"); seedParam != "" {
+		seed, err := strconv.ParseUint(seedParam, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seed"})
+			return
+		}
+		ws.Questions = quizio.Shuffled(ws.Questions, seed)
+	}
+
+	var buf bytes.Buffer
+	contentType := "text/html; charset=utf-8"
+	if format == "pdf" {
+		contentType = "application/pdf"
+		err = worksheet.WritePDF(&buf, ws)
+	} else {
+		err = worksheet.WriteHTML(&buf, ws)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't render quiz"})
+		return
+	}
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+func withoutTitle(write func(io.Writer, []quizio.Question) error) func(io.Writer, string, []quizio.Question) error {
+	return func(w io.Writer, _ string, questions []quizio.Question) error {
+		return write(w, questions)
+	}
+}
+
+// uploadReader returns the uploaded file without buffering it. Multipart
+// forms are read up to the first part named "file"; any other content type
+// is treated as the raw file.
+func uploadReader(r *http.Request) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return r.Body, nil
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, errors.New("invalid multipart body")
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("missing file field")
+		}
+		if err != nil {
+			return nil, errors.New("invalid multipart body")
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+	}
+}
+
+func createQuestion(ctx context.Context, db *database.Queries, quizID string, number int64, q quizio.Question) error {
+	return db.CreateQuizQuestions(ctx, database.CreateQuizQuestionsParams{
+		ID:             uuid.New().String(),
+		QuizID:         quizID,
+		QuestionNumber: number,
+		QuestionText:   q.Text,
+		Choice1:        q.Choice(1),
+		Choice2:        q.Choice(2),
+		Choice3:        q.Choice(3),
+		Choice4:        q.Choice(4),
+		Answer:         int64(q.Answer),
+		Explanation:    nullString(q.Explanation),
+		QuestionType:   string(q.Type),
+		AcceptedAnswers: sql.NullString{
+			String: strings.Join(q.AcceptedAnswers, "\n"),
+			Valid:  q.Type == quizio.TypeShortAnswer,
+		},
+		NumericAnswer: sql.NullFloat64{
+			Float64: q.NumericAnswer,
+			Valid:   q.Type == quizio.TypeNumeric,
+		},
+		NumericTolerance: sql.NullFloat64{
+			Float64: q.Tolerance,
+			Valid:   q.Type == quizio.TypeNumeric,
+		},
+		Choice1Feedback: nullString(q.Feedback(1)),
+		Choice2Feedback: nullString(q.Feedback(2)),
+		Choice3Feedback: nullString(q.Feedback(3)),
+		Choice4Feedback: nullString(q.Feedback(4)),
+		Tags:            strings.Join(q.Tags, "\n"),
+		TimeLimitSeconds: sql.NullInt64{
+			Int64: int64(q.TimeLimit),
+			Valid: q.TimeLimit > 0,
+		},
+	})
+}
+
+func questionFromDB(q database.QuizQuestion) quizio.Question {
+	question := quizio.Question{
+		Type:        quizio.QuestionType(q.QuestionType),
+		Text:        q.QuestionText,
+		Explanation: q.Explanation.String,
+		TimeLimit:   int(q.TimeLimitSeconds.Int64),
+	}
+	if q.Tags != "" {
+		question.Tags = strings.Split(q.Tags, "\n")
+	}
+	switch question.Type {
+	case quizio.TypeShortAnswer:
+		question.AcceptedAnswers = strings.Split(q.AcceptedAnswers.String, "\n")
+	case quizio.TypeNumeric:
+		question.NumericAnswer = q.NumericAnswer.Float64
+		question.Tolerance = q.NumericTolerance.Float64
+	case quizio.TypeTrueFalse:
+		question.Choices = []string{q.Choice1, q.Choice2}
+		question.Answer = int(q.Answer)
+		question.ChoiceFeedback = choiceFeedback(q.Choice1Feedback, q.Choice2Feedback)
+	default:
+		question.Choices = []string{q.Choice1, q.Choice2, q.Choice3, q.Choice4}
+		question.Answer = int(q.Answer)
+		question.ChoiceFeedback = choiceFeedback(q.Choice1Feedback, q.Choice2Feedback, q.Choice3Feedback, q.Choice4Feedback)
+	}
+	return question
+}
+
+// choiceFeedback returns the stored feedback per choice, or nil if no choice
+// has any.
+func choiceFeedback(feedback ...sql.NullString) []string {
+	var out []string
+	for i, f := range feedback {
+		if f.String == "" {
+			continue
+		}
+		if out == nil {
+			out = make([]string, len(feedback))
+		}
+		out[i] = f.String
+	}
+	return out
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
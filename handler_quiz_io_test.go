@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/google/uuid"
+)
+
+// createTestQuiz creates a quiz owned by the user at the path.
+func createTestQuiz(t *testing.T, cfg *apiConfig, user database.User, path string) {
+	t.Helper()
+	now := time.Now().UTC().Format(time.RFC3339)
+	err := cfg.db.CreateQuiz(t.Context(), database.CreateQuizParams{
+		ID:        uuid.NewString(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Title:     "Capitals",
+		UserID:    user.ID,
+		Path:      path,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrintQuizOwnerOnly(t *testing.T) {
+	cfg := newTestConfig(t)
+	owner := createTestUser(t, cfg, "alice@example.com", "correct horse battery")
+	createTestUser(t, cfg, "bob@example.com", "correct horse battery")
+	createTestQuiz(t, cfg, owner, "capitals")
+	r := newSessionRouter(cfg)
+	r.GET("/quizzes/:path/print", cfg.handlerPrintQuiz)
+
+	if status, body := doRequest(t, r, http.MethodGet, "/quizzes/capitals/print", "", nil); status != http.StatusUnauthorized {
+		t.Errorf("print without logging in: status %d, body %v, want %d", status, body, http.StatusUnauthorized)
+	}
+	other, _ := login(t, r, "bob@example.com", "correct horse battery")
+	if status, body := doRequest(t, r, http.MethodGet, "/quizzes/capitals/print", other, nil); status != http.StatusForbidden {
+		t.Errorf("print as another user: status %d, body %v, want %d", status, body, http.StatusForbidden)
+	}
+
+	token, _ := login(t, r, "alice@example.com", "correct horse battery")
+	req := httptest.NewRequest(http.MethodGet, "/quizzes/capitals/print", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Capitals") {
+		t.Errorf("print as the owner: status %d, body %.200s", w.Code, w.Body.String())
+	}
+}
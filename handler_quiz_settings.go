@@ -0,0 +1,320 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/Corogura/quizmaker/internal/quizio"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ownedQuiz loads the quiz named in the request path and checks that the
+// caller owns it, and if they use an API key, that it has the scope. It
+// writes the error response itself and returns false on failure.
+func (cfg *apiConfig) ownedQuiz(c *gin.Context, scope string) (database.GetQuizIDFromPathRow, bool) {
+	quiz, err := cfg.db.GetQuizIDFromPath(c.Request.Context(), c.Param("path"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return quiz, false
+	}
+	if quiz.DeletedAt.Valid {
+		c.JSON(http.StatusGone, gin.H{"error": "Quiz has been deleted"})
+		return quiz, false
+	}
+	userID, ok := cfg.authorize(c, scope)
+	if !ok {
+		return quiz, false
+	}
+	if quiz.UserID != userID.String() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to change this quiz"})
+		return quiz, false
+	}
+	return quiz, true
+}
+
+// quizSettings is the JSON form of database.GetQuizSettingsRow.
+type quizSettings struct {
+	ShuffleQuestions       bool   `json:"shuffle_questions"`
+	ShuffleChoices         bool   `json:"shuffle_choices"`
+	TimeLimitSeconds       int64  `json:"time_limit_seconds"`
+	OpensAt                string `json:"opens_at"`
+	ClosesAt               string `json:"closes_at"`
+	MaxAttempts            int64  `json:"max_attempts"`
+	AttemptCooldownSeconds int64  `json:"attempt_cooldown_seconds"`
+	LeaderboardEnabled     bool   `json:"leaderboard_enabled"`
+	LeaderboardShowNames   bool   `json:"leaderboard_show_names"`
+	LeaderboardSize        int64  `json:"leaderboard_size"`
+}
+
+func settingsResponse(row database.GetQuizSettingsRow) quizSettings {
+	return quizSettings{
+		ShuffleQuestions:       row.ShuffleQuestions,
+		ShuffleChoices:         row.ShuffleChoices,
+		TimeLimitSeconds:       row.TimeLimitSeconds.Int64,
+		OpensAt:                row.OpensAt.String,
+		ClosesAt:               row.ClosesAt.String,
+		MaxAttempts:            row.MaxAttempts.Int64,
+		AttemptCooldownSeconds: row.AttemptCooldownSeconds.Int64,
+		LeaderboardEnabled:     row.LeaderboardEnabled,
+		LeaderboardShowNames:   row.LeaderboardShowNames,
+		LeaderboardSize:        row.LeaderboardSize,
+	}
+}
+
+// quizAvailability is whether a quiz can be started at a given time.
+type quizAvailability int
+
+const (
+	quizOpen quizAvailability = iota
+	quizNotYetOpen
+	quizClosed
+)
+
+func availability(settings database.GetQuizSettingsRow, now time.Time) quizAvailability {
+	if settings.OpensAt.Valid {
+		if opensAt, err := time.Parse(time.RFC3339, settings.OpensAt.String); err == nil && now.Before(opensAt) {
+			return quizNotYetOpen
+		}
+	}
+	if settings.ClosesAt.Valid {
+		if closesAt, err := time.Parse(time.RFC3339, settings.ClosesAt.String); err == nil && !now.Before(closesAt) {
+			return quizClosed
+		}
+	}
+	return quizOpen
+}
+
+func (cfg *apiConfig) handlerGetQuizSettings(c *gin.Context) {
+	quiz, ok := cfg.ownedQuiz(c, auth.ScopeReadQuizzes)
+	if !ok {
+		return
+	}
+	settings, err := cfg.db.GetQuizSettings(c.Request.Context(), quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve quiz settings"})
+		return
+	}
+	c.JSON(http.StatusOK, settingsResponse(settings))
+}
+
+// handlerUpdateQuizSettings changes the settings present in the request and
+// leaves the others as they are.
+func (cfg *apiConfig) handlerUpdateQuizSettings(c *gin.Context) {
+	quiz, ok := cfg.ownedQuiz(c, auth.ScopeWriteQuizzes)
+	if !ok {
+		return
+	}
+	type parameters struct {
+		ShuffleQuestions *bool `json:"shuffle_questions"`
+		ShuffleChoices   *bool `json:"shuffle_choices"`
+		// TimeLimitSeconds limits the whole attempt; 0 removes the limit.
+		TimeLimitSeconds *int64 `json:"time_limit_seconds"`
+		// OpensAt and ClosesAt are RFC 3339 timestamps; an empty string
+		// removes them.
+		OpensAt  *string `json:"opens_at"`
+		ClosesAt *string `json:"closes_at"`
+		// MaxAttempts and AttemptCooldownSeconds apply per user; 0 removes
+		// them.
+		MaxAttempts            *int64 `json:"max_attempts"`
+		AttemptCooldownSeconds *int64 `json:"attempt_cooldown_seconds"`
+		// LeaderboardShowNames shows takers by name rather than by
+		// pseudonym; LeaderboardSize is the number of entries shown.
+		LeaderboardEnabled   *bool  `json:"leaderboard_enabled"`
+		LeaderboardShowNames *bool  `json:"leaderboard_show_names"`
+		LeaderboardSize      *int64 `json:"leaderboard_size"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parameters"})
+		return
+	}
+	settings, err := cfg.db.GetQuizSettings(c.Request.Context(), quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve quiz settings"})
+		return
+	}
+	if params.ShuffleQuestions != nil {
+		settings.ShuffleQuestions = *params.ShuffleQuestions
+	}
+	if params.ShuffleChoices != nil {
+		settings.ShuffleChoices = *params.ShuffleChoices
+	}
+	if params.TimeLimitSeconds != nil {
+		if *params.TimeLimitSeconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Time limit must not be negative"})
+			return
+		}
+		settings.TimeLimitSeconds = sql.NullInt64{
+			Int64: *params.TimeLimitSeconds,
+			Valid: *params.TimeLimitSeconds > 0,
+		}
+	}
+	if params.OpensAt != nil {
+		if settings.OpensAt, err = settingTime(*params.OpensAt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Opening time must be an RFC 3339 timestamp"})
+			return
+		}
+	}
+	if params.ClosesAt != nil {
+		if settings.ClosesAt, err = settingTime(*params.ClosesAt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Closing time must be an RFC 3339 timestamp"})
+			return
+		}
+	}
+	if settings.OpensAt.Valid && settings.ClosesAt.Valid && settings.ClosesAt.String <= settings.OpensAt.String {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quiz must close after it opens"})
+		return
+	}
+	if params.MaxAttempts != nil {
+		if *params.MaxAttempts < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Maximum attempts must not be negative"})
+			return
+		}
+		settings.MaxAttempts = sql.NullInt64{
+			Int64: *params.MaxAttempts,
+			Valid: *params.MaxAttempts > 0,
+		}
+	}
+	if params.AttemptCooldownSeconds != nil {
+		if *params.AttemptCooldownSeconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Attempt cooldown must not be negative"})
+			return
+		}
+		settings.AttemptCooldownSeconds = sql.NullInt64{
+			Int64: *params.AttemptCooldownSeconds,
+			Valid: *params.AttemptCooldownSeconds > 0,
+		}
+	}
+	if params.LeaderboardEnabled != nil {
+		settings.LeaderboardEnabled = *params.LeaderboardEnabled
+	}
+	if params.LeaderboardShowNames != nil {
+		settings.LeaderboardShowNames = *params.LeaderboardShowNames
+	}
+	if params.LeaderboardSize != nil {
+		if *params.LeaderboardSize < 1 || *params.LeaderboardSize > maxLeaderboardSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Leaderboard size must be between 1 and %d", maxLeaderboardSize)})
+			return
+		}
+		settings.LeaderboardSize = *params.LeaderboardSize
+	}
+	err = cfg.db.UpdateQuizSettings(c.Request.Context(), database.UpdateQuizSettingsParams{
+		ID:                     quiz.ID,
+		ShuffleQuestions:       settings.ShuffleQuestions,
+		ShuffleChoices:         settings.ShuffleChoices,
+		TimeLimitSeconds:       settings.TimeLimitSeconds,
+		OpensAt:                settings.OpensAt,
+		ClosesAt:               settings.ClosesAt,
+		MaxAttempts:            settings.MaxAttempts,
+		AttemptCooldownSeconds: settings.AttemptCooldownSeconds,
+		LeaderboardEnabled:     settings.LeaderboardEnabled,
+		LeaderboardShowNames:   settings.LeaderboardShowNames,
+		LeaderboardSize:        settings.LeaderboardSize,
+		UpdatedAt:              time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't update quiz settings"})
+		return
+	}
+	c.JSON(http.StatusOK, settingsResponse(settings))
+}
+
+// settingTime parses an RFC 3339 timestamp from the settings and stores it in
+// UTC, so stored timestamps compare as strings. An empty string clears it.
+func settingTime(s string) (sql.NullString, error) {
+	if s == "" {
+		return sql.NullString{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: t.UTC().Format(time.RFC3339), Valid: true}, nil
+}
+
+func (cfg *apiConfig) handlerGetQuizSections(c *gin.Context) {
+	quiz, ok := cfg.ownedQuiz(c, auth.ScopeReadQuizzes)
+	if !ok {
+		return
+	}
+	rows, err := cfg.db.GetQuizSections(c.Request.Context(), quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve quiz sections"})
+		return
+	}
+	sections := quizSections(rows)
+	if sections == nil {
+		sections = []quizio.Section{}
+	}
+	c.JSON(http.StatusOK, gin.H{"sections": sections})
+}
+
+// handlerUpdateQuizSections replaces the sections of a quiz. An empty list
+// removes them, so that every attempt gets every question again.
+func (cfg *apiConfig) handlerUpdateQuizSections(c *gin.Context) {
+	quiz, ok := cfg.ownedQuiz(c, auth.ScopeWriteQuizzes)
+	if !ok {
+		return
+	}
+	type parameters struct {
+		Sections []quizio.Section `json:"sections"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parameters"})
+		return
+	}
+	for i := range params.Sections {
+		section := &params.Sections[i]
+		if section.Draw < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Section %d: draw must not be negative", i+1)})
+			return
+		}
+		section.Title = strings.TrimSpace(section.Title)
+		if tags := quizio.NormalizeTags([]string{section.Tag}); len(tags) > 0 {
+			section.Tag = tags[0]
+		} else {
+			section.Tag = ""
+		}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't update quiz sections"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	if err := qtx.DeleteQuizSections(c.Request.Context(), quiz.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't update quiz sections"})
+		return
+	}
+	for i, section := range params.Sections {
+		err := qtx.CreateQuizSection(c.Request.Context(), database.CreateQuizSectionParams{
+			ID:        uuid.New().String(),
+			QuizID:    quiz.ID,
+			Position:  int64(i + 1),
+			Title:     section.Title,
+			Tag:       section.Tag,
+			DrawCount: int64(section.Draw),
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't update quiz sections"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't update quiz sections"})
+		return
+	}
+	if params.Sections == nil {
+		params.Sections = []quizio.Section{}
+	}
+	c.JSON(http.StatusOK, gin.H{"sections": params.Sections})
+}
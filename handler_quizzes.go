@@ -4,10 +4,12 @@ import (
 	"database/sql"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Corogura/quizmaker/internal/auth"
 	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/Corogura/quizmaker/internal/quizio"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -16,14 +18,11 @@ func (cfg *apiConfig) handlerQuizzesCreate(c *gin.Context) {
 	type parameters struct {
 		Title string `json:"title"`
 	}
-	bearer, err := auth.GetBearerToken(c.Request.Header)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header"})
+	userID, ok := cfg.authorize(c, auth.ScopeWriteQuizzes)
+	if !ok {
 		return
 	}
-	userID, err := auth.ValidateJWT(bearer, cfg.jwtSecret)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+	if !cfg.checkQuizQuota(c, userID.String()) {
 		return
 	}
 	var params parameters
@@ -33,7 +32,7 @@ func (cfg *apiConfig) handlerQuizzesCreate(c *gin.Context) {
 	}
 	quizID := uuid.New().String()
 	path := generatePath()
-	err = cfg.db.CreateQuiz(c.Request.Context(), database.CreateQuizParams{
+	err := cfg.db.CreateQuiz(c.Request.Context(), database.CreateQuizParams{
 		ID:        quizID,
 		CreatedAt: time.Now().UTC().Format(time.RFC3339),
 		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
@@ -63,14 +62,8 @@ func (cfg *apiConfig) handlerQuestionsCreate(c *gin.Context) {
 		c.JSON(http.StatusGone, gin.H{"error": "Quiz has been deleted"})
 		return
 	}
-	bearer, err := auth.GetBearerToken(c.Request.Header)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header"})
-		return
-	}
-	userID, err := auth.ValidateJWT(bearer, cfg.jwtSecret)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+	userID, ok := cfg.authorize(c, auth.ScopeWriteQuizzes)
+	if !ok {
 		return
 	}
 	if quiz.UserID != userID.String() {
@@ -82,30 +75,20 @@ func (cfg *apiConfig) handlerQuestionsCreate(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve question count"})
 		return
 	}
-	type parameters struct {
-		Question string `json:"question"`
-		Choice1  string `json:"choice1"`
-		Choice2  string `json:"choice2"`
-		Choice3  string `json:"choice3"`
-		Choice4  string `json:"choice4"`
-		Answer   int64  `json:"answer"`
+	if !cfg.checkQuestionQuota(c, userID.String(), questionCount, 1) {
+		return
 	}
-	var params parameters
+	var params questionParameters
 	if err := c.ShouldBindJSON(&params); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
 		return
 	}
-	err = cfg.db.CreateQuizQuestions(c.Request.Context(), database.CreateQuizQuestionsParams{
-		ID:             uuid.New().String(),
-		QuizID:         quiz.ID,
-		QuestionNumber: questionCount + 1, // Increment the question number
-		QuestionText:   params.Question,
-		Choice1:        params.Choice1,
-		Choice2:        params.Choice2,
-		Choice3:        params.Choice3,
-		Choice4:        params.Choice4,
-		Answer:         params.Answer,
-	})
+	question := params.question()
+	if err := question.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = createQuestion(c.Request.Context(), cfg.db, quiz.ID, questionCount+1, question)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create question"})
 		return
@@ -113,6 +96,145 @@ func (cfg *apiConfig) handlerQuestionsCreate(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"message": "Question created successfully"})
 }
 
+func (cfg *apiConfig) handlerQuestionsUpdate(c *gin.Context) {
+	questionNumber, err := strconv.Atoi(c.Param("question_number"))
+	if err != nil || questionNumber <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question number"})
+		return
+	}
+	userID, ok := cfg.authorize(c, auth.ScopeWriteQuizzes)
+	if !ok {
+		return
+	}
+	quiz, err := cfg.db.GetQuizIDFromPath(c.Request.Context(), c.Param("path"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+	if quiz.DeletedAt.Valid {
+		c.JSON(http.StatusGone, gin.H{"error": "Quiz has been deleted"})
+		return
+	}
+	if quiz.UserID != userID.String() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to update this question"})
+		return
+	}
+	existing, err := cfg.db.GetQuestionFromQuestionNumber(c.Request.Context(), database.GetQuestionFromQuestionNumberParams{
+		QuestionNumber: int64(questionNumber),
+		QuizID:         quiz.ID,
+	})
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve question"})
+		return
+	}
+	if existing.DeletedAt.Valid {
+		c.JSON(http.StatusGone, gin.H{"error": "Question has been deleted"})
+		return
+	}
+	var params questionParameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parameters"})
+		return
+	}
+	q := params.question()
+	if err := q.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = cfg.db.UpdateQuizQuestion(c.Request.Context(), database.UpdateQuizQuestionParams{
+		ID:           existing.ID,
+		QuestionText: q.Text,
+		Choice1:      q.Choice(1),
+		Choice2:      q.Choice(2),
+		Choice3:      q.Choice(3),
+		Choice4:      q.Choice(4),
+		Answer:       int64(q.Answer),
+		Explanation:  nullString(q.Explanation),
+		QuestionType: string(q.Type),
+		AcceptedAnswers: sql.NullString{
+			String: strings.Join(q.AcceptedAnswers, "\n"),
+			Valid:  q.Type == quizio.TypeShortAnswer,
+		},
+		NumericAnswer: sql.NullFloat64{
+			Float64: q.NumericAnswer,
+			Valid:   q.Type == quizio.TypeNumeric,
+		},
+		NumericTolerance: sql.NullFloat64{
+			Float64: q.Tolerance,
+			Valid:   q.Type == quizio.TypeNumeric,
+		},
+		Choice1Feedback: nullString(q.Feedback(1)),
+		Choice2Feedback: nullString(q.Feedback(2)),
+		Choice3Feedback: nullString(q.Feedback(3)),
+		Choice4Feedback: nullString(q.Feedback(4)),
+		Tags:            strings.Join(q.Tags, "\n"),
+		TimeLimitSeconds: sql.NullInt64{
+			Int64: int64(q.TimeLimit),
+			Valid: q.TimeLimit > 0,
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't update question"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Question updated successfully"})
+}
+
+// questionParameters is the request body for creating and updating a
+// question. Type defaults to multiple choice, and true/false questions get
+// the choices "True" and "False" unless others are given.
+type questionParameters struct {
+	Type            quizio.QuestionType `json:"type"`
+	Question        string              `json:"question"`
+	Choice1         string              `json:"choice1"`
+	Choice2         string              `json:"choice2"`
+	Choice3         string              `json:"choice3"`
+	Choice4         string              `json:"choice4"`
+	Answer          int                 `json:"answer"`
+	AcceptedAnswers []string            `json:"accepted_answers"`
+	NumericAnswer   float64             `json:"numeric_answer"`
+	Tolerance       float64             `json:"tolerance"`
+	Explanation     string              `json:"explanation"`
+	ChoiceFeedback  []string            `json:"choice_feedback"`
+	Tags            []string            `json:"tags"`
+	TimeLimit       int                 `json:"time_limit_seconds"`
+}
+
+func (p questionParameters) question() quizio.Question {
+	q := quizio.Question{
+		Type:        p.Type,
+		Text:        p.Question,
+		Explanation: p.Explanation,
+		Tags:        quizio.NormalizeTags(p.Tags),
+		TimeLimit:   p.TimeLimit,
+	}
+	switch q.Type {
+	case "":
+		q.Type = quizio.TypeMultipleChoice
+		fallthrough
+	case quizio.TypeMultipleChoice:
+		q.Choices = []string{p.Choice1, p.Choice2, p.Choice3, p.Choice4}
+		q.Answer = p.Answer
+		q.ChoiceFeedback = p.ChoiceFeedback
+	case quizio.TypeTrueFalse:
+		q.Choices = []string{"True", "False"}
+		if p.Choice1 != "" || p.Choice2 != "" {
+			q.Choices = []string{p.Choice1, p.Choice2}
+		}
+		q.Answer = p.Answer
+		q.ChoiceFeedback = p.ChoiceFeedback
+	case quizio.TypeShortAnswer:
+		q.AcceptedAnswers = p.AcceptedAnswers
+	case quizio.TypeNumeric:
+		q.NumericAnswer = p.NumericAnswer
+		q.Tolerance = p.Tolerance
+	}
+	return q
+}
+
 func (cfg *apiConfig) handlerQuizzesDelete(c *gin.Context) {
 	path := c.Param("path")
 	if path == "" {
@@ -128,14 +250,8 @@ func (cfg *apiConfig) handlerQuizzesDelete(c *gin.Context) {
 		c.JSON(http.StatusGone, gin.H{"error": "Quiz has already been deleted"})
 		return
 	}
-	bearer, err := auth.GetBearerToken(c.Request.Header)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header"})
-		return
-	}
-	userID, err := auth.ValidateJWT(bearer, cfg.jwtSecret)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+	userID, ok := cfg.authorize(c, auth.ScopeWriteQuizzes)
+	if !ok {
 		return
 	}
 	if quiz.UserID != userID.String() {
@@ -166,14 +282,8 @@ func (cfg *apiConfig) handlerQuestionsDelete(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question number"})
 		return
 	}
-	bearer, err := auth.GetBearerToken(c.Request.Header)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header"})
-		return
-	}
-	userID, err := auth.ValidateJWT(bearer, cfg.jwtSecret)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+	userID, ok := cfg.authorize(c, auth.ScopeWriteQuizzes)
+	if !ok {
 		return
 	}
 	quiz, err := cfg.db.GetQuizIDFromPath(c.Request.Context(), c.Param("path"))
@@ -229,14 +339,8 @@ func (cfg *apiConfig) handlerUpdateQuizTitle(c *gin.Context) {
 		c.JSON(http.StatusGone, gin.H{"error": "Quiz has been deleted"})
 		return
 	}
-	bearer, err := auth.GetBearerToken(c.Request.Header)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header"})
-		return
-	}
-	userID, err := auth.ValidateJWT(bearer, cfg.jwtSecret)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+	userID, ok := cfg.authorize(c, auth.ScopeWriteQuizzes)
+	if !ok {
 		return
 	}
 	if quiz.UserID != userID.String() {
@@ -264,14 +368,8 @@ func (cfg *apiConfig) handlerUpdateQuizTitle(c *gin.Context) {
 }
 
 func (cfg *apiConfig) handlerGetAllQuizzesForUser(c *gin.Context) {
-	bearer, err := auth.GetBearerToken(c.Request.Header)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header"})
-		return
-	}
-	userID, err := auth.ValidateJWT(bearer, cfg.jwtSecret)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+	userID, ok := cfg.authorize(c, auth.ScopeReadQuizzes)
+	if !ok {
 		return
 	}
 	quizzes, err := cfg.db.GetAllQuizzesByUserID(c.Request.Context(), userID.String())
@@ -282,19 +380,11 @@ func (cfg *apiConfig) handlerGetAllQuizzesForUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"quizzes": quizzes})
 }
 
+// handlerGetAllQuestionsInQuiz lists a quiz's questions with their answers,
+// so only its owner may; takers get their questions from an attempt.
 func (cfg *apiConfig) handlerGetAllQuestionsInQuiz(c *gin.Context) {
-	path := c.Param("path")
-	if path == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Path is required"})
-		return
-	}
-	quiz, err := cfg.db.GetQuizIDFromPath(c.Request.Context(), path)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
-		return
-	}
-	if quiz.DeletedAt.Valid {
-		c.JSON(http.StatusGone, gin.H{"error": "Quiz has been deleted"})
+	quiz, ok := cfg.ownedQuiz(c, auth.ScopeReadQuizzes)
+	if !ok {
 		return
 	}
 	questions, err := cfg.db.GetAllQuestionsInQuiz(c.Request.Context(), quiz.ID)
@@ -303,9 +393,12 @@ func (cfg *apiConfig) handlerGetAllQuestionsInQuiz(c *gin.Context) {
 		return
 	}
 	type QuestionWithChoices struct {
-		ID             string `json:"id"`
-		QuestionNumber int64  `json:"question_number"`
-		QuestionText   string `json:"question_text"`
+		ID             string   `json:"id"`
+		QuestionNumber int64    `json:"question_number"`
+		QuestionType   string   `json:"question_type"`
+		QuestionText   string   `json:"question_text"`
+		Tags           []string `json:"tags"`
+		TimeLimit      int      `json:"time_limit_seconds,omitempty"`
 		Choices        []struct {
 			ChoiceText string `json:"choice_text"`
 			IsCorrect  bool   `json:"is_correct"`
@@ -316,22 +409,22 @@ func (cfg *apiConfig) handlerGetAllQuestionsInQuiz(c *gin.Context) {
 		formattedQuestion := QuestionWithChoices{
 			ID:             q.ID,
 			QuestionNumber: q.QuestionNumber,
+			QuestionType:   q.QuestionType,
 			QuestionText:   q.QuestionText,
 			Choices: []struct {
 				ChoiceText string `json:"choice_text"`
 				IsCorrect  bool   `json:"is_correct"`
 			}{},
 		}
-		choices := []struct {
-			ChoiceText string `json:"choice_text"`
-			IsCorrect  bool   `json:"is_correct"`
-		}{
-			{ChoiceText: q.Choice1, IsCorrect: q.Answer == 1},
-			{ChoiceText: q.Choice2, IsCorrect: q.Answer == 2},
-			{ChoiceText: q.Choice3, IsCorrect: q.Answer == 3},
-			{ChoiceText: q.Choice4, IsCorrect: q.Answer == 4},
+		question := questionFromDB(q)
+		formattedQuestion.Tags = question.Tags
+		formattedQuestion.TimeLimit = question.TimeLimit
+		for i, choice := range question.Choices {
+			formattedQuestion.Choices = append(formattedQuestion.Choices, struct {
+				ChoiceText string `json:"choice_text"`
+				IsCorrect  bool   `json:"is_correct"`
+			}{ChoiceText: choice, IsCorrect: question.Answer == i+1})
 		}
-		formattedQuestion.Choices = choices
 		formattedQuestions = append(formattedQuestions, formattedQuestion)
 	}
 	c.JSON(http.StatusOK, gin.H{"questions": formattedQuestions})
@@ -352,8 +445,26 @@ func (cfg *apiConfig) handlerServeQuizPage(c *gin.Context) {
 		c.JSON(http.StatusGone, gin.H{"error": "Quiz has been deleted"})
 		return
 	}
-	c.HTML(http.StatusOK, "quiz.html", gin.H{
-		"title": quiz.Title,
+	settings, err := cfg.db.GetQuizSettings(c.Request.Context(), quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve quiz settings"})
+		return
+	}
+	// The page is still served while the quiz can't be taken, so that its
+	// owner can edit it; it just says why there is nothing to answer.
+	status := http.StatusOK
+	unavailable := ""
+	switch availability(settings, time.Now().UTC()) {
+	case quizNotYetOpen:
+		status = http.StatusForbidden
+		unavailable = "This quiz is not open yet. It opens at " + settings.OpensAt.String + "."
+	case quizClosed:
+		status = http.StatusForbidden
+		unavailable = "This quiz is closed. It closed at " + settings.ClosesAt.String + "."
+	}
+	c.HTML(status, "quiz.html", gin.H{
+		"title":       quiz.Title,
+		"unavailable": unavailable,
 	})
 }
 
@@ -372,14 +483,8 @@ func (cfg *apiConfig) handlerChechOwnerOfQuiz(c *gin.Context) {
 		c.JSON(http.StatusGone, gin.H{"error": "Quiz has been deleted"})
 		return
 	}
-	bearer, err := auth.GetBearerToken(c.Request.Header)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header"})
-		return
-	}
-	userID, err := auth.ValidateJWT(bearer, cfg.jwtSecret)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+	userID, ok := cfg.authorize(c, auth.ScopeReadQuizzes)
+	if !ok {
 		return
 	}
 	if quiz.UserID != userID.String() {
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// handlerGetSessions lists the devices the caller is logged in on. Each
+// login starts a session, which lasts as long as its refresh tokens do.
+func (cfg *apiConfig) handlerGetSessions(c *gin.Context) {
+	session, ok := cfg.callerSession(c)
+	if !ok {
+		return
+	}
+	rows, err := cfg.db.GetUserSessions(c.Request.Context(), database.GetUserSessionsParams{
+		UserID:    session.UserID.String(),
+		ExpiresAt: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't retrieve sessions"})
+		return
+	}
+	sessions := make([]gin.H, 0, len(rows))
+	for _, row := range rows {
+		sessions = append(sessions, gin.H{
+			"id":           row.FamilyID,
+			"created_at":   row.SessionStartedAt,
+			"last_used_at": row.LastUsedAt,
+			"user_agent":   row.UserAgent,
+			"ip":           row.Ip,
+			"current":      row.FamilyID == session.SessionID,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// handlerRevokeSession logs the caller out of one of their sessions,
+// revoking its refresh tokens and the access tokens issued with them.
+func (cfg *apiConfig) handlerRevokeSession(c *gin.Context) {
+	session, ok := cfg.callerSession(c)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	revoked, err := cfg.db.RevokeUserSession(c.Request.Context(), database.RevokeUserSessionParams{
+		UpdatedAt: now.Format(time.RFC3339),
+		RevokedAt: sql.NullString{String: now.Format(time.RFC3339), Valid: true},
+		UserID:    session.UserID.String(),
+		FamilyID:  c.Param("id"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't revoke session"})
+		return
+	}
+	if revoked == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err := cfg.revokeSessionAccessTokens(c.Request.Context(), c.Param("id"), now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't revoke session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// handlerRevokeAllSessions logs the caller out everywhere, including the
+// session the request was made from, and deletes their API keys.
+func (cfg *apiConfig) handlerRevokeAllSessions(c *gin.Context) {
+	session, ok := cfg.callerSession(c)
+	if !ok {
+		return
+	}
+	keysDeleted, err := cfg.revokeOtherAccess(c, session.UserID.String(), "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't revoke sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions", "api_keys_deleted": keysDeleted})
+}
+
+// callerSession returns the user and session of the caller's access token.
+// It writes the error response itself.
+func (cfg *apiConfig) callerSession(c *gin.Context) (auth.Session, bool) {
+	bearerToken, err := auth.GetBearerToken(c.Request.Header)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return auth.Session{}, false
+	}
+	session, err := auth.ValidateSessionJWT(bearerToken, cfg.jwtKeys, cfg.revocations)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return auth.Session{}, false
+	}
+	return session, true
+}
+
+// revokeOtherAccess revokes every session of a user except keep, which
+// may be empty to revoke them all, and deletes their API keys, which would
+// otherwise outlive a password change or a logout everywhere. It returns
+// how many keys were deleted.
+func (cfg *apiConfig) revokeOtherAccess(c *gin.Context, userID, keep string) (int64, error) {
+	now := time.Now().UTC()
+	err := cfg.db.RevokeOtherUserSessions(c.Request.Context(), database.RevokeOtherUserSessionsParams{
+		UpdatedAt: now.Format(time.RFC3339),
+		RevokedAt: sql.NullString{String: now.Format(time.RFC3339), Valid: true},
+		UserID:    userID,
+		FamilyID:  keep,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := cfg.revokeOtherAccessTokens(c.Request.Context(), userID, keep, now); err != nil {
+		return 0, err
+	}
+	return cfg.db.DeleteUserAPIKeys(c.Request.Context(), userID)
+}
+
+// revokeFamily revokes a session: every refresh token in its family and
+// the access tokens issued with them.
+func (cfg *apiConfig) revokeFamily(ctx context.Context, familyID string, now time.Time) error {
+	err := cfg.db.RevokeRefreshTokenFamily(ctx, database.RevokeRefreshTokenFamilyParams{
+		UpdatedAt: now.Format(time.RFC3339),
+		RevokedAt: sql.NullString{String: now.Format(time.RFC3339), Valid: true},
+		FamilyID:  familyID,
+	})
+	if err != nil {
+		return err
+	}
+	return cfg.revokeSessionAccessTokens(ctx, familyID, now)
+}
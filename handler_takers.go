@@ -0,0 +1,94 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// takerTokenDuration is how long a nickname taker can take and resume
+	// attempts before they need to join again.
+	takerTokenDuration = 2 * time.Hour
+	// maxTakerNickname is the maximum length of a nickname in characters.
+	maxTakerNickname = 20
+)
+
+// attemptTaker identifies who takes an attempt: a user, a nickname taker or,
+// when both are empty, an anonymous taker.
+type attemptTaker struct {
+	UserID  sql.NullString
+	TakerID sql.NullString
+}
+
+// handlerTakersCreate lets someone without an account take quizzes under a
+// nickname. The token it returns is sent like an access token.
+func (cfg *apiConfig) handlerTakersCreate(c *gin.Context) {
+	type parameters struct {
+		Nickname string `json:"nickname"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
+		return
+	}
+	nickname := strings.Join(strings.Fields(params.Nickname), " ")
+	if nickname == "" || utf8.RuneCountInString(nickname) > maxTakerNickname {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nickname must be 1 to 20 characters"})
+		return
+	}
+	takerID := uuid.New()
+	now := time.Now().UTC()
+	expiresAt := now.Add(takerTokenDuration)
+	err := cfg.db.CreateTaker(c.Request.Context(), database.CreateTakerParams{
+		ID:        takerID.String(),
+		CreatedAt: now.Format(time.RFC3339),
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+		Nickname:  nickname,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create taker"})
+		return
+	}
+	token, err := auth.MakeTakerJWT(takerID, nickname, cfg.jwtKeys, takerTokenDuration)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create token"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"taker_id":   takerID.String(),
+		"nickname":   nickname,
+		"token":      token,
+		"expires_at": expiresAt.Format(time.RFC3339),
+	})
+}
+
+// callerTaker identifies the caller from an access or taker token. Callers
+// without a token are anonymous, but a token that is sent must be valid so
+// that takers don't silently lose their attempts.
+func (cfg *apiConfig) callerTaker(c *gin.Context) (attemptTaker, bool) {
+	if c.GetHeader("Authorization") == "" {
+		return attemptTaker{}, true
+	}
+	bearer, err := auth.GetBearerToken(c.Request.Header)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header"})
+		return attemptTaker{}, false
+	}
+	if userID, err := auth.ValidateJWT(bearer, cfg.jwtKeys, cfg.revocations); err == nil {
+		return attemptTaker{UserID: sql.NullString{String: userID.String(), Valid: true}}, true
+	}
+	takerID, _, err := auth.ValidateTakerJWT(bearer, cfg.jwtKeys)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return attemptTaker{}, false
+	}
+	return attemptTaker{TakerID: sql.NullString{String: takerID.String(), Valid: true}}, true
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/Corogura/quizmaker/internal/qr"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// loginChallengeDuration is how long a user has to enter their code
+	// after their password.
+	loginChallengeDuration = 5 * time.Minute
+	// totpIssuer names the account in authenticator apps.
+	totpIssuer = "Quiz Maker"
+)
+
+// handlerGetTwoFactor reports whether the caller has two-factor
+// authentication and how many recovery codes they have left.
+func (cfg *apiConfig) handlerGetTwoFactor(c *gin.Context) {
+	user, ok := cfg.currentUser(c)
+	if !ok {
+		return
+	}
+	codes, err := cfg.db.GetUnusedRecoveryCodes(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't get recovery codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  user.TotpEnabledAt.Valid,
+		"recovery_codes_remaining": len(codes),
+	})
+}
+
+// handlerEnrollTOTP starts setting up two-factor authentication with a new
+// secret, for the caller to add to an authenticator app by scanning the QR
+// code or typing the secret. It isn't enabled until confirmed with a code.
+func (cfg *apiConfig) handlerEnrollTOTP(c *gin.Context) {
+	user, ok := cfg.currentUser(c)
+	if !ok {
+		return
+	}
+	if user.GuestExpiresAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Guest accounts can't use two-factor authentication"})
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't generate secret"})
+		return
+	}
+	uri := auth.TOTPURI(totpIssuer, user.Email, secret)
+	code, err := qr.Encode([]byte(uri))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't draw QR code"})
+		return
+	}
+	set, err := cfg.db.SetUserTotpSecret(c.Request.Context(), database.SetUserTotpSecretParams{
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+		UpdatedAt:  time.Now().UTC().Format(time.RFC3339),
+		ID:         user.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't save secret"})
+		return
+	}
+	if set == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"secret":  secret,
+		"uri":     uri,
+		"qr_code": "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(code.SVG())),
+	})
+}
+
+// handlerConfirmTOTP enables two-factor authentication once the caller
+// shows, with a code, that their app has the secret. The response has their
+// recovery codes, which are only ever shown this once. Every other session
+// is logged out and every API key deleted, since they could skip the
+// second factor.
+func (cfg *apiConfig) handlerConfirmTOTP(c *gin.Context) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
+		return
+	}
+	session, ok := cfg.callerSession(c)
+	if !ok {
+		return
+	}
+	user, ok := cfg.activeUser(c, session.UserID.String())
+	if !ok {
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if !user.TotpSecret.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication hasn't been set up"})
+		return
+	}
+	now := time.Now().UTC()
+	step, ok := auth.ValidateTOTP(user.TotpSecret.String, params.Code, now, 0)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	}
+	tx, err := cfg.dbConn.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't start transaction"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	enabled, err := qtx.EnableUserTotp(c.Request.Context(), database.EnableUserTotpParams{
+		TotpEnabledAt: sql.NullString{String: now.Format(time.RFC3339), Valid: true},
+		TotpLastStep:  step,
+		UpdatedAt:     now.Format(time.RFC3339),
+		ID:            user.ID,
+		TotpSecret:    user.TotpSecret,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't enable two-factor authentication"})
+		return
+	}
+	if enabled == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication was set up again; scan the new code"})
+		return
+	}
+	codes, err := replaceRecoveryCodes(c.Request.Context(), qtx, cfg.passwords, user.ID, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create recovery codes"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't enable two-factor authentication"})
+		return
+	}
+	keysDeleted, err := cfg.revokeOtherAccess(c, user.ID, session.SessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't revoke other sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Two-factor authentication enabled",
+		"recovery_codes":   codes,
+		"api_keys_deleted": keysDeleted,
+	})
+}
+
+// handlerDisableTOTP turns two-factor authentication off. It takes the
+// password and a code, so a stolen session alone can't.
+func (cfg *apiConfig) handlerDisableTOTP(c *gin.Context) {
+	type parameters struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
+		return
+	}
+	user, ok := cfg.currentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TotpEnabledAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication isn't enabled"})
+		return
+	}
+	if err := auth.CheckPasswordHash(user.HashedPw, params.Password); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+	if !cfg.useSecondFactor(c, user, params.Code) {
+		return
+	}
+	tx, err := cfg.dbConn.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't start transaction"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	err = qtx.DisableUserTotp(c.Request.Context(), database.DisableUserTotpParams{
+		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+		ID:        user.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't disable two-factor authentication"})
+		return
+	}
+	if err := qtx.DeleteRecoveryCodes(c.Request.Context(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't delete recovery codes"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't disable two-factor authentication"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// handlerRegenerateRecoveryCodes replaces the caller's recovery codes with
+// new ones, for when they have used or lost them.
+func (cfg *apiConfig) handlerRegenerateRecoveryCodes(c *gin.Context) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
+		return
+	}
+	user, ok := cfg.currentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TotpEnabledAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication isn't enabled"})
+		return
+	}
+	if !cfg.useSecondFactor(c, user, params.Code) {
+		return
+	}
+	tx, err := cfg.dbConn.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't start transaction"})
+		return
+	}
+	defer tx.Rollback()
+	codes, err := replaceRecoveryCodes(c.Request.Context(), cfg.db.WithTx(tx), cfg.passwords, user.ID, time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create recovery codes"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create recovery codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// handlerLoginTwoFactor is the second step of logging in with two-factor
+// authentication: it trades the token given for the password, along with a
+// TOTP or recovery code, for an access token. Each login token allows one
+// try, so guessing codes means entering the password again each time.
+func (cfg *apiConfig) handlerLoginTwoFactor(c *gin.Context) {
+	type parameters struct {
+		LoginToken string `json:"login_token"`
+		Code       string `json:"code"`
+	}
+	var params parameters
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
+		return
+	}
+	token, err := auth.ValidateSingleUseJWT(auth.TokenTypeLoginChallenge, params.LoginToken, cfg.jwtKeys)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, log in again"})
+		return
+	}
+	user, err := cfg.db.GetUser(c.Request.Context(), token.UserID.String())
+	if err != nil || user.Email != token.Email || !user.TotpEnabledAt.Valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, log in again"})
+		return
+	}
+	now := time.Now().UTC()
+	attempt, ok := cfg.startLoginAttempt(c, user.Email, now)
+	if !ok {
+		return
+	}
+	used, err := cfg.db.UseToken(c.Request.Context(), database.UseTokenParams{
+		ID:        token.TokenID,
+		RevokedAt: now.Format(time.RFC3339),
+		ExpiresAt: token.ExpiresAt.Format(time.RFC3339),
+	})
+	if err != nil {
+		cfg.refundLoginAttempt(c.Request.Context(), attempt)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't use token"})
+		return
+	}
+	if used == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, log in again"})
+		return
+	}
+	// Wrong codes count as failed logins too, or a guesser who has the
+	// password could try a code with each login.
+	if !cfg.useSecondFactor(c, user, params.Code) {
+		if err := cfg.loginFailed(c.Request.Context(), attempt, nullString(user.ID)); err != nil {
+			log.Printf("Couldn't record failed login: %v", err)
+		}
+		return
+	}
+	cfg.refundLoginAttempt(c.Request.Context(), attempt)
+	cfg.startSession(c, user)
+}
+
+// useSecondFactor checks a TOTP code or recovery code of a user and marks
+// it as used. It writes the error response itself.
+func (cfg *apiConfig) useSecondFactor(c *gin.Context, user database.User, code string) bool {
+	if step, ok := auth.ValidateTOTP(user.TotpSecret.String, code, time.Now().UTC(), user.TotpLastStep); ok {
+		used, err := cfg.db.UseUserTotpStep(c.Request.Context(), database.UseUserTotpStepParams{
+			TotpLastStep:   step,
+			ID:             user.ID,
+			TotpLastStep_2: step,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't use code"})
+			return false
+		}
+		if used == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Code has already been used"})
+			return false
+		}
+		return true
+	}
+	recoveryCode := auth.NormalizeRecoveryCode(code)
+	codes, err := cfg.db.GetUnusedRecoveryCodes(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't get recovery codes"})
+		return false
+	}
+	for _, rc := range codes {
+		if auth.CheckPasswordHash(rc.HashedCode, recoveryCode) != nil {
+			continue
+		}
+		used, err := cfg.db.UseRecoveryCode(c.Request.Context(), database.UseRecoveryCodeParams{
+			UsedAt: sql.NullString{String: time.Now().UTC().Format(time.RFC3339), Valid: true},
+			ID:     rc.ID,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't use code"})
+			return false
+		}
+		if used == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Code has already been used"})
+			return false
+		}
+		return true
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+	return false
+}
+
+// replaceRecoveryCodes gives a user a new set of recovery codes in place of
+// any they had, storing them hashed like passwords, and returns them.
+func replaceRecoveryCodes(ctx context.Context, qtx *database.Queries, hasher auth.PasswordHasher, userID string, now time.Time) ([]string, error) {
+	if err := qtx.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+	codes := auth.GenerateRecoveryCodes()
+	for _, code := range codes {
+		hashed, err := hasher.Hash(code)
+		if err != nil {
+			return nil, err
+		}
+		err = qtx.CreateRecoveryCode(ctx, database.CreateRecoveryCodeParams{
+			ID:         uuid.New().String(),
+			UserID:     userID,
+			HashedCode: hashed,
+			CreatedAt:  now.Format(time.RFC3339),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
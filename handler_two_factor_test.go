@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+)
+
+// enableTestTOTP turns on two-factor authentication for a user and returns
+// the secret.
+func enableTestTOTP(t *testing.T, cfg *apiConfig, user database.User) string {
+	t.Helper()
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err = cfg.db.SetUserTotpSecret(t.Context(), database.SetUserTotpSecretParams{
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+		UpdatedAt:  now,
+		ID:         user.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cfg.db.EnableUserTotp(t.Context(), database.EnableUserTotpParams{
+		TotpEnabledAt: sql.NullString{String: now, Valid: true},
+		UpdatedAt:     now,
+		ID:            user.ID,
+		TotpSecret:    sql.NullString{String: secret, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+// loginChallenge logs in with the password of a user with two-factor
+// authentication and returns the login token.
+func loginChallenge(t *testing.T, h http.Handler, email, password string) string {
+	t.Helper()
+	status, body := doRequest(t, h, http.MethodPost, "/users/login", "", map[string]string{
+		"email":    email,
+		"password": password,
+	})
+	if status != http.StatusOK || body["two_factor_required"] != true {
+		t.Fatalf("login: status %d, body %v, want a two-factor challenge", status, body)
+	}
+	if _, ok := body["token"]; ok {
+		t.Fatal("login: got an access token before the second factor")
+	}
+	loginToken, _ := body["login_token"].(string)
+	return loginToken
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := createTestUser(t, cfg, "alice@example.com", "correct horse battery")
+	secret := enableTestTOTP(t, cfg, user)
+	r := newSessionRouter(cfg)
+	r.POST("/users/login/2fa", cfg.handlerLoginTwoFactor)
+
+	code, err := auth.TOTPCode(secret, auth.TOTPStep(time.Now().UTC()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loginToken := loginChallenge(t, r, "alice@example.com", "correct horse battery")
+	status, body := doRequest(t, r, http.MethodPost, "/users/login/2fa", "", map[string]string{
+		"login_token": loginToken,
+		"code":        code,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("second step: status %d, body %v", status, body)
+	}
+	accessToken, _ := body["token"].(string)
+	if status, body := doRequest(t, r, http.MethodGet, "/users/validate", accessToken, nil); status != http.StatusOK {
+		t.Fatalf("validate: status %d, body %v", status, body)
+	}
+
+	// Each login token and each code work once.
+	status, body = doRequest(t, r, http.MethodPost, "/users/login/2fa", "", map[string]string{
+		"login_token": loginToken,
+		"code":        code,
+	})
+	if status != http.StatusUnauthorized {
+		t.Fatalf("reused login token: status %d, body %v, want %d", status, body, http.StatusUnauthorized)
+	}
+	status, body = doRequest(t, r, http.MethodPost, "/users/login/2fa", "", map[string]string{
+		"login_token": loginChallenge(t, r, "alice@example.com", "correct horse battery"),
+		"code":        code,
+	})
+	if status != http.StatusUnauthorized {
+		t.Fatalf("reused code: status %d, body %v, want %d", status, body, http.StatusUnauthorized)
+	}
+}
+
+func TestLoginTwoFactorWrongCode(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := createTestUser(t, cfg, "alice@example.com", "correct horse battery")
+	enableTestTOTP(t, cfg, user)
+	r := newSessionRouter(cfg)
+	r.POST("/users/login/2fa", cfg.handlerLoginTwoFactor)
+
+	loginToken := loginChallenge(t, r, "alice@example.com", "correct horse battery")
+	status, body := doRequest(t, r, http.MethodPost, "/users/login/2fa", "", map[string]string{
+		"login_token": loginToken,
+		"code":        "not a code",
+	})
+	if status != http.StatusUnauthorized {
+		t.Fatalf("wrong code: status %d, body %v, want %d", status, body, http.StatusUnauthorized)
+	}
+	if _, ok := body["token"]; ok {
+		t.Fatal("wrong code: got an access token")
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"log"
 	"net/http"
 	"time"
 
@@ -21,14 +23,18 @@ func (cfg *apiConfig) handlerUsersCreate(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
 		return
 	}
+	if !validEmail(params.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+		return
+	}
 
-	hashedPassword, err := auth.HashPassword(params.Password)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't hash password"})
+	hashedPassword, ok := cfg.hashNewPassword(c, params.Password)
+	if !ok {
 		return
 	}
-	err = cfg.db.CreateUser(c.Request.Context(), database.CreateUserParams{
-		ID:        uuid.New().String(),
+	userID := uuid.New().String()
+	err := cfg.db.CreateUser(c.Request.Context(), database.CreateUserParams{
+		ID:        userID,
 		CreatedAt: time.Now().UTC().Format(time.RFC3339),
 		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
 		Email:     params.Email,
@@ -38,6 +44,10 @@ func (cfg *apiConfig) handlerUsersCreate(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create user"})
 		return
 	}
+	// The account works without verifying; the link can be sent again.
+	if err := cfg.sendVerificationEmail(c.Request.Context(), userID, params.Email); err != nil {
+		log.Printf("Couldn't send verification email: %v", err)
+	}
 	c.JSON(http.StatusCreated, gin.H{})
 }
 
@@ -51,43 +61,132 @@ func (cfg *apiConfig) handlerUsersLogin(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't decode parameters"})
 		return
 	}
+	attempt, ok := cfg.startLoginAttempt(c, params.Email, time.Now().UTC())
+	if !ok {
+		return
+	}
+	// Logging in to an account that doesn't exist fails the same way as
+	// with a wrong password, and takes as long, so as not to tell which
+	// email addresses have accounts.
 	user, err := cfg.db.GetUserByEmail(c.Request.Context(), params.Email)
-	if err != nil {
+	found := err == nil
+	hashedPw := user.HashedPw
+	if !found {
+		hashedPw = cfg.dummyPasswordHash
+	}
+	if err := auth.CheckPasswordHash(hashedPw, params.Password); err != nil || !found {
+		if err := cfg.loginFailed(c.Request.Context(), attempt, nullString(user.ID)); err != nil {
+			log.Printf("Couldn't record failed login: %v", err)
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
-	if err := auth.CheckPasswordHash(user.HashedPw, params.Password); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+	cfg.refundLoginAttempt(c.Request.Context(), attempt)
+	// The password is only known now, so this is when a hash made with
+	// an old algorithm or settings can be replaced.
+	if cfg.passwords.NeedsRehash(user.HashedPw) {
+		cfg.rehashPassword(c.Request.Context(), user, params.Password)
+	}
+	cfg.completeLogin(c, user)
+}
+
+// rehashPassword replaces the stored hash of a user's password with one
+// made by the current hasher. Logging in works either way, so failing to is
+// only logged.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, user database.User, password string) {
+	hashedPassword, err := cfg.passwords.Hash(password)
+	if err == nil {
+		err = cfg.db.RehashUserPassword(ctx, database.RehashUserPasswordParams{
+			HashedPw:   hashedPassword,
+			ID:         user.ID,
+			HashedPw_2: user.HashedPw,
+		})
+	}
+	if err != nil {
+		log.Printf("Couldn't rehash password: %v", err)
+	}
+}
+
+// hashNewPassword checks that a password a user chose follows the password
+// policy, and hashes it. It writes the error response itself.
+func (cfg *apiConfig) hashNewPassword(c *gin.Context, password string) (string, bool) {
+	if err := cfg.passwordPolicy.Check(password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	hashedPassword, err := cfg.passwords.Hash(password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't hash password"})
+		return "", false
+	}
+	return hashedPassword, true
+}
+
+// completeLogin logs in a user who has proved who they are with a password
+// or an identity provider. Users with two-factor authentication get a login
+// token to trade for a session along with their code; others get a session.
+func (cfg *apiConfig) completeLogin(c *gin.Context, user database.User) {
+	if user.TotpEnabledAt.Valid {
+		challenge, err := auth.MakeSingleUseJWT(auth.TokenTypeLoginChallenge, uuid.MustParse(user.ID), user.Email, cfg.jwtKeys, loginChallengeDuration)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"login_token":         challenge,
+			"expires_in":          int(loginChallengeDuration.Seconds()),
+		})
 		return
 	}
-	token, err := auth.MakeJWT(uuid.MustParse(user.ID), cfg.jwtSecret, 24*time.Hour)
+	cfg.startSession(c, user)
+}
+
+// startSession logs a user in on a new session, responding with its access
+// and refresh tokens. Their failed logins are forgotten.
+func (cfg *apiConfig) startSession(c *gin.Context, user database.User) {
+	cfg.clearLoginFailures(c.Request.Context(), user.Email)
+	sessionID := uuid.New().String()
+	token, access, err := auth.MakeJWT(uuid.MustParse(user.ID), sessionID, cfg.jwtKeys, cfg.accessTokenTTL)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create token"})
 		return
 	}
 	rt, _ := auth.MakeRefreshToken()
 	rToken, err := cfg.db.CreateRefreshToken(c.Request.Context(), database.CreateRefreshTokenParams{
-		Token:     rt,
-		UserID:    user.ID,
-		CreatedAt: time.Now().UTC().Format(time.RFC3339),
-		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
-		ExpiresAt: time.Now().UTC().Add(720 * time.Hour).Format(time.RFC3339),
+		Token:            rt,
+		UserID:           user.ID,
+		CreatedAt:        time.Now().UTC().Format(time.RFC3339),
+		UpdatedAt:        time.Now().UTC().Format(time.RFC3339),
+		ExpiresAt:        time.Now().UTC().Add(cfg.refreshTokenTTL).Format(time.RFC3339),
+		FamilyID:         sessionID,
+		SessionStartedAt: time.Now().UTC().Format(time.RFC3339),
+		UserAgent:        c.Request.UserAgent(),
+		Ip:               c.ClientIP(),
+		AccessTokenID:    access.TokenID,
+		AccessExpiresAt:  access.ExpiresAt.Format(time.RFC3339),
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create refresh token"})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"id":            user.ID,
-		"email":         user.Email,
-		"created_at":    user.CreatedAt,
-		"updated_at":    user.UpdatedAt,
-		"token":         token,
-		"refresh_token": rToken.Token,
-		"expires_in":    720 * 60 * 60, // 720 hours in seconds
+		"id":             user.ID,
+		"email":          user.Email,
+		"email_verified": user.EmailVerifiedAt.Valid,
+		"created_at":     user.CreatedAt,
+		"updated_at":     user.UpdatedAt,
+		"token":          token,
+		"refresh_token":  rToken.Token,
+		"expires_in":     int(cfg.accessTokenTTL.Seconds()),
 	})
 }
 
+// handlerRefreshJWT exchanges a refresh token for a new access token and a
+// new refresh token. Each refresh token can be used once; the tokens that
+// descend from one login form a family, and presenting a token that has
+// already been used revokes the whole family, since either the holder or a
+// thief is replaying it.
 func (cfg *apiConfig) handlerRefreshJWT(c *gin.Context) {
 	tokenString, err := auth.GetBearerToken(c.Request.Header)
 	if err != nil {
@@ -99,32 +198,112 @@ func (cfg *apiConfig) handlerRefreshJWT(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 		return
 	}
-	accessToken, err := auth.MakeJWT(uuid.MustParse(dbUser.ID), cfg.jwtSecret, 24*time.Hour)
+	now := time.Now().UTC()
+	if dbUser.TokenRevokedAt.Valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has been revoked"})
+		return
+	}
+	if dbUser.TokenUsedAt.Valid {
+		cfg.revokeReusedFamily(c, dbUser.TokenFamilyID, now)
+		return
+	}
+	expiresAt, err := time.Parse(time.RFC3339, dbUser.TokenExpiresAt)
+	if err != nil || !now.Before(expiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has expired"})
+		return
+	}
+	if guestExpired(dbUser.GuestExpiresAt, now) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Guest account has expired"})
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't start transaction"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	used, err := qtx.UseRefreshToken(c.Request.Context(), database.UseRefreshTokenParams{
+		UpdatedAt: now.Format(time.RFC3339),
+		UsedAt:    sql.NullString{String: now.Format(time.RFC3339), Valid: true},
+		Token:     tokenString,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't use refresh token"})
+		return
+	}
+	if used == 0 {
+		// Another request used the token first.
+		tx.Rollback()
+		cfg.revokeReusedFamily(c, dbUser.TokenFamilyID, now)
+		return
+	}
+	newExpiresAt := now.Add(cfg.refreshTokenTTL)
+	if dbUser.GuestExpiresAt.Valid {
+		if guestExpiresAt, err := time.Parse(time.RFC3339, dbUser.GuestExpiresAt.String); err == nil && guestExpiresAt.Before(newExpiresAt) {
+			newExpiresAt = guestExpiresAt
+		}
+	}
+	accessToken, access, err := auth.MakeJWT(uuid.MustParse(dbUser.ID), dbUser.TokenFamilyID, cfg.jwtKeys, cfg.accessTokenTTL)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create access token"})
 		return
 	}
+	rt, _ := auth.MakeRefreshToken()
+	rToken, err := qtx.CreateRefreshToken(c.Request.Context(), database.CreateRefreshTokenParams{
+		Token:            rt,
+		UserID:           dbUser.ID,
+		CreatedAt:        now.Format(time.RFC3339),
+		UpdatedAt:        now.Format(time.RFC3339),
+		ExpiresAt:        newExpiresAt.Format(time.RFC3339),
+		FamilyID:         dbUser.TokenFamilyID,
+		SessionStartedAt: dbUser.TokenSessionStartedAt,
+		UserAgent:        c.Request.UserAgent(),
+		Ip:               c.ClientIP(),
+		AccessTokenID:    access.TokenID,
+		AccessExpiresAt:  access.ExpiresAt.Format(time.RFC3339),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create refresh token"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create refresh token"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"token":      accessToken,
-		"expires_in": 24 * 60 * 60, // 24 hours in seconds
+		"token":         accessToken,
+		"refresh_token": rToken.Token,
+		"expires_in":    int(cfg.accessTokenTTL.Seconds()),
 	})
 }
 
+// revokeReusedFamily revokes every refresh token in a family, and the
+// access tokens issued with them, after one of them was presented again,
+// and tells the caller to log in again.
+func (cfg *apiConfig) revokeReusedFamily(c *gin.Context, familyID string, now time.Time) {
+	if err := cfg.revokeFamily(c.Request.Context(), familyID, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't revoke refresh tokens"})
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has already been used; please log in again"})
+}
+
+// handlerRevokeRefreshToken logs out the session the refresh token belongs
+// to by revoking its whole family and the access tokens issued with it.
 func (cfg *apiConfig) handlerRevokeRefreshToken(c *gin.Context) {
 	tokenString, err := auth.GetBearerToken(c.Request.Header)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 		return
 	}
-	err = cfg.db.RevokeRefreshToken(c.Request.Context(), database.RevokeRefreshTokenParams{
-		Token:     tokenString,
-		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
-		RevokedAt: sql.NullString{
-			String: time.Now().UTC().Format(time.RFC3339),
-			Valid:  true,
-		},
-	})
+	dbUser, err := cfg.db.GetUserFromRefreshToken(c.Request.Context(), tokenString)
 	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if err := cfg.revokeFamily(c.Request.Context(), dbUser.TokenFamilyID, time.Now().UTC()); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't revoke refresh token"})
 		return
 	}
@@ -146,11 +325,12 @@ func (cfg *apiConfig) handlerUpdatePassword(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 		return
 	}
-	userID, err := auth.ValidateJWT(bearerToken, cfg.jwtSecret)
+	session, err := auth.ValidateSessionJWT(bearerToken, cfg.jwtKeys, cfg.revocations)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
+	userID := session.UserID
 	user, err := cfg.db.GetUser(c.Request.Context(), userID.String())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't find user"})
@@ -161,9 +341,8 @@ func (cfg *apiConfig) handlerUpdatePassword(c *gin.Context) {
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(params.NewPassword)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't hash password"})
+	hashedPassword, ok := cfg.hashNewPassword(c, params.NewPassword)
+	if !ok {
 		return
 	}
 
@@ -176,7 +355,14 @@ func (cfg *apiConfig) handlerUpdatePassword(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't update password"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
+	// Whoever knew the old password is logged out of every other device,
+	// and any API key they made is deleted.
+	keysDeleted, err := cfg.revokeOtherAccess(c, userID.String(), session.SessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't revoke other sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully", "api_keys_deleted": keysDeleted})
 }
 
 func (cfg *apiConfig) handlerValidateJWT(c *gin.Context) {
@@ -185,7 +371,7 @@ func (cfg *apiConfig) handlerValidateJWT(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 		return
 	}
-	_, err = auth.ValidateJWT(bearerToken, cfg.jwtSecret)
+	_, err = auth.ValidateJWT(bearerToken, cfg.jwtKeys, cfg.revocations)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
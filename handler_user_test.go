@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// newSessionRouter routes the handlers for logging in and refreshing.
+func newSessionRouter(cfg *apiConfig) *gin.Engine {
+	r := gin.New()
+	r.POST("/users/login", cfg.handlerUsersLogin)
+	r.GET("/users/refresh", cfg.handlerRefreshJWT)
+	r.GET("/users/validate", cfg.handlerValidateJWT)
+	return r
+}
+
+// login logs in with the email and password and returns the access and
+// refresh tokens.
+func login(t *testing.T, h http.Handler, email, password string) (string, string) {
+	t.Helper()
+	status, body := doRequest(t, h, http.MethodPost, "/users/login", "", map[string]string{
+		"email":    email,
+		"password": password,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("login: status %d, body %v", status, body)
+	}
+	token, _ := body["token"].(string)
+	refreshToken, _ := body["refresh_token"].(string)
+	if token == "" || refreshToken == "" {
+		t.Fatalf("login: missing tokens in %v", body)
+	}
+	return token, refreshToken
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	cfg := newTestConfig(t)
+	createTestUser(t, cfg, "alice@example.com", "correct horse battery")
+	r := newSessionRouter(cfg)
+	_, refreshToken := login(t, r, "alice@example.com", "correct horse battery")
+
+	status, body := doRequest(t, r, http.MethodGet, "/users/refresh", refreshToken, nil)
+	if status != http.StatusOK {
+		t.Fatalf("refresh: status %d, body %v", status, body)
+	}
+	newToken, _ := body["refresh_token"].(string)
+	if newToken == "" || newToken == refreshToken {
+		t.Fatalf("refresh: got refresh token %q, want a new one", newToken)
+	}
+	accessToken, _ := body["token"].(string)
+	if status, body := doRequest(t, r, http.MethodGet, "/users/validate", accessToken, nil); status != http.StatusOK {
+		t.Fatalf("validate: status %d, body %v", status, body)
+	}
+
+	status, body = doRequest(t, r, http.MethodGet, "/users/refresh", newToken, nil)
+	if status != http.StatusOK {
+		t.Fatalf("second refresh: status %d, body %v", status, body)
+	}
+}
+
+func TestRefreshUsedTokenUnauthorized(t *testing.T) {
+	cfg := newTestConfig(t)
+	createTestUser(t, cfg, "alice@example.com", "correct horse battery")
+	r := newSessionRouter(cfg)
+	_, refreshToken := login(t, r, "alice@example.com", "correct horse battery")
+
+	if status, body := doRequest(t, r, http.MethodGet, "/users/refresh", refreshToken, nil); status != http.StatusOK {
+		t.Fatalf("refresh: status %d, body %v", status, body)
+	}
+	if status, body := doRequest(t, r, http.MethodGet, "/users/refresh", refreshToken, nil); status != http.StatusUnauthorized {
+		t.Fatalf("reused refresh: status %d, body %v, want %d", status, body, http.StatusUnauthorized)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	cfg := newTestConfig(t)
+	createTestUser(t, cfg, "alice@example.com", "correct horse battery")
+	r := newSessionRouter(cfg)
+	_, stolen := login(t, r, "alice@example.com", "correct horse battery")
+
+	status, body := doRequest(t, r, http.MethodGet, "/users/refresh", stolen, nil)
+	if status != http.StatusOK {
+		t.Fatalf("refresh: status %d, body %v", status, body)
+	}
+	current, _ := body["refresh_token"].(string)
+	accessToken, _ := body["token"].(string)
+
+	if status, body := doRequest(t, r, http.MethodGet, "/users/refresh", stolen, nil); status != http.StatusUnauthorized {
+		t.Fatalf("reused refresh: status %d, body %v, want %d", status, body, http.StatusUnauthorized)
+	}
+	// The token issued in exchange for the reused one, and the access
+	// token issued with it, are revoked with the rest of the family.
+	if status, body := doRequest(t, r, http.MethodGet, "/users/refresh", current, nil); status != http.StatusUnauthorized {
+		t.Fatalf("refresh after reuse: status %d, body %v, want %d", status, body, http.StatusUnauthorized)
+	}
+	if status, body := doRequest(t, r, http.MethodGet, "/users/validate", accessToken, nil); status != http.StatusUnauthorized {
+		t.Fatalf("validate after reuse: status %d, body %v, want %d", status, body, http.StatusUnauthorized)
+	}
+
+	// Other sessions are left alone.
+	_, other := login(t, r, "alice@example.com", "correct horse battery")
+	if status, body := doRequest(t, r, http.MethodGet, "/users/refresh", other, nil); status != http.StatusOK {
+		t.Fatalf("refresh in another session: status %d, body %v", status, body)
+	}
+}
+
+func TestUpdatePasswordDeletesAPIKeys(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := createTestUser(t, cfg, "alice@example.com", "correct horse battery")
+	r := newSessionRouter(cfg)
+	r.PUT("/users/password", cfg.handlerUpdatePassword)
+	err := cfg.db.CreateAPIKey(t.Context(), database.CreateAPIKeyParams{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		Name:      "script",
+		HashedKey: "hash",
+		Scopes:    auth.ScopeReadQuizzes,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _ := login(t, r, "alice@example.com", "correct horse battery")
+
+	status, body := doRequest(t, r, http.MethodPut, "/users/password", token, map[string]string{
+		"current_password": "correct horse battery",
+		"new_password":     "a brand new password",
+	})
+	if status != http.StatusOK || body["api_keys_deleted"] != float64(1) {
+		t.Fatalf("update password: status %d, body %v", status, body)
+	}
+	count, err := cfg.db.CountUserAPIKeys(t.Context(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("user has %d API keys after changing their password, want 0", count)
+	}
+}
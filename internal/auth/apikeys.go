@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Scopes an API key can be given. Access tokens can do everything.
+const (
+	ScopeReadQuizzes  = "quizzes:read"
+	ScopeWriteQuizzes = "quizzes:write"
+	ScopeReadResults  = "results:read"
+)
+
+// Scopes are all the scopes, in the order they are shown in.
+var Scopes = []string{ScopeReadQuizzes, ScopeWriteQuizzes, ScopeReadResults}
+
+// apiKeyPrefix starts every API key, so they can be told apart from access
+// tokens and found by secret scanners.
+const apiKeyPrefix = "qm_"
+
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// GenerateAPIKey returns a new API key and its ID. The key is
+// qm_<id>_<secret>: the ID finds the key's row, so it is shown to the user
+// to tell keys apart, and the 128-bit secret is only stored hashed.
+func GenerateAPIKey() (key, id string) {
+	id = strings.ToLower(rand.Text()[:8])
+	return apiKeyPrefix + id + "_" + strings.ToLower(rand.Text()), id
+}
+
+// IsAPIKey reports whether a bearer token is an API key rather than a JWT.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// ParseAPIKey returns the ID of an API key.
+func ParseAPIKey(key string) (string, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(key, apiKeyPrefix), "_")
+	if !IsAPIKey(key) || !ok || len(id) != 8 || secret == "" {
+		return "", ErrInvalidAPIKey
+	}
+	return id, nil
+}
+
+// HashAPIKey returns the hash an API key is stored as. Unlike passwords, keys
+// are random and long enough that a fast hash can't be brute-forced, which
+// matters because one is checked on every request.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAPIKeyHash reports whether a key matches its stored hash.
+func CheckAPIKeyHash(hash, key string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(HashAPIKey(key))) == 1
+}
+
+// ValidScope reports whether scope is one of Scopes.
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAPIKey(t *testing.T) {
+	key, id := GenerateAPIKey()
+	if !IsAPIKey(key) || !strings.HasPrefix(key, "qm_"+id+"_") {
+		t.Fatalf("GenerateAPIKey() = %q, %q", key, id)
+	}
+	parsed, err := ParseAPIKey(key)
+	if err != nil || parsed != id {
+		t.Errorf("ParseAPIKey() = %q, %v, want %q", parsed, err, id)
+	}
+	hash := HashAPIKey(key)
+	if !CheckAPIKeyHash(hash, key) {
+		t.Error("CheckAPIKeyHash() rejected the key")
+	}
+	other, _ := GenerateAPIKey()
+	if CheckAPIKeyHash(hash, other) {
+		t.Error("CheckAPIKeyHash() accepted another key")
+	}
+}
+
+func TestParseAPIKey(t *testing.T) {
+	for _, key := range []string{
+		"",
+		"eyJhbGciOiJIUzI1NiJ9.e30.sig",
+		"qm_",
+		"qm_abcd1234",
+		"qm_abcd1234_",
+		"qm_abc_secret",
+		"xx_abcd1234_secret",
+	} {
+		if _, err := ParseAPIKey(key); err == nil {
+			t.Errorf("ParseAPIKey(%q) accepted an invalid key", key)
+		}
+	}
+}
+
+func TestValidScope(t *testing.T) {
+	for _, scope := range Scopes {
+		if !ValidScope(scope) {
+			t.Errorf("ValidScope(%q) = false", scope)
+		}
+	}
+	if ValidScope("admin") {
+		t.Error(`ValidScope("admin") = true`)
+	}
+}
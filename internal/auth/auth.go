@@ -2,6 +2,8 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -11,68 +13,285 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type TokenType string
 
 const (
 	TokenTypeAccess TokenType = "quizmaker-access"
+	// TokenTypeTaker identifies tokens given to takers who join a quiz with
+	// a nickname instead of an account.
+	TokenTypeTaker TokenType = "quizmaker-taker"
+	// TokenTypeVerifyEmail and TokenTypeResetPassword identify the tokens
+	// emailed to users to verify their address and reset their password.
+	TokenTypeVerifyEmail   TokenType = "quizmaker-verify-email"
+	TokenTypeResetPassword TokenType = "quizmaker-reset-password"
+	// TokenTypeLoginChallenge identifies the tokens given for a correct
+	// password to users with two-factor authentication, which they trade
+	// for an access token along with a code.
+	TokenTypeLoginChallenge TokenType = "quizmaker-login-challenge"
+	// TokenTypeOIDCFlow identifies the tokens that hold what is needed to
+	// finish logging in with an identity provider, kept in a cookie while
+	// the user is at the provider.
+	TokenTypeOIDCFlow TokenType = "quizmaker-oidc-flow"
+	// TokenTypeOIDCLogin identifies the tokens given to users who have
+	// logged in with an identity provider, which they trade for a session.
+	TokenTypeOIDCLogin TokenType = "quizmaker-oidc-login"
 )
 
-func HashPassword(password string) (string, error) {
-	bytePW, err := bcrypt.GenerateFromPassword([]byte(password), 5)
-	if err != nil {
-		return "", err
-	}
-	return string(bytePW), nil
+// takerClaims are the claims of a taker token. The subject is the taker ID.
+type takerClaims struct {
+	Nickname string `json:"nickname"`
+	jwt.RegisteredClaims
+}
+
+// accessClaims are the claims of an access token. The subject is the user
+// ID, sid the session, that is the refresh token family, it was issued for,
+// and jti the ID it can be revoked by.
+type accessClaims struct {
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
 }
 
-func CheckPasswordHash(hash, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+// Session identifies an access token: the user and session it was issued
+// for, its ID and when it expires.
+type Session struct {
+	UserID    uuid.UUID
+	SessionID string
+	TokenID   string
+	ExpiresAt time.Time
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    string(TokenTypeAccess),
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
+// MakeJWT issues an access token and returns it along with the Session it
+// identifies, so the caller can record its ID.
+func MakeJWT(userID uuid.UUID, sessionID string, keys *KeySet, expiresIn time.Duration) (string, Session, error) {
+	now := time.Now().UTC()
+	session := Session{
+		UserID:    userID,
+		SessionID: sessionID,
+		TokenID:   uuid.New().String(),
+		// JWT times are whole seconds.
+		ExpiresAt: now.Add(expiresIn).Truncate(time.Second),
+	}
+	signedString, err := keys.sign(accessClaims{
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeAccess),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
+			Subject:   userID.String(),
+			ID:        session.TokenID,
+		},
 	})
-	signedString, err := token.SignedString([]byte(tokenSecret))
 	if err != nil {
-		return "", err
+		return "", Session{}, err
+	}
+	return signedString, session, nil
+}
+
+// ValidateJWT returns the user ID of an access token. Tokens in revoked are
+// rejected; a nil revoked skips the check.
+func ValidateJWT(tokenString string, keys *KeySet, revoked *Revocations) (uuid.UUID, error) {
+	session, err := ValidateSessionJWT(tokenString, keys, revoked)
+	if err != nil {
+		return uuid.Nil, err
 	}
-	return signedString, nil
+	return session.UserID, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimsStruct := jwt.RegisteredClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, &claimsStruct, func(token *jwt.Token) (any, error) {
-		return []byte(tokenSecret), nil
-	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+// ValidateSessionJWT is ValidateJWT for callers that also need the session
+// the token was issued for.
+func ValidateSessionJWT(tokenString string, keys *KeySet, revoked *Revocations) (Session, error) {
+	claims := accessClaims{}
+	token, err := keys.parse(tokenString, &claims)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("parsing failed: %v", err)
+		return Session{}, fmt.Errorf("parsing failed: %v", err)
 	}
 
 	idString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return Session{}, err
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		return uuid.Nil, err
+		return Session{}, err
 	}
 	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
+		return Session{}, errors.New("invalid issuer")
 	}
 
 	parsedID, err := uuid.Parse(idString)
 	if err != nil {
-		return uuid.Nil, err
+		return Session{}, err
+	}
+	if revoked != nil {
+		// Tokens from before revocation existed have no ID and can't be
+		// revoked; they are not accepted any more.
+		if claims.ID == "" {
+			return Session{}, errors.New("token has no ID")
+		}
+		isRevoked, err := revoked.IsRevoked(claims.ID)
+		if err != nil {
+			return Session{}, err
+		}
+		if isRevoked {
+			return Session{}, ErrTokenRevoked
+		}
+	}
+	session := Session{UserID: parsedID, SessionID: claims.SessionID, TokenID: claims.ID}
+	if claims.ExpiresAt != nil {
+		session.ExpiresAt = claims.ExpiresAt.Time.UTC()
+	}
+	return session, nil
+}
+
+// MakeTakerJWT issues a token for a nickname taker, which lets them take
+// and resume attempts without an account.
+func MakeTakerJWT(takerID uuid.UUID, nickname string, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return keys.sign(takerClaims{
+		Nickname: nickname,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeTaker),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   takerID.String(),
+		},
+	})
+}
+
+// ValidateTakerJWT returns the taker ID and nickname of a taker token.
+// Access tokens are rejected, as are taker tokens where access tokens are
+// expected.
+func ValidateTakerJWT(tokenString string, keys *KeySet) (uuid.UUID, string, error) {
+	claims := takerClaims{}
+	_, err := keys.parse(tokenString, &claims, jwt.WithIssuer(string(TokenTypeTaker)))
+	if err != nil {
+		return uuid.Nil, "", fmt.Errorf("parsing failed: %v", err)
+	}
+	takerID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return takerID, claims.Nickname, nil
+}
+
+// singleUseClaims are the claims of a token that works once, such as one
+// sent by email. The subject is the user ID and email the address of the
+// account when it was issued. Password reset tokens also hold the
+// fingerprint of the password they replace.
+type singleUseClaims struct {
+	Email    string `json:"email"`
+	Password string `json:"pwd,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// SingleUseToken is what a single use token identifies. Its ID is how the
+// token is marked as used, so it works only once. Password is the
+// PasswordFingerprint a reset token was issued for.
+type SingleUseToken struct {
+	UserID    uuid.UUID
+	Email     string
+	Password  string
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+// MakeSingleUseJWT issues a token of the type, which must be one of the
+// single use token types, for a user with the email address.
+func MakeSingleUseJWT(tokenType TokenType, userID uuid.UUID, email string, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return makeSingleUseJWT(tokenType, userID, email, "", keys, expiresIn)
+}
+
+// MakePasswordResetJWT issues a password reset token for a user, bound to
+// the hash of their current password so that it stops working once the
+// password changes.
+func MakePasswordResetJWT(userID uuid.UUID, email, passwordHash string, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return makeSingleUseJWT(TokenTypeResetPassword, userID, email, PasswordFingerprint(passwordHash), keys, expiresIn)
+}
+
+func makeSingleUseJWT(tokenType TokenType, userID uuid.UUID, email, password string, keys *KeySet, expiresIn time.Duration) (string, error) {
+	now := time.Now().UTC()
+	return keys.sign(singleUseClaims{
+		Email:    email,
+		Password: password,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(tokenType),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			Subject:   userID.String(),
+			ID:        uuid.New().String(),
+		},
+	})
+}
+
+// ValidateSingleUseJWT returns what a token of the type identifies. Tokens of
+// other types are rejected.
+func ValidateSingleUseJWT(tokenType TokenType, tokenString string, keys *KeySet) (SingleUseToken, error) {
+	claims := singleUseClaims{}
+	_, err := keys.parse(tokenString, &claims, jwt.WithIssuer(string(tokenType)), jwt.WithExpirationRequired())
+	if err != nil {
+		return SingleUseToken{}, fmt.Errorf("parsing failed: %v", err)
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return SingleUseToken{}, err
+	}
+	if claims.ID == "" {
+		return SingleUseToken{}, errors.New("token has no ID")
+	}
+	return SingleUseToken{
+		UserID:    userID,
+		Email:     claims.Email,
+		Password:  claims.Password,
+		TokenID:   claims.ID,
+		ExpiresAt: claims.ExpiresAt.Time.UTC(),
+	}, nil
+}
+
+// PasswordFingerprint identifies a password hash without revealing it, as
+// tokens can be read by whoever holds them.
+func PasswordFingerprint(passwordHash string) string {
+	sum := sha256.Sum256([]byte(passwordHash))
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
+
+// oidcFlowClaims are the claims of an OIDC flow token. The ID is the state
+// sent to the provider.
+type oidcFlowClaims struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.RegisteredClaims
+}
+
+// MakeOIDCFlowJWT issues a token holding the state, nonce and PKCE verifier
+// of a login with an identity provider.
+func MakeOIDCFlowJWT(state, nonce, verifier string, keys *KeySet, expiresIn time.Duration) (string, error) {
+	now := time.Now().UTC()
+	return keys.sign(oidcFlowClaims{
+		Nonce:    nonce,
+		Verifier: verifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeOIDCFlow),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			ID:        state,
+		},
+	})
+}
+
+// ValidateOIDCFlowJWT returns the state, nonce and PKCE verifier of an OIDC
+// flow token.
+func ValidateOIDCFlowJWT(tokenString string, keys *KeySet) (state, nonce, verifier string, err error) {
+	claims := oidcFlowClaims{}
+	_, err = keys.parse(tokenString, &claims, jwt.WithIssuer(string(TokenTypeOIDCFlow)), jwt.WithExpirationRequired())
+	if err != nil {
+		return "", "", "", fmt.Errorf("parsing failed: %v", err)
+	}
+	if claims.ID == "" || claims.Nonce == "" || claims.Verifier == "" {
+		return "", "", "", errors.New("incomplete flow")
 	}
-	return parsedID, nil
+	return claims.ID, claims.Nonce, claims.Verifier, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -1,69 +1,76 @@
 package auth
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-func TestHashPassword(t *testing.T) {
-	_, err := HashPassword("test")
+// testKeySet returns an HS256 key set for a secret, padded to the length
+// secrets must have.
+func testKeySet(t *testing.T, secret string) *KeySet {
+	t.Helper()
+	key, err := HMACKey([]byte(secret + strings.Repeat("-", MinSecretLength)))
 	if err != nil {
-		t.Error("error")
-		return
+		t.Fatal(err)
 	}
-}
-
-func TestCheckPasswordHash(t *testing.T) {
-	hashedPW, err := HashPassword("password")
+	keys, err := NewKeySet(key)
 	if err != nil {
-		t.Error("error")
-	}
-	err = CheckPasswordHash(hashedPW, "password")
-	if err != nil {
-		t.Error("check failed")
+		t.Fatal(err)
 	}
+	return keys
 }
 
 func TestMakeJWT(t *testing.T) {
 	userID := uuid.New()
 	tokenDuration, _ := time.ParseDuration("12h")
-	_, err := MakeJWT(userID, "test", tokenDuration)
+	_, _, err := MakeJWT(userID, "", testKeySet(t, "test"), tokenDuration)
 	if err != nil {
 		t.Error("MakeJWT Failed")
 	}
 }
 
 func TestValidateJWT(t *testing.T) {
+	keys := testKeySet(t, "secret")
+	otherKeys := testKeySet(t, "wrong_secret")
 	userID := uuid.New()
-	validToken, _ := MakeJWT(userID, "secret", time.Hour)
+	validToken, _, _ := MakeJWT(userID, "", keys, time.Hour)
+	takerToken, _ := MakeTakerJWT(uuid.New(), "alice", keys, time.Hour)
 
 	tests := []struct {
 		name        string
 		tokenString string
-		tokenSecret string
+		keys        *KeySet
 		wantUserID  uuid.UUID
 		wantErr     bool
 	}{
 		{
 			name:        "Valid token",
 			tokenString: validToken,
-			tokenSecret: "secret",
+			keys:        keys,
 			wantUserID:  userID,
 			wantErr:     false,
 		},
 		{
 			name:        "Invalid token",
 			tokenString: "invalid.token.string",
-			tokenSecret: "secret",
+			keys:        keys,
 			wantUserID:  uuid.Nil,
 			wantErr:     true,
 		},
 		{
 			name:        "Wrong secret",
 			tokenString: validToken,
-			tokenSecret: "wrong_secret",
+			keys:        otherKeys,
+			wantUserID:  uuid.Nil,
+			wantErr:     true,
+		},
+		{
+			name:        "Taker token",
+			tokenString: takerToken,
+			keys:        keys,
 			wantUserID:  uuid.Nil,
 			wantErr:     true,
 		},
@@ -71,7 +78,7 @@ func TestValidateJWT(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotUserID, err := ValidateJWT(tt.tokenString, tt.tokenSecret)
+			gotUserID, err := ValidateJWT(tt.tokenString, tt.keys, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ValidateJWT() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -82,3 +89,138 @@ func TestValidateJWT(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateSessionJWT(t *testing.T) {
+	keys := testKeySet(t, "secret")
+	userID := uuid.New()
+	token, made, _ := MakeJWT(userID, "family", keys, time.Hour)
+	session, err := ValidateSessionJWT(token, keys, nil)
+	if err != nil {
+		t.Fatalf("ValidateSessionJWT() error = %v", err)
+	}
+	if session != made || session.UserID != userID || session.SessionID != "family" || session.TokenID == "" {
+		t.Errorf("ValidateSessionJWT() = %+v, want %+v", session, made)
+	}
+}
+
+func TestValidateTakerJWT(t *testing.T) {
+	keys := testKeySet(t, "secret")
+	otherKeys := testKeySet(t, "wrong_secret")
+	takerID := uuid.New()
+	validToken, _ := MakeTakerJWT(takerID, "alice", keys, time.Hour)
+	expiredToken, _ := MakeTakerJWT(takerID, "alice", keys, -time.Minute)
+	accessToken, _, _ := MakeJWT(uuid.New(), "", keys, time.Hour)
+
+	tests := []struct {
+		name         string
+		tokenString  string
+		keys         *KeySet
+		wantTakerID  uuid.UUID
+		wantNickname string
+		wantErr      bool
+	}{
+		{
+			name:         "Valid token",
+			tokenString:  validToken,
+			keys:         keys,
+			wantTakerID:  takerID,
+			wantNickname: "alice",
+		},
+		{
+			name:        "Wrong secret",
+			tokenString: validToken,
+			keys:        otherKeys,
+			wantErr:     true,
+		},
+		{
+			name:        "Expired token",
+			tokenString: expiredToken,
+			keys:        keys,
+			wantErr:     true,
+		},
+		{
+			name:        "Access token",
+			tokenString: accessToken,
+			keys:        keys,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTakerID, gotNickname, err := ValidateTakerJWT(tt.tokenString, tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTakerJWT() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotTakerID != tt.wantTakerID || gotNickname != tt.wantNickname {
+				t.Errorf("ValidateTakerJWT() = %v, %q, want %v, %q", gotTakerID, gotNickname, tt.wantTakerID, tt.wantNickname)
+			}
+		})
+	}
+}
+
+func TestValidateSingleUseJWT(t *testing.T) {
+	keys := testKeySet(t, "secret")
+	userID := uuid.New()
+	resetToken, _ := MakeSingleUseJWT(TokenTypeResetPassword, userID, "alice@example.com", keys, time.Hour)
+	expiredToken, _ := MakeSingleUseJWT(TokenTypeResetPassword, userID, "alice@example.com", keys, -time.Minute)
+	accessToken, _, _ := MakeJWT(userID, "", keys, time.Hour)
+
+	got, err := ValidateSingleUseJWT(TokenTypeResetPassword, resetToken, keys)
+	if err != nil {
+		t.Fatalf("ValidateSingleUseJWT() error = %v", err)
+	}
+	if got.UserID != userID || got.Email != "alice@example.com" || got.TokenID == "" {
+		t.Errorf("ValidateSingleUseJWT() = %+v", got)
+	}
+	for name, token := range map[string]string{"expired": expiredToken, "access": accessToken} {
+		if _, err := ValidateSingleUseJWT(TokenTypeResetPassword, token, keys); err == nil {
+			t.Errorf("ValidateSingleUseJWT() accepted the %s token", name)
+		}
+	}
+	if _, err := ValidateSingleUseJWT(TokenTypeVerifyEmail, resetToken, keys); err == nil {
+		t.Error("ValidateSingleUseJWT() accepted a reset token for verification")
+	}
+	if _, err := ValidateJWT(resetToken, keys, nil); err == nil {
+		t.Error("ValidateJWT() accepted a reset token")
+	}
+}
+
+func TestMakePasswordResetJWT(t *testing.T) {
+	keys := testKeySet(t, "secret")
+	token, err := MakePasswordResetJWT(uuid.New(), "alice@example.com", "$2a$10$hash", keys, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ValidateSingleUseJWT(TokenTypeResetPassword, token, keys)
+	if err != nil {
+		t.Fatalf("ValidateSingleUseJWT() error = %v", err)
+	}
+	if got.Password != PasswordFingerprint("$2a$10$hash") || got.Password == "$2a$10$hash" {
+		t.Errorf("Password = %q, want the fingerprint of the hash", got.Password)
+	}
+	if got.Password == PasswordFingerprint("$2a$10$other") {
+		t.Error("different hashes have the same fingerprint")
+	}
+}
+
+func TestValidateOIDCFlowJWT(t *testing.T) {
+	keys := testKeySet(t, "secret")
+	token, err := MakeOIDCFlowJWT("state", "nonce", "verifier", keys, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, nonce, verifier, err := ValidateOIDCFlowJWT(token, keys)
+	if err != nil || state != "state" || nonce != "nonce" || verifier != "verifier" {
+		t.Errorf("ValidateOIDCFlowJWT() = %q, %q, %q, %v", state, nonce, verifier, err)
+	}
+	expired, _ := MakeOIDCFlowJWT("state", "nonce", "verifier", keys, -time.Minute)
+	if _, _, _, err := ValidateOIDCFlowJWT(expired, keys); err == nil {
+		t.Error("ValidateOIDCFlowJWT() accepted an expired token")
+	}
+	login, _ := MakeSingleUseJWT(TokenTypeOIDCLogin, uuid.New(), "alice@example.com", keys, time.Minute)
+	if _, _, _, err := ValidateOIDCFlowJWT(login, keys); err == nil {
+		t.Error("ValidateOIDCFlowJWT() accepted a login token")
+	}
+}
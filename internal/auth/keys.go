@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// MinSecretLength is the shortest HS256 secret accepted, the size of
+	// the hash as RFC 7518 requires.
+	MinSecretLength = 32
+	// MinRSABits is the smallest RSA key accepted.
+	MinRSABits = 2048
+)
+
+// Key is a key tokens are signed or verified with. Its ID is sent in the
+// kid header of the tokens it signs.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// signKey is nil for keys that can only verify.
+	signKey   any
+	verifyKey any
+}
+
+// HMACKey returns an HS256 key for a shared secret.
+func HMACKey(secret []byte) (Key, error) {
+	if len(secret) < MinSecretLength {
+		return Key{}, fmt.Errorf("secret must be at least %d bytes", MinSecretLength)
+	}
+	// The ID is derived from the secret without giving it away, so every
+	// instance sharing the secret agrees on it.
+	sum := sha256.Sum256(secret)
+	return Key{
+		ID:        "hs256-" + base64.RawURLEncoding.EncodeToString(sum[:12]),
+		Method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}, nil
+}
+
+// ParsePEMKey parses an Ed25519 or RSA key in PEM form. Private keys, in
+// PKCS #8 or PKCS #1, can sign; public keys, in PKIX, can only verify.
+func ParsePEMKey(data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, errors.New("no PEM data found")
+	}
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return Key{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	switch k := parsed.(type) {
+	case ed25519.PrivateKey:
+		return ed25519Key(k, k.Public().(ed25519.PublicKey)), nil
+	case ed25519.PublicKey:
+		return ed25519Key(nil, k), nil
+	case *rsa.PrivateKey:
+		return rsaKey(k, &k.PublicKey)
+	case *rsa.PublicKey:
+		return rsaKey(nil, k)
+	default:
+		return Key{}, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+func ed25519Key(private ed25519.PrivateKey, public ed25519.PublicKey) Key {
+	key := Key{Method: jwt.SigningMethodEdDSA, verifyKey: public}
+	if private != nil {
+		key.signKey = private
+	}
+	jwk, _ := key.jwk()
+	key.ID = thumbprint(jwk)
+	return key
+}
+
+func rsaKey(private *rsa.PrivateKey, public *rsa.PublicKey) (Key, error) {
+	if public.N.BitLen() < MinRSABits {
+		return Key{}, fmt.Errorf("RSA keys must be at least %d bits", MinRSABits)
+	}
+	key := Key{Method: jwt.SigningMethodRS256, verifyKey: public}
+	if private != nil {
+		key.signKey = private
+	}
+	jwk, _ := key.jwk()
+	key.ID = thumbprint(jwk)
+	return key, nil
+}
+
+// CanSign reports whether the key can sign tokens as well as verify them.
+func (k Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// JWK is a public key in JSON Web Key form.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// Curve and X are the members of Ed25519 keys.
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	// N and E are the members of RSA keys.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+// JWKS is a set of public keys in the form served to other services so
+// they can verify tokens.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// jwk returns the public part of an asymmetric key. HMAC keys are secret
+// and have none.
+func (k Key) jwk() (JWK, bool) {
+	jwk := JWK{KeyID: k.ID, Use: "sig", Algorithm: k.Method.Alg()}
+	switch public := k.verifyKey.(type) {
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
+
+// thumbprint is the RFC 7638 thumbprint of a public key: the hash of its
+// required members, in order, with no whitespace.
+func thumbprint(jwk JWK) string {
+	var members any
+	if jwk.KeyType == "RSA" {
+		members = struct {
+			E       string `json:"e"`
+			KeyType string `json:"kty"`
+			N       string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	} else {
+		members = struct {
+			Curve   string `json:"crv"`
+			KeyType string `json:"kty"`
+			X       string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	}
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// KeySet is the keys tokens are signed and verified with. One key signs;
+// the others are still accepted, so keys can be rotated without logging
+// everyone out: the new key signs while the old one verifies the tokens
+// it signed until they expire.
+type KeySet struct {
+	signing Key
+	keys    map[string]Key
+}
+
+// NewKeySet returns a key set that signs with signing and also verifies
+// tokens signed with others.
+func NewKeySet(signing Key, others ...Key) (*KeySet, error) {
+	if !signing.CanSign() {
+		return nil, errors.New("signing key is a public key")
+	}
+	ks := &KeySet{signing: signing, keys: map[string]Key{signing.ID: signing}}
+	for _, k := range others {
+		if _, ok := ks.keys[k.ID]; ok {
+			return nil, fmt.Errorf("key %s is given twice", k.ID)
+		}
+		ks.keys[k.ID] = k
+	}
+	return ks, nil
+}
+
+// JWKS returns the public keys of the set, the signing key first. HMAC
+// keys are left out.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, k := range ks.keys {
+		if jwk, ok := k.jwk(); ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		a, b := jwks.Keys[i].KeyID, jwks.Keys[j].KeyID
+		if a == ks.signing.ID || b == ks.signing.ID {
+			return a == ks.signing.ID
+		}
+		return a < b
+	})
+	return jwks
+}
+
+// sign signs claims with the signing key.
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.Method, claims)
+	token.Header["kid"] = ks.signing.ID
+	return token.SignedString(ks.signing.signKey)
+}
+
+// parse verifies a token with the key its kid names and decodes its claims.
+// The algorithm must be the key's, so a public key can't be passed off as
+// an HMAC secret.
+func (ks *KeySet) parse(tokenString string, claims jwt.Claims, options ...jwt.ParserOption) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		k, ok := ks.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		if token.Method.Alg() != k.Method.Alg() {
+			return nil, fmt.Errorf("key %s is not for %s", kid, token.Method.Alg())
+		}
+		return k.verifyKey, nil
+	}, append(options, jwt.WithValidMethods(ks.methods()))...)
+}
+
+func (ks *KeySet) methods() []string {
+	var methods []string
+	seen := map[string]bool{}
+	for _, k := range ks.keys {
+		if !seen[k.Method.Alg()] {
+			seen[k.Method.Alg()] = true
+			methods = append(methods, k.Method.Alg())
+		}
+	}
+	return methods
+}
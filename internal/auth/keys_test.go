@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func pemKey(t *testing.T, blockType string, der []byte, err error) Key {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParsePEMKey(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}))
+	if err != nil {
+		t.Fatalf("ParsePEMKey(%s) error = %v", blockType, err)
+	}
+	return key
+}
+
+func TestParsePEMKey(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	edPrivate := pemKey(t, "PRIVATE KEY", der, err)
+	der, err = x509.MarshalPKIXPublicKey(public)
+	edPublic := pemKey(t, "PUBLIC KEY", der, err)
+	if !edPrivate.CanSign() || edPublic.CanSign() {
+		t.Error("only the private key should be able to sign")
+	}
+	if edPrivate.ID != edPublic.ID || edPrivate.Method != jwt.SigningMethodEdDSA {
+		t.Errorf("Ed25519 keys = %s %s and %s, want one ID and EdDSA", edPrivate.ID, edPrivate.Method.Alg(), edPublic.ID)
+	}
+
+	rsaPrivate, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaKey := pemKey(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaPrivate), nil)
+	if !rsaKey.CanSign() || rsaKey.Method != jwt.SigningMethodRS256 {
+		t.Errorf("RSA key = %+v, want an RS256 signing key", rsaKey)
+	}
+
+	weak, _ := rsa.GenerateKey(rand.Reader, 1024)
+	if _, err := ParsePEMKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(weak)})); err == nil {
+		t.Error("ParsePEMKey() accepted a 1024-bit RSA key")
+	}
+	if _, err := ParsePEMKey([]byte("not a key")); err == nil {
+		t.Error("ParsePEMKey() accepted data that isn't PEM")
+	}
+	if _, err := HMACKey([]byte("short")); err == nil {
+		t.Error("HMACKey() accepted a short secret")
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	_, oldPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	der, err := x509.MarshalPKCS8PrivateKey(oldPrivate)
+	oldKey := pemKey(t, "PRIVATE KEY", der, err)
+	rsaPrivate, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey := pemKey(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaPrivate), nil)
+
+	before, _ := NewKeySet(oldKey)
+	after, err := NewKeySet(newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID := uuid.New()
+	oldToken, _, _ := MakeJWT(userID, "", before, time.Hour)
+	newToken, _, _ := MakeJWT(userID, "", after, time.Hour)
+
+	if got, err := ValidateJWT(oldToken, after, nil); err != nil || got != userID {
+		t.Errorf("token signed with the old key: %v, %v", got, err)
+	}
+	if got, err := ValidateJWT(newToken, after, nil); err != nil || got != userID {
+		t.Errorf("token signed with the new key: %v, %v", got, err)
+	}
+	if _, err := ValidateJWT(newToken, before, nil); err == nil {
+		t.Error("token signed with a key not in the set was accepted")
+	}
+
+	jwks := after.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].KeyID != newKey.ID || jwks.Keys[0].KeyType != "RSA" || jwks.Keys[1].KeyType != "OKP" {
+		t.Errorf("JWKS() = %+v, want the RSA signing key then the Ed25519 key", jwks)
+	}
+	if hmacKeys := testKeySet(t, "secret").JWKS(); len(hmacKeys.Keys) != 0 {
+		t.Errorf("JWKS() published HMAC keys: %+v", hmacKeys)
+	}
+}
+
+func TestKeySetRejectsAlgorithmConfusion(t *testing.T) {
+	rsaPrivate, _ := rsa.GenerateKey(rand.Reader, 2048)
+	key := pemKey(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaPrivate), nil)
+	keys, _ := NewKeySet(key)
+
+	// A token signed with HS256 using the public key as the secret, claiming
+	// the RSA key's ID.
+	publicDER, _ := x509.MarshalPKIXPublicKey(&rsaPrivate.PublicKey)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeAccess),
+			Subject:   uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	forged.Header["kid"] = key.ID
+	tokenString, _ := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	if _, err := ValidateJWT(tokenString, keys, nil); err == nil {
+		t.Error("ValidateJWT() accepted an HS256 token for an RSA key")
+	}
+}
@@ -0,0 +1,36 @@
+package auth
+
+import "time"
+
+// LockoutPolicy decides how long logins are refused after failed attempts.
+// The first FreeAttempts failures cost nothing. Each one after that locks
+// logins out for BaseLockout, doubling with every further failure up to
+// MaxLockout, so guessing slows down exponentially without locking out for
+// long a user who mistypes their password a few times. Failures are
+// forgotten once there have been none for Window.
+type LockoutPolicy struct {
+	FreeAttempts int
+	BaseLockout  time.Duration
+	MaxLockout   time.Duration
+	Window       time.Duration
+}
+
+// Lockout returns how long logins are refused after the given number of
+// consecutive failures, or 0 if they aren't.
+func (p LockoutPolicy) Lockout(failures int64) time.Duration {
+	over := failures - int64(p.FreeAttempts)
+	if over <= 0 {
+		return 0
+	}
+	lockout := p.BaseLockout
+	for i := int64(1); i < over && lockout < p.MaxLockout; i++ {
+		lockout *= 2
+	}
+	return min(lockout, p.MaxLockout)
+}
+
+// Expired reports whether failures last made at lastFailedAt are old enough
+// to be forgotten by now.
+func (p LockoutPolicy) Expired(lastFailedAt, now time.Time) bool {
+	return now.Sub(lastFailedAt) >= p.Window
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockout(t *testing.T) {
+	p := LockoutPolicy{
+		FreeAttempts: 3,
+		BaseLockout:  30 * time.Second,
+		MaxLockout:   10 * time.Minute,
+		Window:       time.Hour,
+	}
+	tests := []struct {
+		failures int64
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, 30 * time.Second},
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{8, 8 * time.Minute},
+		{9, 10 * time.Minute},
+		{1000, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := p.Lockout(tt.failures); got != tt.want {
+			t.Errorf("Lockout(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+
+	now := time.Now()
+	if p.Expired(now.Add(-59*time.Minute), now) {
+		t.Error("Expired() forgot a failure inside the window")
+	}
+	if !p.Expired(now.Add(-time.Hour), now) {
+		t.Error("Expired() kept a failure outside the window")
+	}
+}
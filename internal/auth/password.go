@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher hashes passwords for storage. Hashes made by any hasher
+// are checked with CheckPasswordHash, so the hasher, or its settings, can
+// change while users keep logging in with hashes made by the old one.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// NeedsRehash reports whether a hash was made with another algorithm
+	// or other settings, and should be replaced by one made with this
+	// hasher the next time the password is known.
+	NeedsRehash(hash string) bool
+}
+
+// BcryptHasher hashes passwords with bcrypt at Cost. bcrypt only uses the
+// first 72 bytes of a password and refuses longer ones.
+type BcryptHasher struct {
+	Cost int
+}
+
+// BcryptMaxLength is the longest password bcrypt hashes, in bytes.
+const BcryptMaxLength = 72
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+// Argon2idHasher hashes passwords with Argon2id, taking Time passes over
+// Memory KiB with Threads threads. Hashes are stored in the PHC string
+// format, which records the settings along with the salt.
+type Argon2idHasher struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// DefaultArgon2idHasher has the settings OWASP recommends as the minimum:
+// 19 MiB of memory, two passes and one thread.
+var DefaultArgon2idHasher = Argon2idHasher{Time: 2, Memory: 19 * 1024, Threads: 1}
+
+const (
+	argon2idSaltLength = 16
+	argon2idKeyLength  = 32
+)
+
+var phcEncoding = base64.RawStdEncoding
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2idSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, argon2idKeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Time, h.Threads,
+		phcEncoding.EncodeToString(salt), phcEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, key, err := parseArgon2id(hash)
+	return err != nil || params != h || len(key) != argon2idKeyLength
+}
+
+// parseArgon2id splits an Argon2id hash in the PHC string format into its
+// settings, salt and key.
+func parseArgon2id(hash string) (params Argon2idHasher, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return params, nil, nil, errors.New("not an argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported argon2 version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	if params.Time == 0 || params.Threads == 0 {
+		return params, nil, nil, errors.New("invalid argon2id parameters")
+	}
+	if salt, err = phcEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	if key, err = phcEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return params, nil, nil, errors.New("invalid argon2id key")
+	}
+	return params, salt, key, nil
+}
+
+// CheckPasswordHash checks a password against a hash made by any of the
+// hashers, returning nil if it matches.
+func CheckPasswordHash(hash, password string) error {
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	}
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return err
+	}
+	got := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(got, key) != 1 {
+		return errors.New("password doesn't match")
+	}
+	return nil
+}
+
+// commonPasswords are some of the passwords seen most often in breaches.
+//
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// PasswordPolicy decides which passwords users may choose. Passwords must
+// be between MinLength characters and MaxLength bytes long, and not be on
+// the list of breached passwords, which is compared without regard to case.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	breached  map[string]bool
+}
+
+// NewPasswordPolicy returns a policy whose list of breached passwords has
+// the common ones built in, and those read from breached, one per line, if
+// it isn't nil.
+func NewPasswordPolicy(minLength, maxLength int, breached io.Reader) (*PasswordPolicy, error) {
+	p := &PasswordPolicy{MinLength: minLength, MaxLength: maxLength, breached: map[string]bool{}}
+	if err := p.addBreached(strings.NewReader(commonPasswords)); err != nil {
+		return nil, err
+	}
+	if breached != nil {
+		if err := p.addBreached(breached); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (p *PasswordPolicy) addBreached(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if password := strings.TrimSpace(scanner.Text()); password != "" {
+			p.breached[strings.ToLower(password)] = true
+		}
+	}
+	return scanner.Err()
+}
+
+// Check returns an error, meant to be shown to the user, if a password
+// doesn't follow the policy.
+func (p *PasswordPolicy) Check(password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("Password must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		return fmt.Errorf("Password must be at most %d bytes long", p.MaxLength)
+	}
+	if p.breached[strings.ToLower(password)] {
+		return errors.New("This password has appeared in a data breach, please choose another")
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+// testArgon2idHasher is cheap enough to keep the tests fast.
+var testArgon2idHasher = Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}
+
+func TestHashPassword(t *testing.T) {
+	for _, h := range []PasswordHasher{BcryptHasher{Cost: 4}, testArgon2idHasher} {
+		hash, err := h.Hash("password")
+		if err != nil {
+			t.Fatalf("%T.Hash() error: %v", h, err)
+		}
+		if err := CheckPasswordHash(hash, "password"); err != nil {
+			t.Errorf("%T: CheckPasswordHash() rejected the password: %v", h, err)
+		}
+		if err := CheckPasswordHash(hash, "Password"); err == nil {
+			t.Errorf("%T: CheckPasswordHash() accepted another password", h)
+		}
+		if h.NeedsRehash(hash) {
+			t.Errorf("%T.NeedsRehash() = true for its own hash", h)
+		}
+	}
+	other, _ := testArgon2idHasher.Hash("password")
+	if !strings.HasPrefix(other, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Argon2idHasher.Hash() = %s", other)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	weak, _ := BcryptHasher{Cost: 4}.Hash("password")
+	argon, _ := testArgon2idHasher.Hash("password")
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+		hash   string
+		want   bool
+	}{
+		{"bcrypt at a lower cost", BcryptHasher{Cost: 5}, weak, true},
+		{"argon2id to bcrypt", BcryptHasher{Cost: 4}, argon, true},
+		{"bcrypt to argon2id", testArgon2idHasher, weak, true},
+		{"argon2id with more memory", Argon2idHasher{Time: 1, Memory: 2048, Threads: 1}, argon, true},
+		{"empty hash", testArgon2idHasher, "", true},
+	}
+	for _, tt := range tests {
+		if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+			t.Errorf("%s: NeedsRehash() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPasswordHashArgon2id(t *testing.T) {
+	// From the reference implementation's test vectors, with the password
+	// "password" and the salt "somesalt".
+	hash := "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+	if err := CheckPasswordHash(hash, "password"); err != nil {
+		t.Errorf("CheckPasswordHash() rejected the test vector: %v", err)
+	}
+	for _, bad := range []string{
+		"$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ",
+		"$argon2id$v=16$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$m=65536,t=0,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$",
+	} {
+		if err := CheckPasswordHash(bad, "password"); err == nil {
+			t.Errorf("CheckPasswordHash(%q) accepted a malformed hash", bad)
+		}
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	p, err := NewPasswordPolicy(10, BcryptMaxLength, strings.NewReader("correcthorse\n\n  Tr0ub4dor&3  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"short", false},
+		{"ünïcödé-pw", true},
+		{strings.Repeat("x", 73), false},
+		{"a long enough password", true},
+		{"password123", false},
+		{"PASSWORD123", false},
+		{"CorrectHorse", false},
+		{"tr0ub4dor&3", false},
+	}
+	for _, tt := range tests {
+		if err := p.Check(tt.password); (err == nil) != tt.ok {
+			t.Errorf("Check(%q) error = %v, want ok = %v", tt.password, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTokenRevoked is returned when validating an access token that has been
+// revoked.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// RevocationStore keeps the IDs of revoked access tokens, so revocations
+// outlive the process and reach every instance.
+type RevocationStore interface {
+	// RevokeToken records that the token with the ID, which expires at
+	// expiresAt, has been revoked.
+	RevokeToken(ctx context.Context, id string, expiresAt time.Time) error
+	// RevokedTokens returns the expiry of each revoked token, by ID, that
+	// has not expired by now.
+	RevokedTokens(ctx context.Context, now time.Time) (map[string]time.Time, error)
+}
+
+// Revocations is the list of revoked access tokens. It is kept in memory
+// and reloaded from its store once it is older than maxAge, which is how
+// long revocations made by other instances take to apply here.
+type Revocations struct {
+	store  RevocationStore
+	maxAge time.Duration
+
+	mu       sync.Mutex
+	revoked  map[string]time.Time
+	loadedAt time.Time
+}
+
+func NewRevocations(store RevocationStore, maxAge time.Duration) *Revocations {
+	return &Revocations{store: store, maxAge: maxAge}
+}
+
+// Revoke revokes the token with the ID. Only tokens that have not expired
+// need revoking.
+func (r *Revocations) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	if err := r.store.RevokeToken(ctx, id, expiresAt); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.revoked != nil {
+		r.revoked[id] = expiresAt
+	}
+	return nil
+}
+
+// IsRevoked reports whether the token with the ID has been revoked.
+func (r *Revocations) IsRevoked(id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC()
+	if r.revoked == nil || now.Sub(r.loadedAt) >= r.maxAge {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		revoked, err := r.store.RevokedTokens(ctx, now)
+		if err != nil {
+			return false, err
+		}
+		r.revoked = revoked
+		r.loadedAt = now
+	}
+	_, ok := r.revoked[id]
+	return ok, nil
+}
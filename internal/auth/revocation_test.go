@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryStore is a RevocationStore that counts how often it is loaded.
+type memoryStore struct {
+	revoked map[string]time.Time
+	loads   int
+	err     error
+}
+
+func (s *memoryStore) RevokeToken(ctx context.Context, id string, expiresAt time.Time) error {
+	s.revoked[id] = expiresAt
+	return nil
+}
+
+func (s *memoryStore) RevokedTokens(ctx context.Context, now time.Time) (map[string]time.Time, error) {
+	s.loads++
+	if s.err != nil {
+		return nil, s.err
+	}
+	revoked := map[string]time.Time{}
+	for id, expiresAt := range s.revoked {
+		if expiresAt.After(now) {
+			revoked[id] = expiresAt
+		}
+	}
+	return revoked, nil
+}
+
+func TestRevocations(t *testing.T) {
+	store := &memoryStore{revoked: map[string]time.Time{}}
+	revoked := NewRevocations(store, time.Hour)
+	keys := testKeySet(t, "secret")
+	token, session, _ := MakeJWT(uuid.New(), "family", keys, time.Hour)
+	other, _, _ := MakeJWT(uuid.New(), "family", keys, time.Hour)
+
+	if _, err := ValidateJWT(token, keys, revoked); err != nil {
+		t.Fatalf("ValidateJWT() before revoking: %v", err)
+	}
+	if err := revoked.Revoke(context.Background(), session.TokenID, session.ExpiresAt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateJWT(token, keys, revoked); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("ValidateJWT() after revoking: error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := ValidateJWT(other, keys, revoked); err != nil {
+		t.Errorf("ValidateJWT() of another token: %v", err)
+	}
+	if store.loads != 1 {
+		t.Errorf("store loaded %d times, want once", store.loads)
+	}
+
+	// Another instance revokes the other token; it applies once the list is
+	// reloaded.
+	_, otherSession, _ := MakeJWT(uuid.New(), "", keys, time.Hour)
+	store.revoked[otherSession.TokenID] = otherSession.ExpiresAt
+	if isRevoked, _ := revoked.IsRevoked(otherSession.TokenID); isRevoked {
+		t.Error("revocation from the store applied before reloading")
+	}
+	fresh := NewRevocations(store, time.Hour)
+	if isRevoked, _ := fresh.IsRevoked(otherSession.TokenID); !isRevoked {
+		t.Error("revocation from the store not loaded")
+	}
+
+	store.err = errors.New("store unavailable")
+	if _, err := ValidateJWT(token, keys, NewRevocations(store, time.Hour)); err == nil {
+		t.Error("ValidateJWT() succeeded without the revocation list")
+	}
+}
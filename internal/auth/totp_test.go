@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 secret of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	// The last 6 digits of the 8 digit codes in RFC 6238 appendix B.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.code {
+			t.Errorf("TOTPCode() at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := TOTPStep(now)
+	previous, _ := TOTPCode(rfcSecret, step-1)
+	current, _ := TOTPCode(rfcSecret, step)
+	stale, _ := TOTPCode(rfcSecret, step-2)
+
+	if got, ok := ValidateTOTP(rfcSecret, current, now, 0); !ok || got != step {
+		t.Errorf("ValidateTOTP(current) = %d, %v", got, ok)
+	}
+	if got, ok := ValidateTOTP(rfcSecret, previous, now, 0); !ok || got != step-1 {
+		t.Errorf("ValidateTOTP(previous) = %d, %v", got, ok)
+	}
+	if _, ok := ValidateTOTP(rfcSecret, stale, now, 0); ok {
+		t.Error("ValidateTOTP() accepted a code two steps old")
+	}
+	if _, ok := ValidateTOTP(rfcSecret, current, now, step); ok {
+		t.Error("ValidateTOTP() accepted a code that was already used")
+	}
+	wrong := current[:5] + string('0'+(current[5]-'0'+1)%10)
+	if _, ok := ValidateTOTP(rfcSecret, wrong, now, 0); ok && wrong != previous {
+		t.Error("ValidateTOTP() accepted a wrong code")
+	}
+	if _, ok := ValidateTOTP(rfcSecret, current[:3]+" "+current[3:], now, 0); !ok {
+		t.Error("ValidateTOTP() rejected a code with a space")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("Quiz Maker", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Quiz Maker:alice@example.com" {
+		t.Errorf("TOTPURI() = %s", uri)
+	}
+	if q := u.Query(); q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "Quiz Maker" || q.Get("digits") != "6" {
+		t.Errorf("TOTPURI() query = %v", q)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes := GenerateRecoveryCodes()
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("GenerateRecoveryCodes() returned %d codes", len(codes))
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' || seen[code] {
+			t.Errorf("GenerateRecoveryCodes() returned %q", code)
+		}
+		seen[code] = true
+		typed := " " + strings.ToUpper(strings.ReplaceAll(code, "-", "")) + " "
+		if got := NormalizeRecoveryCode(typed); got != code {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", typed, got, code)
+		}
+	}
+}
@@ -8,42 +8,182 @@ import (
 	"database/sql"
 )
 
-type Quiz struct {
+type ApiKey struct {
+	ID         string         `json:"id"`
+	UserID     string         `json:"user_id"`
+	Name       string         `json:"name"`
+	HashedKey  string         `json:"hashed_key"`
+	Scopes     string         `json:"scopes"`
+	CreatedAt  string         `json:"created_at"`
+	ExpiresAt  sql.NullString `json:"expires_at"`
+	LastUsedAt sql.NullString `json:"last_used_at"`
+}
+
+type Attempt struct {
+	ID             string         `json:"id"`
+	CreatedAt      string         `json:"created_at"`
+	UpdatedAt      string         `json:"updated_at"`
+	QuizID         string         `json:"quiz_id"`
+	UserID         sql.NullString `json:"user_id"`
+	Seed           int64          `json:"seed"`
+	QuestionOrder  string         `json:"question_order"`
+	ShuffleChoices bool           `json:"shuffle_choices"`
+	FinishedAt     sql.NullString `json:"finished_at"`
+	Score          sql.NullInt64  `json:"score"`
+	Sections       string         `json:"sections"`
+	Deadline       sql.NullString `json:"deadline"`
+	TakerID        sql.NullString `json:"taker_id"`
+}
+
+type AttemptAnswer struct {
+	AttemptID  string `json:"attempt_id"`
+	QuestionID string `json:"question_id"`
+	CreatedAt  string `json:"created_at"`
+	Answer     int64  `json:"answer"`
+	AnswerText string `json:"answer_text"`
+	Correct    bool   `json:"correct"`
+}
+
+type AttemptQuestionTimer struct {
+	AttemptID  string `json:"attempt_id"`
+	QuestionID string `json:"question_id"`
+	OpenedAt   string `json:"opened_at"`
+	Deadline   string `json:"deadline"`
+}
+
+type AuditEvent struct {
 	ID        string         `json:"id"`
 	CreatedAt string         `json:"created_at"`
-	UpdatedAt string         `json:"updated_at"`
-	Title     string         `json:"title"`
-	UserID    string         `json:"user_id"`
-	Path      string         `json:"path"`
-	DeletedAt sql.NullString `json:"deleted_at"`
+	Event     string         `json:"event"`
+	UserID    sql.NullString `json:"user_id"`
+	Email     string         `json:"email"`
+	Ip        string         `json:"ip"`
+	Detail    string         `json:"detail"`
+}
+
+type LoginFailure struct {
+	Key          string         `json:"key"`
+	Failures     int64          `json:"failures"`
+	LastFailedAt string         `json:"last_failed_at"`
+	LockedUntil  sql.NullString `json:"locked_until"`
+}
+
+type Quiz struct {
+	ID                     string         `json:"id"`
+	CreatedAt              string         `json:"created_at"`
+	UpdatedAt              string         `json:"updated_at"`
+	Title                  string         `json:"title"`
+	UserID                 string         `json:"user_id"`
+	Path                   string         `json:"path"`
+	DeletedAt              sql.NullString `json:"deleted_at"`
+	ShuffleQuestions       bool           `json:"shuffle_questions"`
+	ShuffleChoices         bool           `json:"shuffle_choices"`
+	TimeLimitSeconds       sql.NullInt64  `json:"time_limit_seconds"`
+	OpensAt                sql.NullString `json:"opens_at"`
+	ClosesAt               sql.NullString `json:"closes_at"`
+	MaxAttempts            sql.NullInt64  `json:"max_attempts"`
+	AttemptCooldownSeconds sql.NullInt64  `json:"attempt_cooldown_seconds"`
+	LeaderboardEnabled     bool           `json:"leaderboard_enabled"`
+	LeaderboardShowNames   bool           `json:"leaderboard_show_names"`
+	LeaderboardSize        int64          `json:"leaderboard_size"`
 }
 
 type QuizQuestion struct {
-	ID             string         `json:"id"`
-	QuizID         string         `json:"quiz_id"`
-	QuestionNumber int64          `json:"question_number"`
-	QuestionText   string         `json:"question_text"`
-	Choice1        string         `json:"choice1"`
-	Choice2        string         `json:"choice2"`
-	Choice3        string         `json:"choice3"`
-	Choice4        string         `json:"choice4"`
-	Answer         int64          `json:"answer"`
-	DeletedAt      sql.NullString `json:"deleted_at"`
+	ID               string          `json:"id"`
+	QuizID           string          `json:"quiz_id"`
+	QuestionNumber   int64           `json:"question_number"`
+	QuestionText     string          `json:"question_text"`
+	Choice1          string          `json:"choice1"`
+	Choice2          string          `json:"choice2"`
+	Choice3          string          `json:"choice3"`
+	Choice4          string          `json:"choice4"`
+	Answer           int64           `json:"answer"`
+	DeletedAt        sql.NullString  `json:"deleted_at"`
+	Explanation      sql.NullString  `json:"explanation"`
+	QuestionType     string          `json:"question_type"`
+	AcceptedAnswers  sql.NullString  `json:"accepted_answers"`
+	NumericAnswer    sql.NullFloat64 `json:"numeric_answer"`
+	NumericTolerance sql.NullFloat64 `json:"numeric_tolerance"`
+	Choice1Feedback  sql.NullString  `json:"choice1_feedback"`
+	Choice2Feedback  sql.NullString  `json:"choice2_feedback"`
+	Choice3Feedback  sql.NullString  `json:"choice3_feedback"`
+	Choice4Feedback  sql.NullString  `json:"choice4_feedback"`
+	Tags             string          `json:"tags"`
+	TimeLimitSeconds sql.NullInt64   `json:"time_limit_seconds"`
+}
+
+type QuizSection struct {
+	ID        string `json:"id"`
+	QuizID    string `json:"quiz_id"`
+	Position  int64  `json:"position"`
+	Title     string `json:"title"`
+	Tag       string `json:"tag"`
+	DrawCount int64  `json:"draw_count"`
+}
+
+type RateLimit struct {
+	Key             string `json:"key"`
+	Count           int64  `json:"count"`
+	WindowStartedAt string `json:"window_started_at"`
+}
+
+type RecoveryCode struct {
+	ID         string         `json:"id"`
+	UserID     string         `json:"user_id"`
+	HashedCode string         `json:"hashed_code"`
+	CreatedAt  string         `json:"created_at"`
+	UsedAt     sql.NullString `json:"used_at"`
 }
 
 type RefreshToken struct {
-	Token     string         `json:"token"`
-	CreatedAt string         `json:"created_at"`
-	UpdatedAt string         `json:"updated_at"`
-	UserID    string         `json:"user_id"`
-	ExpiresAt string         `json:"expires_at"`
-	RevokedAt sql.NullString `json:"revoked_at"`
+	Token            string         `json:"token"`
+	CreatedAt        string         `json:"created_at"`
+	UpdatedAt        string         `json:"updated_at"`
+	UserID           string         `json:"user_id"`
+	ExpiresAt        string         `json:"expires_at"`
+	RevokedAt        sql.NullString `json:"revoked_at"`
+	FamilyID         string         `json:"family_id"`
+	UsedAt           sql.NullString `json:"used_at"`
+	SessionStartedAt string         `json:"session_started_at"`
+	UserAgent        string         `json:"user_agent"`
+	Ip               string         `json:"ip"`
+	AccessTokenID    string         `json:"access_token_id"`
+	AccessExpiresAt  string         `json:"access_expires_at"`
 }
 
-type User struct {
+type RevokedToken struct {
+	ID        string `json:"id"`
+	RevokedAt string `json:"revoked_at"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type Taker struct {
 	ID        string `json:"id"`
 	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
-	Email     string `json:"email"`
-	HashedPw  string `json:"hashed_pw"`
+	ExpiresAt string `json:"expires_at"`
+	Nickname  string `json:"nickname"`
+}
+
+type User struct {
+	ID              string         `json:"id"`
+	CreatedAt       string         `json:"created_at"`
+	UpdatedAt       string         `json:"updated_at"`
+	Email           string         `json:"email"`
+	HashedPw        string         `json:"hashed_pw"`
+	GuestExpiresAt  sql.NullString `json:"guest_expires_at"`
+	EmailVerifiedAt sql.NullString `json:"email_verified_at"`
+	TotpSecret      sql.NullString `json:"totp_secret"`
+	TotpEnabledAt   sql.NullString `json:"totp_enabled_at"`
+	TotpLastStep    int64          `json:"totp_last_step"`
+	DisplayName     sql.NullString `json:"display_name"`
+}
+
+type UserIdentity struct {
+	ID          string `json:"id"`
+	UserID      string `json:"user_id"`
+	Issuer      string `json:"issuer"`
+	Subject     string `json:"subject"`
+	Email       string `json:"email"`
+	CreatedAt   string `json:"created_at"`
+	LastLoginAt string `json:"last_login_at"`
 }
@@ -44,8 +44,19 @@ func (q *Queries) CreateQuiz(ctx context.Context, arg CreateQuizParams) error {
 }
 
 const createQuizQuestions = `-- name: CreateQuizQuestions :exec
-INSERT INTO quiz_questions (id, quiz_id, question_number, question_text, choice1, choice2, choice3, choice4, answer)
+INSERT INTO quiz_questions (id, quiz_id, question_number, question_text, choice1, choice2, choice3, choice4, answer, explanation, question_type, accepted_answers, numeric_answer, numeric_tolerance, choice1_feedback, choice2_feedback, choice3_feedback, choice4_feedback, tags, time_limit_seconds)
 VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
     ?,
     ?,
     ?,
@@ -59,15 +70,26 @@ VALUES (
 `
 
 type CreateQuizQuestionsParams struct {
-	ID             string `json:"id"`
-	QuizID         string `json:"quiz_id"`
-	QuestionNumber int64  `json:"question_number"`
-	QuestionText   string `json:"question_text"`
-	Choice1        string `json:"choice1"`
-	Choice2        string `json:"choice2"`
-	Choice3        string `json:"choice3"`
-	Choice4        string `json:"choice4"`
-	Answer         int64  `json:"answer"`
+	ID               string          `json:"id"`
+	QuizID           string          `json:"quiz_id"`
+	QuestionNumber   int64           `json:"question_number"`
+	QuestionText     string          `json:"question_text"`
+	Choice1          string          `json:"choice1"`
+	Choice2          string          `json:"choice2"`
+	Choice3          string          `json:"choice3"`
+	Choice4          string          `json:"choice4"`
+	Answer           int64           `json:"answer"`
+	Explanation      sql.NullString  `json:"explanation"`
+	QuestionType     string          `json:"question_type"`
+	AcceptedAnswers  sql.NullString  `json:"accepted_answers"`
+	NumericAnswer    sql.NullFloat64 `json:"numeric_answer"`
+	NumericTolerance sql.NullFloat64 `json:"numeric_tolerance"`
+	Choice1Feedback  sql.NullString  `json:"choice1_feedback"`
+	Choice2Feedback  sql.NullString  `json:"choice2_feedback"`
+	Choice3Feedback  sql.NullString  `json:"choice3_feedback"`
+	Choice4Feedback  sql.NullString  `json:"choice4_feedback"`
+	Tags             string          `json:"tags"`
+	TimeLimitSeconds sql.NullInt64   `json:"time_limit_seconds"`
 }
 
 func (q *Queries) CreateQuizQuestions(ctx context.Context, arg CreateQuizQuestionsParams) error {
@@ -81,6 +103,50 @@ func (q *Queries) CreateQuizQuestions(ctx context.Context, arg CreateQuizQuestio
 		arg.Choice3,
 		arg.Choice4,
 		arg.Answer,
+		arg.Explanation,
+		arg.QuestionType,
+		arg.AcceptedAnswers,
+		arg.NumericAnswer,
+		arg.NumericTolerance,
+		arg.Choice1Feedback,
+		arg.Choice2Feedback,
+		arg.Choice3Feedback,
+		arg.Choice4Feedback,
+		arg.Tags,
+		arg.TimeLimitSeconds,
+	)
+	return err
+}
+
+const createQuizSection = `-- name: CreateQuizSection :exec
+INSERT INTO quiz_sections (id, quiz_id, position, title, tag, draw_count)
+VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?
+)
+`
+
+type CreateQuizSectionParams struct {
+	ID        string `json:"id"`
+	QuizID    string `json:"quiz_id"`
+	Position  int64  `json:"position"`
+	Title     string `json:"title"`
+	Tag       string `json:"tag"`
+	DrawCount int64  `json:"draw_count"`
+}
+
+func (q *Queries) CreateQuizSection(ctx context.Context, arg CreateQuizSectionParams) error {
+	_, err := q.db.ExecContext(ctx, createQuizSection,
+		arg.ID,
+		arg.QuizID,
+		arg.Position,
+		arg.Title,
+		arg.Tag,
+		arg.DrawCount,
 	)
 	return err
 }
@@ -113,8 +179,17 @@ func (q *Queries) DeleteQuizQuestion(ctx context.Context, arg DeleteQuizQuestion
 	return err
 }
 
+const deleteQuizSections = `-- name: DeleteQuizSections :exec
+DELETE FROM quiz_sections WHERE quiz_id = ?
+`
+
+func (q *Queries) DeleteQuizSections(ctx context.Context, quizID string) error {
+	_, err := q.db.ExecContext(ctx, deleteQuizSections, quizID)
+	return err
+}
+
 const getAllQuestionsInQuiz = `-- name: GetAllQuestionsInQuiz :many
-SELECT id, quiz_id, question_number, question_text, choice1, choice2, choice3, choice4, answer, deleted_at FROM quiz_questions WHERE quiz_id = ? AND deleted_at IS NULL ORDER BY question_number ASC
+SELECT id, quiz_id, question_number, question_text, choice1, choice2, choice3, choice4, answer, deleted_at, explanation, question_type, accepted_answers, numeric_answer, numeric_tolerance, choice1_feedback, choice2_feedback, choice3_feedback, choice4_feedback, tags, time_limit_seconds FROM quiz_questions WHERE quiz_id = ? AND deleted_at IS NULL ORDER BY question_number ASC
 `
 
 func (q *Queries) GetAllQuestionsInQuiz(ctx context.Context, quizID string) ([]QuizQuestion, error) {
@@ -137,6 +212,17 @@ func (q *Queries) GetAllQuestionsInQuiz(ctx context.Context, quizID string) ([]Q
 			&i.Choice4,
 			&i.Answer,
 			&i.DeletedAt,
+			&i.Explanation,
+			&i.QuestionType,
+			&i.AcceptedAnswers,
+			&i.NumericAnswer,
+			&i.NumericTolerance,
+			&i.Choice1Feedback,
+			&i.Choice2Feedback,
+			&i.Choice3Feedback,
+			&i.Choice4Feedback,
+			&i.Tags,
+			&i.TimeLimitSeconds,
 		); err != nil {
 			return nil, err
 		}
@@ -152,7 +238,7 @@ func (q *Queries) GetAllQuestionsInQuiz(ctx context.Context, quizID string) ([]Q
 }
 
 const getAllQuizzesByUserID = `-- name: GetAllQuizzesByUserID :many
-SELECT id, created_at, updated_at, title, user_id, path, deleted_at FROM quizzes WHERE user_id = ? AND deleted_at IS NULL ORDER BY updated_at DESC
+SELECT id, created_at, updated_at, title, user_id, path, deleted_at, shuffle_questions, shuffle_choices, time_limit_seconds FROM quizzes WHERE user_id = ? AND deleted_at IS NULL ORDER BY updated_at DESC
 `
 
 func (q *Queries) GetAllQuizzesByUserID(ctx context.Context, userID string) ([]Quiz, error) {
@@ -172,6 +258,9 @@ func (q *Queries) GetAllQuizzesByUserID(ctx context.Context, userID string) ([]Q
 			&i.UserID,
 			&i.Path,
 			&i.DeletedAt,
+			&i.ShuffleQuestions,
+			&i.ShuffleChoices,
+			&i.TimeLimitSeconds,
 		); err != nil {
 			return nil, err
 		}
@@ -198,7 +287,7 @@ func (q *Queries) GetQuestionCountInQuiz(ctx context.Context, quizID string) (in
 }
 
 const getQuestionFromQuestionNumber = `-- name: GetQuestionFromQuestionNumber :one
-SELECT id, quiz_id, question_number, question_text, choice1, choice2, choice3, choice4, answer, deleted_at FROM quiz_questions WHERE question_number = ? AND quiz_id = ?
+SELECT id, quiz_id, question_number, question_text, choice1, choice2, choice3, choice4, answer, deleted_at, explanation, question_type, accepted_answers, numeric_answer, numeric_tolerance, choice1_feedback, choice2_feedback, choice3_feedback, choice4_feedback, tags, time_limit_seconds FROM quiz_questions WHERE question_number = ? AND quiz_id = ?
 `
 
 type GetQuestionFromQuestionNumberParams struct {
@@ -220,33 +309,65 @@ func (q *Queries) GetQuestionFromQuestionNumber(ctx context.Context, arg GetQues
 		&i.Choice4,
 		&i.Answer,
 		&i.DeletedAt,
+		&i.Explanation,
+		&i.QuestionType,
+		&i.AcceptedAnswers,
+		&i.NumericAnswer,
+		&i.NumericTolerance,
+		&i.Choice1Feedback,
+		&i.Choice2Feedback,
+		&i.Choice3Feedback,
+		&i.Choice4Feedback,
+		&i.Tags,
+		&i.TimeLimitSeconds,
 	)
 	return i, err
 }
 
 const getQuiz = `-- name: GetQuiz :one
-SELECT quizzes.id, created_at, updated_at, title, user_id, path, quizzes.deleted_at, quiz_questions.id, quiz_id, question_number, question_text, choice1, choice2, choice3, choice4, answer, quiz_questions.deleted_at FROM quizzes JOIN quiz_questions ON quizzes.id = quiz_questions.quiz_id
+SELECT quizzes.id, created_at, updated_at, title, user_id, path, quizzes.deleted_at, shuffle_questions, shuffle_choices, quizzes.time_limit_seconds, opens_at, closes_at, max_attempts, attempt_cooldown_seconds, leaderboard_enabled, leaderboard_show_names, leaderboard_size, quiz_questions.id, quiz_id, question_number, question_text, choice1, choice2, choice3, choice4, answer, quiz_questions.deleted_at, explanation, question_type, accepted_answers, numeric_answer, numeric_tolerance, choice1_feedback, choice2_feedback, choice3_feedback, choice4_feedback, tags, quiz_questions.time_limit_seconds FROM quizzes JOIN quiz_questions ON quizzes.id = quiz_questions.quiz_id
 WHERE quizzes.id = ?
 `
 
 type GetQuizRow struct {
-	ID             string         `json:"id"`
-	CreatedAt      string         `json:"created_at"`
-	UpdatedAt      string         `json:"updated_at"`
-	Title          string         `json:"title"`
-	UserID         string         `json:"user_id"`
-	Path           string         `json:"path"`
-	DeletedAt      sql.NullString `json:"deleted_at"`
-	ID_2           string         `json:"id_2"`
-	QuizID         string         `json:"quiz_id"`
-	QuestionNumber int64          `json:"question_number"`
-	QuestionText   string         `json:"question_text"`
-	Choice1        string         `json:"choice1"`
-	Choice2        string         `json:"choice2"`
-	Choice3        string         `json:"choice3"`
-	Choice4        string         `json:"choice4"`
-	Answer         int64          `json:"answer"`
-	DeletedAt_2    sql.NullString `json:"deleted_at_2"`
+	ID                     string          `json:"id"`
+	CreatedAt              string          `json:"created_at"`
+	UpdatedAt              string          `json:"updated_at"`
+	Title                  string          `json:"title"`
+	UserID                 string          `json:"user_id"`
+	Path                   string          `json:"path"`
+	DeletedAt              sql.NullString  `json:"deleted_at"`
+	ShuffleQuestions       bool            `json:"shuffle_questions"`
+	ShuffleChoices         bool            `json:"shuffle_choices"`
+	TimeLimitSeconds       sql.NullInt64   `json:"time_limit_seconds"`
+	OpensAt                sql.NullString  `json:"opens_at"`
+	ClosesAt               sql.NullString  `json:"closes_at"`
+	MaxAttempts            sql.NullInt64   `json:"max_attempts"`
+	AttemptCooldownSeconds sql.NullInt64   `json:"attempt_cooldown_seconds"`
+	LeaderboardEnabled     bool            `json:"leaderboard_enabled"`
+	LeaderboardShowNames   bool            `json:"leaderboard_show_names"`
+	LeaderboardSize        int64           `json:"leaderboard_size"`
+	ID_2                   string          `json:"id_2"`
+	QuizID                 string          `json:"quiz_id"`
+	QuestionNumber         int64           `json:"question_number"`
+	QuestionText           string          `json:"question_text"`
+	Choice1                string          `json:"choice1"`
+	Choice2                string          `json:"choice2"`
+	Choice3                string          `json:"choice3"`
+	Choice4                string          `json:"choice4"`
+	Answer                 int64           `json:"answer"`
+	DeletedAt_2            sql.NullString  `json:"deleted_at_2"`
+	Explanation            sql.NullString  `json:"explanation"`
+	QuestionType           string          `json:"question_type"`
+	AcceptedAnswers        sql.NullString  `json:"accepted_answers"`
+	NumericAnswer          sql.NullFloat64 `json:"numeric_answer"`
+	NumericTolerance       sql.NullFloat64 `json:"numeric_tolerance"`
+	Choice1Feedback        sql.NullString  `json:"choice1_feedback"`
+	Choice2Feedback        sql.NullString  `json:"choice2_feedback"`
+	Choice3Feedback        sql.NullString  `json:"choice3_feedback"`
+	Choice4Feedback        sql.NullString  `json:"choice4_feedback"`
+	Tags                   string          `json:"tags"`
+	TimeLimitSeconds_2     sql.NullInt64   `json:"time_limit_seconds_2"`
 }
 
 func (q *Queries) GetQuiz(ctx context.Context, id string) (GetQuizRow, error) {
@@ -260,6 +381,16 @@ func (q *Queries) GetQuiz(ctx context.Context, id string) (GetQuizRow, error) {
 		&i.UserID,
 		&i.Path,
 		&i.DeletedAt,
+		&i.ShuffleQuestions,
+		&i.ShuffleChoices,
+		&i.TimeLimitSeconds,
+		&i.OpensAt,
+		&i.ClosesAt,
+		&i.MaxAttempts,
+		&i.AttemptCooldownSeconds,
+		&i.LeaderboardEnabled,
+		&i.LeaderboardShowNames,
+		&i.LeaderboardSize,
 		&i.ID_2,
 		&i.QuizID,
 		&i.QuestionNumber,
@@ -270,6 +401,17 @@ func (q *Queries) GetQuiz(ctx context.Context, id string) (GetQuizRow, error) {
 		&i.Choice4,
 		&i.Answer,
 		&i.DeletedAt_2,
+		&i.Explanation,
+		&i.QuestionType,
+		&i.AcceptedAnswers,
+		&i.NumericAnswer,
+		&i.NumericTolerance,
+		&i.Choice1Feedback,
+		&i.Choice2Feedback,
+		&i.Choice3Feedback,
+		&i.Choice4Feedback,
+		&i.Tags,
+		&i.TimeLimitSeconds_2,
 	)
 	return i, err
 }
@@ -297,6 +439,193 @@ func (q *Queries) GetQuizIDFromPath(ctx context.Context, path string) (GetQuizID
 	return i, err
 }
 
+const getQuizSections = `-- name: GetQuizSections :many
+SELECT id, quiz_id, position, title, tag, draw_count FROM quiz_sections WHERE quiz_id = ? ORDER BY position ASC
+`
+
+func (q *Queries) GetQuizSections(ctx context.Context, quizID string) ([]QuizSection, error) {
+	rows, err := q.db.QueryContext(ctx, getQuizSections, quizID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []QuizSection
+	for rows.Next() {
+		var i QuizSection
+		if err := rows.Scan(
+			&i.ID,
+			&i.QuizID,
+			&i.Position,
+			&i.Title,
+			&i.Tag,
+			&i.DrawCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getQuizSettings = `-- name: GetQuizSettings :one
+SELECT shuffle_questions, shuffle_choices, time_limit_seconds, opens_at, closes_at, max_attempts, attempt_cooldown_seconds,
+    leaderboard_enabled, leaderboard_show_names, leaderboard_size
+FROM quizzes WHERE id = ?
+`
+
+type GetQuizSettingsRow struct {
+	ShuffleQuestions       bool           `json:"shuffle_questions"`
+	ShuffleChoices         bool           `json:"shuffle_choices"`
+	TimeLimitSeconds       sql.NullInt64  `json:"time_limit_seconds"`
+	OpensAt                sql.NullString `json:"opens_at"`
+	ClosesAt               sql.NullString `json:"closes_at"`
+	MaxAttempts            sql.NullInt64  `json:"max_attempts"`
+	AttemptCooldownSeconds sql.NullInt64  `json:"attempt_cooldown_seconds"`
+	LeaderboardEnabled     bool           `json:"leaderboard_enabled"`
+	LeaderboardShowNames   bool           `json:"leaderboard_show_names"`
+	LeaderboardSize        int64          `json:"leaderboard_size"`
+}
+
+func (q *Queries) GetQuizSettings(ctx context.Context, id string) (GetQuizSettingsRow, error) {
+	row := q.db.QueryRowContext(ctx, getQuizSettings, id)
+	var i GetQuizSettingsRow
+	err := row.Scan(
+		&i.ShuffleQuestions,
+		&i.ShuffleChoices,
+		&i.TimeLimitSeconds,
+		&i.OpensAt,
+		&i.ClosesAt,
+		&i.MaxAttempts,
+		&i.AttemptCooldownSeconds,
+		&i.LeaderboardEnabled,
+		&i.LeaderboardShowNames,
+		&i.LeaderboardSize,
+	)
+	return i, err
+}
+
+const updateQuizQuestion = `-- name: UpdateQuizQuestion :exec
+UPDATE quiz_questions
+SET question_text = ?,
+    choice1 = ?,
+    choice2 = ?,
+    choice3 = ?,
+    choice4 = ?,
+    answer = ?,
+    explanation = ?,
+    question_type = ?,
+    accepted_answers = ?,
+    numeric_answer = ?,
+    numeric_tolerance = ?,
+    choice1_feedback = ?,
+    choice2_feedback = ?,
+    choice3_feedback = ?,
+    choice4_feedback = ?,
+    tags = ?,
+    time_limit_seconds = ?
+WHERE id = ?
+`
+
+type UpdateQuizQuestionParams struct {
+	QuestionText     string          `json:"question_text"`
+	Choice1          string          `json:"choice1"`
+	Choice2          string          `json:"choice2"`
+	Choice3          string          `json:"choice3"`
+	Choice4          string          `json:"choice4"`
+	Answer           int64           `json:"answer"`
+	Explanation      sql.NullString  `json:"explanation"`
+	QuestionType     string          `json:"question_type"`
+	AcceptedAnswers  sql.NullString  `json:"accepted_answers"`
+	NumericAnswer    sql.NullFloat64 `json:"numeric_answer"`
+	NumericTolerance sql.NullFloat64 `json:"numeric_tolerance"`
+	Choice1Feedback  sql.NullString  `json:"choice1_feedback"`
+	Choice2Feedback  sql.NullString  `json:"choice2_feedback"`
+	Choice3Feedback  sql.NullString  `json:"choice3_feedback"`
+	Choice4Feedback  sql.NullString  `json:"choice4_feedback"`
+	Tags             string          `json:"tags"`
+	TimeLimitSeconds sql.NullInt64   `json:"time_limit_seconds"`
+	ID               string          `json:"id"`
+}
+
+func (q *Queries) UpdateQuizQuestion(ctx context.Context, arg UpdateQuizQuestionParams) error {
+	_, err := q.db.ExecContext(ctx, updateQuizQuestion,
+		arg.QuestionText,
+		arg.Choice1,
+		arg.Choice2,
+		arg.Choice3,
+		arg.Choice4,
+		arg.Answer,
+		arg.Explanation,
+		arg.QuestionType,
+		arg.AcceptedAnswers,
+		arg.NumericAnswer,
+		arg.NumericTolerance,
+		arg.Choice1Feedback,
+		arg.Choice2Feedback,
+		arg.Choice3Feedback,
+		arg.Choice4Feedback,
+		arg.Tags,
+		arg.TimeLimitSeconds,
+		arg.ID,
+	)
+	return err
+}
+
+const updateQuizSettings = `-- name: UpdateQuizSettings :exec
+UPDATE quizzes
+SET shuffle_questions = ?,
+    shuffle_choices = ?,
+    time_limit_seconds = ?,
+    opens_at = ?,
+    closes_at = ?,
+    max_attempts = ?,
+    attempt_cooldown_seconds = ?,
+    leaderboard_enabled = ?,
+    leaderboard_show_names = ?,
+    leaderboard_size = ?,
+    updated_at = ?
+WHERE id = ?
+`
+
+type UpdateQuizSettingsParams struct {
+	ShuffleQuestions       bool           `json:"shuffle_questions"`
+	ShuffleChoices         bool           `json:"shuffle_choices"`
+	TimeLimitSeconds       sql.NullInt64  `json:"time_limit_seconds"`
+	OpensAt                sql.NullString `json:"opens_at"`
+	ClosesAt               sql.NullString `json:"closes_at"`
+	MaxAttempts            sql.NullInt64  `json:"max_attempts"`
+	AttemptCooldownSeconds sql.NullInt64  `json:"attempt_cooldown_seconds"`
+	LeaderboardEnabled     bool           `json:"leaderboard_enabled"`
+	LeaderboardShowNames   bool           `json:"leaderboard_show_names"`
+	LeaderboardSize        int64          `json:"leaderboard_size"`
+	UpdatedAt              string         `json:"updated_at"`
+	ID                     string         `json:"id"`
+}
+
+func (q *Queries) UpdateQuizSettings(ctx context.Context, arg UpdateQuizSettingsParams) error {
+	_, err := q.db.ExecContext(ctx, updateQuizSettings,
+		arg.ShuffleQuestions,
+		arg.ShuffleChoices,
+		arg.TimeLimitSeconds,
+		arg.OpensAt,
+		arg.ClosesAt,
+		arg.MaxAttempts,
+		arg.AttemptCooldownSeconds,
+		arg.LeaderboardEnabled,
+		arg.LeaderboardShowNames,
+		arg.LeaderboardSize,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	return err
+}
+
 const updateQuizTitle = `-- name: UpdateQuizTitle :exec
 UPDATE quizzes SET title = ?, updated_at = ? WHERE id = ?
 `
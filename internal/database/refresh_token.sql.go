@@ -11,23 +11,35 @@ import (
 )
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, family_id, session_started_at, user_agent, ip, access_token_id, access_expires_at)
 VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
     ?,
     ?,
     ?,
     ?,
     ?
 )
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, used_at, session_started_at, user_agent, ip, access_token_id, access_expires_at
 `
 
 type CreateRefreshTokenParams struct {
-	Token     string `json:"token"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
-	UserID    string `json:"user_id"`
-	ExpiresAt string `json:"expires_at"`
+	Token            string `json:"token"`
+	CreatedAt        string `json:"created_at"`
+	UpdatedAt        string `json:"updated_at"`
+	UserID           string `json:"user_id"`
+	ExpiresAt        string `json:"expires_at"`
+	FamilyID         string `json:"family_id"`
+	SessionStartedAt string `json:"session_started_at"`
+	UserAgent        string `json:"user_agent"`
+	Ip               string `json:"ip"`
+	AccessTokenID    string `json:"access_token_id"`
+	AccessExpiresAt  string `json:"access_expires_at"`
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
@@ -37,6 +49,12 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		arg.UpdatedAt,
 		arg.UserID,
 		arg.ExpiresAt,
+		arg.FamilyID,
+		arg.SessionStartedAt,
+		arg.UserAgent,
+		arg.Ip,
+		arg.AccessTokenID,
+		arg.AccessExpiresAt,
 	)
 	var i RefreshToken
 	err := row.Scan(
@@ -46,18 +64,118 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.UsedAt,
+		&i.SessionStartedAt,
+		&i.UserAgent,
+		&i.Ip,
+		&i.AccessTokenID,
+		&i.AccessExpiresAt,
 	)
 	return i, err
 }
 
+const getOtherSessionAccessTokens = `-- name: GetOtherSessionAccessTokens :many
+SELECT access_token_id, access_expires_at
+FROM refresh_tokens
+WHERE user_id = ? AND family_id != ? AND access_token_id != '' AND access_expires_at > ?
+`
+
+type GetOtherSessionAccessTokensParams struct {
+	UserID          string `json:"user_id"`
+	FamilyID        string `json:"family_id"`
+	AccessExpiresAt string `json:"access_expires_at"`
+}
+
+type GetOtherSessionAccessTokensRow struct {
+	AccessTokenID   string `json:"access_token_id"`
+	AccessExpiresAt string `json:"access_expires_at"`
+}
+
+// Returns the unexpired access tokens of every session of a user but the
+// one given, which may be empty to return them all.
+func (q *Queries) GetOtherSessionAccessTokens(ctx context.Context, arg GetOtherSessionAccessTokensParams) ([]GetOtherSessionAccessTokensRow, error) {
+	rows, err := q.db.QueryContext(ctx, getOtherSessionAccessTokens, arg.UserID, arg.FamilyID, arg.AccessExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetOtherSessionAccessTokensRow
+	for rows.Next() {
+		var i GetOtherSessionAccessTokensRow
+		if err := rows.Scan(
+			&i.AccessTokenID,
+			&i.AccessExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSessionAccessTokens = `-- name: GetSessionAccessTokens :many
+SELECT access_token_id, access_expires_at
+FROM refresh_tokens
+WHERE family_id = ? AND access_token_id != '' AND access_expires_at > ?
+`
+
+type GetSessionAccessTokensParams struct {
+	FamilyID        string `json:"family_id"`
+	AccessExpiresAt string `json:"access_expires_at"`
+}
+
+type GetSessionAccessTokensRow struct {
+	AccessTokenID   string `json:"access_token_id"`
+	AccessExpiresAt string `json:"access_expires_at"`
+}
+
+// Returns the access tokens issued with the refresh tokens of a session
+// that have not expired.
+func (q *Queries) GetSessionAccessTokens(ctx context.Context, arg GetSessionAccessTokensParams) ([]GetSessionAccessTokensRow, error) {
+	rows, err := q.db.QueryContext(ctx, getSessionAccessTokens, arg.FamilyID, arg.AccessExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetSessionAccessTokensRow
+	for rows.Next() {
+		var i GetSessionAccessTokensRow
+		if err := rows.Scan(
+			&i.AccessTokenID,
+			&i.AccessExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
 SELECT 
     users.id,
     users.created_at,
     users.updated_at,
     users.email,
+    users.guest_expires_at,
     refresh_tokens.expires_at AS token_expires_at,
-    refresh_tokens.revoked_at AS token_revoked_at
+    refresh_tokens.revoked_at AS token_revoked_at,
+    refresh_tokens.used_at AS token_used_at,
+    refresh_tokens.family_id AS token_family_id,
+    refresh_tokens.session_started_at AS token_session_started_at
 FROM users
 JOIN refresh_tokens
     ON users.id = refresh_tokens.user_id
@@ -65,12 +183,16 @@ WHERE refresh_tokens.token = ?
 `
 
 type GetUserFromRefreshTokenRow struct {
-	ID             string         `json:"id"`
-	CreatedAt      string         `json:"created_at"`
-	UpdatedAt      string         `json:"updated_at"`
-	Email          string         `json:"email"`
-	TokenExpiresAt string         `json:"token_expires_at"`
-	TokenRevokedAt sql.NullString `json:"token_revoked_at"`
+	ID                    string         `json:"id"`
+	CreatedAt             string         `json:"created_at"`
+	UpdatedAt             string         `json:"updated_at"`
+	Email                 string         `json:"email"`
+	GuestExpiresAt        sql.NullString `json:"guest_expires_at"`
+	TokenExpiresAt        string         `json:"token_expires_at"`
+	TokenRevokedAt        sql.NullString `json:"token_revoked_at"`
+	TokenUsedAt           sql.NullString `json:"token_used_at"`
+	TokenFamilyID         string         `json:"token_family_id"`
+	TokenSessionStartedAt string         `json:"token_session_started_at"`
 }
 
 func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (GetUserFromRefreshTokenRow, error) {
@@ -81,12 +203,92 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Ge
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Email,
+		&i.GuestExpiresAt,
 		&i.TokenExpiresAt,
 		&i.TokenRevokedAt,
+		&i.TokenUsedAt,
+		&i.TokenFamilyID,
+		&i.TokenSessionStartedAt,
 	)
 	return i, err
 }
 
+const getUserSessions = `-- name: GetUserSessions :many
+SELECT family_id, session_started_at, created_at AS last_used_at, user_agent, ip
+FROM refresh_tokens
+WHERE user_id = ? AND used_at IS NULL AND revoked_at IS NULL AND expires_at > ?
+ORDER BY created_at DESC
+`
+
+type GetUserSessionsParams struct {
+	UserID    string `json:"user_id"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type GetUserSessionsRow struct {
+	FamilyID         string `json:"family_id"`
+	SessionStartedAt string `json:"session_started_at"`
+	LastUsedAt       string `json:"last_used_at"`
+	UserAgent        string `json:"user_agent"`
+	Ip               string `json:"ip"`
+}
+
+// A session is a family of refresh tokens; its current token is the one not
+// yet exchanged for another.
+func (q *Queries) GetUserSessions(ctx context.Context, arg GetUserSessionsParams) ([]GetUserSessionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserSessions, arg.UserID, arg.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserSessionsRow
+	for rows.Next() {
+		var i GetUserSessionsRow
+		if err := rows.Scan(
+			&i.FamilyID,
+			&i.SessionStartedAt,
+			&i.LastUsedAt,
+			&i.UserAgent,
+			&i.Ip,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeOtherUserSessions = `-- name: RevokeOtherUserSessions :exec
+UPDATE refresh_tokens
+SET updated_at = ?, revoked_at = ?
+WHERE user_id = ? AND family_id != ? AND revoked_at IS NULL
+`
+
+type RevokeOtherUserSessionsParams struct {
+	UpdatedAt string         `json:"updated_at"`
+	RevokedAt sql.NullString `json:"revoked_at"`
+	UserID    string         `json:"user_id"`
+	FamilyID  string         `json:"family_id"`
+}
+
+// Revokes every session of a user but the one given, which may be empty to
+// revoke them all.
+func (q *Queries) RevokeOtherUserSessions(ctx context.Context, arg RevokeOtherUserSessionsParams) error {
+	_, err := q.db.ExecContext(ctx, revokeOtherUserSessions,
+		arg.UpdatedAt,
+		arg.RevokedAt,
+		arg.UserID,
+		arg.FamilyID,
+	)
+	return err
+}
+
 const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens
 SET updated_at = ?, revoked_at = ?
@@ -103,3 +305,68 @@ func (q *Queries) RevokeRefreshToken(ctx context.Context, arg RevokeRefreshToken
 	_, err := q.db.ExecContext(ctx, revokeRefreshToken, arg.UpdatedAt, arg.RevokedAt, arg.Token)
 	return err
 }
+
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET updated_at = ?, revoked_at = ?
+WHERE family_id = ? AND revoked_at IS NULL
+`
+
+type RevokeRefreshTokenFamilyParams struct {
+	UpdatedAt string         `json:"updated_at"`
+	RevokedAt sql.NullString `json:"revoked_at"`
+	FamilyID  string         `json:"family_id"`
+}
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, arg RevokeRefreshTokenFamilyParams) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokenFamily, arg.UpdatedAt, arg.RevokedAt, arg.FamilyID)
+	return err
+}
+
+const revokeUserSession = `-- name: RevokeUserSession :execrows
+UPDATE refresh_tokens
+SET updated_at = ?, revoked_at = ?
+WHERE user_id = ? AND family_id = ? AND revoked_at IS NULL
+`
+
+type RevokeUserSessionParams struct {
+	UpdatedAt string         `json:"updated_at"`
+	RevokedAt sql.NullString `json:"revoked_at"`
+	UserID    string         `json:"user_id"`
+	FamilyID  string         `json:"family_id"`
+}
+
+func (q *Queries) RevokeUserSession(ctx context.Context, arg RevokeUserSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserSession,
+		arg.UpdatedAt,
+		arg.RevokedAt,
+		arg.UserID,
+		arg.FamilyID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useRefreshToken = `-- name: UseRefreshToken :execrows
+UPDATE refresh_tokens
+SET updated_at = ?, used_at = ?
+WHERE token = ? AND used_at IS NULL AND revoked_at IS NULL
+`
+
+type UseRefreshTokenParams struct {
+	UpdatedAt string         `json:"updated_at"`
+	UsedAt    sql.NullString `json:"used_at"`
+	Token     string         `json:"token"`
+}
+
+// Marks a refresh token as exchanged for a new one. No rows are affected if
+// it was already used or revoked.
+func (q *Queries) UseRefreshToken(ctx context.Context, arg UseRefreshTokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useRefreshToken, arg.UpdatedAt, arg.UsedAt, arg.Token)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
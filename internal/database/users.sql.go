@@ -7,8 +7,41 @@ package database
 
 import (
 	"context"
+	"database/sql"
 )
 
+const createGuestUser = `-- name: CreateGuestUser :exec
+
+INSERT INTO users (id, created_at, updated_at, email, hashed_pw, guest_expires_at)
+VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    '',
+    ?
+)
+`
+
+type CreateGuestUserParams struct {
+	ID             string         `json:"id"`
+	CreatedAt      string         `json:"created_at"`
+	UpdatedAt      string         `json:"updated_at"`
+	Email          string         `json:"email"`
+	GuestExpiresAt sql.NullString `json:"guest_expires_at"`
+}
+
+func (q *Queries) CreateGuestUser(ctx context.Context, arg CreateGuestUserParams) error {
+	_, err := q.db.ExecContext(ctx, createGuestUser,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Email,
+		arg.GuestExpiresAt,
+	)
+	return err
+}
+
 const createUser = `-- name: CreateUser :exec
 INSERT INTO users (id, created_at, updated_at, email, hashed_pw)
 VALUES (
@@ -39,9 +72,68 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) error {
 	return err
 }
 
+const deleteExpiredGuestUsers = `-- name: DeleteExpiredGuestUsers :exec
+
+DELETE FROM users
+WHERE guest_expires_at IS NOT NULL AND guest_expires_at < ?
+`
+
+func (q *Queries) DeleteExpiredGuestUsers(ctx context.Context, guestExpiresAt sql.NullString) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredGuestUsers, guestExpiresAt)
+	return err
+}
+
+const disableUserTotp = `-- name: DisableUserTotp :exec
+
+UPDATE users
+SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0, updated_at = ?
+WHERE id = ?
+`
+
+type DisableUserTotpParams struct {
+	UpdatedAt string `json:"updated_at"`
+	ID        string `json:"id"`
+}
+
+func (q *Queries) DisableUserTotp(ctx context.Context, arg DisableUserTotpParams) error {
+	_, err := q.db.ExecContext(ctx, disableUserTotp, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const enableUserTotp = `-- name: EnableUserTotp :execrows
+
+UPDATE users
+SET totp_enabled_at = ?, totp_last_step = ?, updated_at = ?
+WHERE id = ? AND totp_secret = ? AND totp_enabled_at IS NULL
+`
+
+type EnableUserTotpParams struct {
+	TotpEnabledAt sql.NullString `json:"totp_enabled_at"`
+	TotpLastStep  int64          `json:"totp_last_step"`
+	UpdatedAt     string         `json:"updated_at"`
+	ID            string         `json:"id"`
+	TotpSecret    sql.NullString `json:"totp_secret"`
+}
+
+// Enables two-factor authentication if the secret is still the one being
+// set up.
+func (q *Queries) EnableUserTotp(ctx context.Context, arg EnableUserTotpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enableUserTotp,
+		arg.TotpEnabledAt,
+		arg.TotpLastStep,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.TotpSecret,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getUser = `-- name: GetUser :one
 
-SELECT id, created_at, updated_at, email, hashed_pw FROM users WHERE id = ?
+SELECT id, created_at, updated_at, email, hashed_pw, guest_expires_at, email_verified_at, totp_secret, totp_enabled_at, totp_last_step, display_name FROM users WHERE id = ?
 `
 
 func (q *Queries) GetUser(ctx context.Context, id string) (User, error) {
@@ -53,13 +145,19 @@ func (q *Queries) GetUser(ctx context.Context, id string) (User, error) {
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPw,
+		&i.GuestExpiresAt,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.DisplayName,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
 
-SELECT id, created_at, updated_at, email, hashed_pw FROM users WHERE email = ?
+SELECT id, created_at, updated_at, email, hashed_pw, guest_expires_at, email_verified_at, totp_secret, totp_enabled_at, totp_last_step, display_name FROM users WHERE email = ?
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -71,10 +169,106 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPw,
+		&i.GuestExpiresAt,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.DisplayName,
 	)
 	return i, err
 }
 
+const rehashUserPassword = `-- name: RehashUserPassword :exec
+
+UPDATE users
+SET hashed_pw = ?
+WHERE id = ? AND hashed_pw = ?
+`
+
+type RehashUserPasswordParams struct {
+	HashedPw   string `json:"hashed_pw"`
+	ID         string `json:"id"`
+	HashedPw_2 string `json:"hashed_pw_2"`
+}
+
+// Replaces the hash of a password with a new one, unless the password has
+// changed since the old one was checked.
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, rehashUserPassword, arg.HashedPw, arg.ID, arg.HashedPw_2)
+	return err
+}
+
+const resetUserPassword = `-- name: ResetUserPassword :execrows
+
+UPDATE users
+SET hashed_pw = ?, updated_at = ?
+WHERE id = ? AND hashed_pw = ?
+`
+
+type ResetUserPasswordParams struct {
+	HashedPw   string `json:"hashed_pw"`
+	UpdatedAt  string `json:"updated_at"`
+	ID         string `json:"id"`
+	HashedPw_2 string `json:"hashed_pw_2"`
+}
+
+// Sets a new password, unless the password has changed since the reset
+// was asked for.
+func (q *Queries) ResetUserPassword(ctx context.Context, arg ResetUserPasswordParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, resetUserPassword,
+		arg.HashedPw,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.HashedPw_2,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setUserTotpSecret = `-- name: SetUserTotpSecret :execrows
+
+UPDATE users
+SET totp_secret = ?, totp_last_step = 0, updated_at = ?
+WHERE id = ? AND totp_enabled_at IS NULL
+`
+
+type SetUserTotpSecretParams struct {
+	TotpSecret sql.NullString `json:"totp_secret"`
+	UpdatedAt  string         `json:"updated_at"`
+	ID         string         `json:"id"`
+}
+
+// Starts setting up two-factor authentication with a new secret, unless it
+// is already enabled.
+func (q *Queries) SetUserTotpSecret(ctx context.Context, arg SetUserTotpSecretParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setUserTotpSecret, arg.TotpSecret, arg.UpdatedAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateDisplayName = `-- name: UpdateDisplayName :exec
+
+UPDATE users
+SET display_name = ?, updated_at = ?
+WHERE id = ?
+`
+
+type UpdateDisplayNameParams struct {
+	DisplayName sql.NullString `json:"display_name"`
+	UpdatedAt   string         `json:"updated_at"`
+	ID          string         `json:"id"`
+}
+
+func (q *Queries) UpdateDisplayName(ctx context.Context, arg UpdateDisplayNameParams) error {
+	_, err := q.db.ExecContext(ctx, updateDisplayName, arg.DisplayName, arg.UpdatedAt, arg.ID)
+	return err
+}
+
 const updatePassword = `-- name: UpdatePassword :exec
 
 UPDATE users
@@ -92,3 +286,78 @@ func (q *Queries) UpdatePassword(ctx context.Context, arg UpdatePasswordParams)
 	_, err := q.db.ExecContext(ctx, updatePassword, arg.HashedPw, arg.UpdatedAt, arg.ID)
 	return err
 }
+
+const upgradeGuestUser = `-- name: UpgradeGuestUser :exec
+
+UPDATE users
+SET email = ?, hashed_pw = ?, guest_expires_at = NULL, updated_at = ?
+WHERE id = ? AND guest_expires_at IS NOT NULL
+`
+
+type UpgradeGuestUserParams struct {
+	Email     string `json:"email"`
+	HashedPw  string `json:"hashed_pw"`
+	UpdatedAt string `json:"updated_at"`
+	ID        string `json:"id"`
+}
+
+func (q *Queries) UpgradeGuestUser(ctx context.Context, arg UpgradeGuestUserParams) error {
+	_, err := q.db.ExecContext(ctx, upgradeGuestUser,
+		arg.Email,
+		arg.HashedPw,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	return err
+}
+
+const useUserTotpStep = `-- name: UseUserTotpStep :execrows
+
+UPDATE users
+SET totp_last_step = ?
+WHERE id = ? AND totp_last_step < ?
+`
+
+type UseUserTotpStepParams struct {
+	TotpLastStep   int64  `json:"totp_last_step"`
+	ID             string `json:"id"`
+	TotpLastStep_2 int64  `json:"totp_last_step_2"`
+}
+
+// Records the time step of a code as used. No rows are affected if a code
+// of that step or a later one already was.
+func (q *Queries) UseUserTotpStep(ctx context.Context, arg UseUserTotpStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useUserTotpStep, arg.TotpLastStep, arg.ID, arg.TotpLastStep_2)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :execrows
+
+UPDATE users
+SET email_verified_at = ?, updated_at = ?
+WHERE id = ? AND email = ?
+`
+
+type VerifyUserEmailParams struct {
+	EmailVerifiedAt sql.NullString `json:"email_verified_at"`
+	UpdatedAt       string         `json:"updated_at"`
+	ID              string         `json:"id"`
+	Email           string         `json:"email"`
+}
+
+// Marks the user's email as verified if it is still the address given.
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, verifyUserEmail,
+		arg.EmailVerifiedAt,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.Email,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
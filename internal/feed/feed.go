@@ -0,0 +1,89 @@
+// Package feed passes events to the subscribers of a topic, such as the
+// owner of a quiz watching attempts come in.
+package feed
+
+import "sync"
+
+// bufferSize is the number of events a subscriber can fall behind by
+// before it starts missing them.
+const bufferSize = 64
+
+// Event is something that happened, with data to show for it.
+type Event struct {
+	Type string
+	Data any
+}
+
+// Broker delivers published events to subscribers in this process.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan Event]struct{}
+	closed      bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[string]map[chan Event]struct{}{}}
+}
+
+// Subscribe returns a channel of the events published to topic from now on
+// and a function that ends the subscription. The channel is closed when the
+// subscription or the broker is.
+func (b *Broker) Subscribe(topic string) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan Event, bufferSize)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subscribers[topic] == nil {
+		b.subscribers[topic] = map[chan Event]struct{}{}
+	}
+	b.subscribers[topic][ch] = struct{}{}
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[topic][ch]; !ok {
+			return
+		}
+		delete(b.subscribers[topic], ch)
+		if len(b.subscribers[topic]) == 0 {
+			delete(b.subscribers, topic)
+		}
+		close(ch)
+	}
+}
+
+// Subscribed reports whether anyone is subscribed to topic, so publishers
+// can skip building events nobody will see.
+func (b *Broker) Subscribed(topic string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers[topic]) > 0
+}
+
+// Publish sends e to the subscribers of topic. It never blocks: subscribers
+// that have fallen too far behind miss the event.
+func (b *Broker) Publish(topic string, e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers[topic] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Close ends every subscription.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for topic, subscribers := range b.subscribers {
+		for ch := range subscribers {
+			close(ch)
+		}
+		delete(b.subscribers, topic)
+	}
+}
@@ -0,0 +1,51 @@
+package feed
+
+import "testing"
+
+func TestBroker(t *testing.T) {
+	b := NewBroker()
+	events, unsubscribe := b.Subscribe("quiz")
+	other, _ := b.Subscribe("other")
+	if !b.Subscribed("quiz") || b.Subscribed("nobody") {
+		t.Fatal("Subscribed doesn't match the subscriptions")
+	}
+
+	b.Publish("quiz", Event{Type: "attempt_started", Data: 1})
+	if e := <-events; e.Type != "attempt_started" || e.Data != 1 {
+		t.Errorf("got %+v, want the published event", e)
+	}
+	select {
+	case e := <-other:
+		t.Errorf("subscriber of another topic got %+v", e)
+	default:
+	}
+
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Error("channel still open after unsubscribing")
+	}
+	if b.Subscribed("quiz") {
+		t.Error("still subscribed after unsubscribing")
+	}
+	unsubscribe()
+
+	b.Close()
+	if _, ok := <-other; ok {
+		t.Error("channel still open after closing the broker")
+	}
+	late, _ := b.Subscribe("quiz")
+	if _, ok := <-late; ok {
+		t.Error("subscribing to a closed broker returned an open channel")
+	}
+}
+
+func TestPublishDoesNotBlock(t *testing.T) {
+	b := NewBroker()
+	events, _ := b.Subscribe("quiz")
+	for i := 0; i < bufferSize*2; i++ {
+		b.Publish("quiz", Event{Type: "attempt_started", Data: i})
+	}
+	if len(events) != bufferSize {
+		t.Errorf("%d events buffered, want %d", len(events), bufferSize)
+	}
+}
@@ -0,0 +1,161 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+const (
+	// sendBuffer is the number of messages queued for a client before it is
+	// considered too slow and disconnected.
+	sendBuffer = 32
+	// writeTimeout bounds the time spent writing one message.
+	writeTimeout = 10 * time.Second
+	// readLimit is the largest message accepted from a client.
+	readLimit = 4096
+	// joinTimeout is how long a client has to send its join message.
+	joinTimeout = 10 * time.Second
+)
+
+// client is one WebSocket connection to a session. Its fields are owned by
+// the session's goroutine, except send, which the connection's writer
+// drains.
+type client struct {
+	send chan []byte
+	// player is nil for the host.
+	player *player
+	closed bool
+	// reason is sent in the close frame once send is closed.
+	reason string
+}
+
+func newClient() *client {
+	return &client{send: make(chan []byte, sendBuffer)}
+}
+
+// ServeHost runs conn as the host's connection until either it or the
+// session ends. The connection must start with a join message holding the
+// host key.
+func (s *Session) ServeHost(ctx context.Context, conn *websocket.Conn) {
+	join, err := readJoin(ctx, conn)
+	if err == nil && !s.IsHostKey(join.Key) {
+		err = ErrInvalidHostKey
+	}
+	if err != nil {
+		rejectConn(ctx, conn, err)
+		return
+	}
+	c := newClient()
+	if !s.do(func() { s.attachHost(c) }) {
+		conn.Close(websocket.StatusGoingAway, ErrSessionEnded.Error())
+		return
+	}
+	s.serve(ctx, conn, c, s.handleHost)
+}
+
+// ServePlayer joins conn to the session and runs it until either it or the
+// session ends. The connection must start with a join message holding the
+// player's nickname. A player reconnects by also sending the token they
+// were given when they first joined.
+func (s *Session) ServePlayer(ctx context.Context, conn *websocket.Conn) {
+	join, err := readJoin(ctx, conn)
+	if err != nil {
+		rejectConn(ctx, conn, err)
+		return
+	}
+	c := newClient()
+	if !s.do(func() { err = s.attachPlayer(c, join.Nickname, join.Token) }) {
+		err = ErrSessionEnded
+	}
+	if err != nil {
+		rejectConn(ctx, conn, err)
+		return
+	}
+	s.serve(ctx, conn, c, s.handlePlayer)
+}
+
+// readJoin reads the join message a connection starts with.
+func readJoin(ctx context.Context, conn *websocket.Conn) (incoming, error) {
+	ctx, cancel := context.WithTimeout(ctx, joinTimeout)
+	defer cancel()
+	conn.SetReadLimit(readLimit)
+	_, data, err := conn.Read(ctx)
+	if err != nil {
+		return incoming{}, ErrJoinExpected
+	}
+	var msg incoming
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type != TypeJoin {
+		return incoming{}, ErrJoinExpected
+	}
+	return msg, nil
+}
+
+// serve reads messages from conn and hands them to handle on the session's
+// goroutine, while a second goroutine writes the messages queued for c.
+func (s *Session) serve(ctx context.Context, conn *websocket.Conn, c *client, handle func(*client, incoming)) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	conn.SetReadLimit(readLimit)
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		writeLoop(ctx, conn, c)
+	}()
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			break
+		}
+		var msg incoming
+		if err := json.Unmarshal(data, &msg); err != nil {
+			msg = incoming{Type: "invalid"}
+		}
+		if !s.do(func() { handle(c, msg) }) {
+			break
+		}
+	}
+	s.do(func() { s.detach(c) })
+	// Let the writer finish sending what is queued, such as the final
+	// leaderboard, before the connection goes away.
+	select {
+	case <-written:
+	case <-time.After(writeTimeout):
+	}
+	cancel()
+	conn.CloseNow()
+}
+
+// writeLoop writes the messages queued for c until the session closes its
+// queue or ctx is cancelled.
+func writeLoop(ctx context.Context, conn *websocket.Conn, c *client) {
+	for {
+		select {
+		case data, ok := <-c.send:
+			if !ok {
+				conn.Close(websocket.StatusNormalClosure, c.reason)
+				return
+			}
+			writeCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+			err := conn.Write(writeCtx, websocket.MessageText, data)
+			cancel()
+			if err != nil {
+				conn.CloseNow()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// rejectConn tells a client why it can't join and closes the connection.
+func rejectConn(ctx context.Context, conn *websocket.Conn, err error) {
+	data, _ := json.Marshal(errorMessage{Type: TypeError, Error: err.Error()})
+	writeCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	conn.Write(writeCtx, websocket.MessageText, data)
+	conn.Close(websocket.StatusPolicyViolation, err.Error())
+}
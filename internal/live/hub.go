@@ -0,0 +1,105 @@
+package live
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/Corogura/quizmaker/internal/quizio"
+)
+
+// codeAlphabet leaves out characters that are easily confused when a join
+// code is read off a projector.
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// CodeLength is the length of a join code.
+const CodeLength = 6
+
+// Hub keeps track of the live sessions running in this process.
+type Hub struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func NewHub() *Hub {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Hub{
+		sessions: map[string]*Session{},
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start creates a session for the questions of a quiz and runs it until the
+// host ends it, the host stays away too long or the hub is closed. It
+// returns the session and the key its host connects with.
+func (h *Hub) Start(quizID string, questions []quizio.Question) (*Session, string, error) {
+	if len(questions) == 0 {
+		return nil, "", errors.New("live sessions need at least 1 question")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.ctx.Err() != nil {
+		return nil, "", ErrSessionEnded
+	}
+	var code string
+	for {
+		var err error
+		code, err = newCode()
+		if err != nil {
+			return nil, "", err
+		}
+		if _, taken := h.sessions[code]; !taken {
+			break
+		}
+	}
+	s, err := newSession(code, quizID, questions)
+	if err != nil {
+		return nil, "", err
+	}
+	h.sessions[code] = s
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		s.run(h.ctx)
+		h.mu.Lock()
+		delete(h.sessions, code)
+		h.mu.Unlock()
+	}()
+	return s, s.hostKey, nil
+}
+
+// Session returns the running session with the join code, which is not case
+// sensitive.
+func (h *Hub) Session(code string) (*Session, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.sessions[strings.ToUpper(strings.TrimSpace(code))]
+	return s, ok
+}
+
+// Close ends every session and waits for them to finish.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	h.cancel()
+	h.mu.Unlock()
+	h.wg.Wait()
+}
+
+func newCode() (string, error) {
+	b := make([]byte, CodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		// 256 is a multiple of len(codeAlphabet), so every character is
+		// equally likely.
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,229 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/quizio"
+	"github.com/coder/websocket"
+)
+
+func TestPoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		correct bool
+		elapsed time.Duration
+		want    int
+	}{
+		{name: "Instant", correct: true, elapsed: 0, want: 1000},
+		{name: "Half time", correct: true, elapsed: 10 * time.Second, want: 750},
+		{name: "At the limit", correct: true, elapsed: 20 * time.Second, want: 500},
+		{name: "Past the limit", correct: true, elapsed: 30 * time.Second, want: 500},
+		{name: "Wrong", correct: false, elapsed: 0, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Points(tt.correct, tt.elapsed, 20*time.Second); got != tt.want {
+				t.Errorf("Points() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	players := []*player{
+		{nickname: "carol", score: 500},
+		{nickname: "Bob", score: 900},
+		{nickname: "alice", score: 500},
+	}
+	got := leaderboard(players)
+	want := []standing{
+		{Rank: 1, Nickname: "Bob", Score: 900},
+		{Rank: 2, Nickname: "alice", Score: 500},
+		{Rank: 2, Nickname: "carol", Score: 500},
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("standing %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// testConn is a WebSocket client for a session served by a test server.
+type testConn struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+func dial(t *testing.T, server *httptest.Server, path string) *testConn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+path, nil)
+	if err != nil {
+		t.Fatalf("dial %s: %v", path, err)
+	}
+	t.Cleanup(func() { conn.CloseNow() })
+	return &testConn{t: t, conn: conn}
+}
+
+func (c *testConn) send(msg incoming) {
+	c.t.Helper()
+	data, _ := json.Marshal(msg)
+	if err := c.conn.Write(context.Background(), websocket.MessageText, data); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+}
+
+// expect reads messages until one of the given type arrives and decodes it
+// into v.
+func (c *testConn) expect(messageType string, v any) {
+	c.t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		_, data, err := c.conn.Read(ctx)
+		if err != nil {
+			c.t.Fatalf("waiting for %q: %v", messageType, err)
+		}
+		var header struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal(data, &header)
+		if header.Type == messageType {
+			if v != nil {
+				if err := json.Unmarshal(data, v); err != nil {
+					c.t.Fatalf("decode %q: %v", messageType, err)
+				}
+			}
+			return
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	hub := NewHub()
+	defer hub.Close()
+	questions := []quizio.Question{
+		{Type: quizio.TypeMultipleChoice, Text: "2+2?", Choices: []string{"3", "4"}, Answer: 2},
+		{Type: quizio.TypeShortAnswer, Text: "Capital of France?", AcceptedAnswers: []string{"Paris"}},
+	}
+	session, hostKey, err := hub.Start("quiz", questions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !session.IsHostKey(hostKey) || session.IsHostKey("wrong") {
+		t.Fatal("IsHostKey doesn't match the host key")
+	}
+	if s, ok := hub.Session(strings.ToLower(session.Code)); !ok || s != session {
+		t.Fatal("session not found by its code")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		if r.URL.Path == "/host" {
+			session.ServeHost(r.Context(), conn)
+		} else {
+			session.ServePlayer(r.Context(), conn)
+		}
+	}))
+	defer server.Close()
+
+	intruder := dial(t, server, "/host")
+	intruder.send(incoming{Type: TypeJoin, Key: "wrong"})
+	var rejected errorMessage
+	intruder.expect(TypeError, &rejected)
+	if rejected.Error != ErrInvalidHostKey.Error() {
+		t.Errorf("wrong host key error = %q, want %q", rejected.Error, ErrInvalidHostKey)
+	}
+
+	host := dial(t, server, "/host")
+	host.send(incoming{Type: TypeJoin, Key: hostKey})
+	host.expect(TypeLobby, nil)
+	alice := dial(t, server, "/play")
+	alice.send(incoming{Type: TypeJoin, Nickname: "alice"})
+	var joined joinedMessage
+	alice.expect(TypeJoined, &joined)
+	bob := dial(t, server, "/play")
+	bob.send(incoming{Type: TypeJoin, Nickname: "Bob"})
+	bob.expect(TypeJoined, nil)
+	var lobby lobbyMessage
+	bob.expect(TypeLobby, &lobby)
+	if len(lobby.Players) != 2 {
+		t.Errorf("lobby players = %v, want alice and Bob", lobby.Players)
+	}
+
+	taken := dial(t, server, "/play")
+	taken.send(incoming{Type: TypeJoin, Nickname: "ALICE"})
+	taken.expect(TypeError, &rejected)
+	if rejected.Error != ErrNicknameTaken.Error() {
+		t.Errorf("duplicate nickname error = %q, want %q", rejected.Error, ErrNicknameTaken)
+	}
+
+	host.send(incoming{Type: TypeNext})
+	var question questionMessage
+	alice.expect(TypeQuestion, &question)
+	bob.expect(TypeQuestion, nil)
+	if question.Index != 1 || question.QuestionText != "2+2?" || len(question.Choices) != 2 {
+		t.Errorf("question = %+v", question)
+	}
+	alice.send(incoming{Type: TypeAnswer, Answer: 2})
+	alice.expect(TypeAnswered, nil)
+	bob.send(incoming{Type: TypeAnswer, Answer: 1})
+
+	// Everyone has answered, so the question closes without the host.
+	var result resultMessage
+	alice.expect(TypeResult, &result)
+	if !result.Correct || result.Points <= MaxPoints/2 || result.Score != result.Points {
+		t.Errorf("alice's result = %+v", result)
+	}
+	bob.expect(TypeResult, &result)
+	if result.Correct || result.Points != 0 {
+		t.Errorf("bob's result = %+v", result)
+	}
+	var board leaderboardMessage
+	host.expect(TypeLeaderboard, &board)
+	if len(board.Leaderboard) != 2 || board.Leaderboard[0].Nickname != "alice" || board.CorrectAnswerText != "4" {
+		t.Errorf("leaderboard = %+v", board)
+	}
+
+	// Alice reconnects with her token and keeps her score.
+	alice.conn.CloseNow()
+	alice = dial(t, server, "/play")
+	alice.send(incoming{Type: TypeJoin, Nickname: "alice", Token: joined.PlayerToken})
+	alice.expect(TypeJoined, nil)
+
+	host.send(incoming{Type: TypeNext})
+	bob.expect(TypeQuestion, nil)
+	bob.send(incoming{Type: TypeAnswer, AnswerText: " paris "})
+	bob.expect(TypeAnswered, nil)
+	host.send(incoming{Type: TypeEnd})
+	host.expect(TypeFinished, &board)
+	if board.Leaderboard[0].Score == 0 || board.Leaderboard[1].Score == 0 {
+		t.Errorf("final leaderboard = %+v, want both players to have scored", board)
+	}
+
+	select {
+	case <-session.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session didn't end")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := hub.Session(session.Code); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("ended session is still in the hub")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
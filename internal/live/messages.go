@@ -0,0 +1,105 @@
+package live
+
+import "github.com/Corogura/quizmaker/internal/quizio"
+
+// Types of the messages clients send.
+const (
+	// TypeJoin is the first message on every connection. The host sends
+	// the host key and players their nickname and, when reconnecting, their
+	// token. They are sent here rather than in the URL, which gets logged.
+	TypeJoin = "join"
+	// TypeAnswer answers the open question. Players only.
+	TypeAnswer = "answer"
+	// TypeNext closes the open question, or opens the next one when none
+	// is open. Host only.
+	TypeNext = "next"
+	// TypeEnd ends the session. Host only.
+	TypeEnd = "end"
+)
+
+// Types of the messages the session sends.
+const (
+	TypeJoined      = "joined"
+	TypeLobby       = "lobby"
+	TypeQuestion    = "question"
+	TypeAnswered    = "answered"
+	TypeResult      = "result"
+	TypeLeaderboard = "leaderboard"
+	TypeFinished    = "finished"
+	TypeError       = "error"
+)
+
+// incoming is a message from a client.
+type incoming struct {
+	Type       string `json:"type"`
+	Answer     int    `json:"answer"`
+	AnswerText string `json:"answer_text"`
+	// Key, Nickname and Token are only sent in a join message.
+	Key      string `json:"key,omitempty"`
+	Nickname string `json:"nickname,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+type errorMessage struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// joinedMessage confirms a join. Sending PlayerToken back when reconnecting
+// reclaims the nickname and its score.
+type joinedMessage struct {
+	Type        string `json:"type"`
+	Nickname    string `json:"nickname"`
+	PlayerToken string `json:"player_token"`
+}
+
+// lobbyMessage lists the players who are connected.
+type lobbyMessage struct {
+	Type    string   `json:"type"`
+	Code    string   `json:"code"`
+	Players []string `json:"players"`
+}
+
+type questionMessage struct {
+	Type             string              `json:"type"`
+	Index            int                 `json:"index"`
+	Total            int                 `json:"total"`
+	QuestionType     quizio.QuestionType `json:"question_type"`
+	QuestionText     string              `json:"question_text"`
+	Choices          []string            `json:"choices,omitempty"`
+	RemainingSeconds int                 `json:"remaining_seconds"`
+}
+
+// answeredMessage tells the host how many players have answered so far.
+type answeredMessage struct {
+	Type     string `json:"type"`
+	Answered int    `json:"answered"`
+	Players  int    `json:"players"`
+}
+
+// resultMessage tells a player how they did on the question that just
+// closed.
+type resultMessage struct {
+	Type              string `json:"type"`
+	Answered          bool   `json:"answered"`
+	Correct           bool   `json:"correct"`
+	Points            int    `json:"points"`
+	Score             int    `json:"score"`
+	CorrectAnswerText string `json:"correct_answer_text"`
+}
+
+// leaderboardMessage is sent to everyone after each question, and as
+// TypeFinished when the session ends.
+type leaderboardMessage struct {
+	Type              string     `json:"type"`
+	CorrectAnswerText string     `json:"correct_answer_text,omitempty"`
+	Leaderboard       []standing `json:"leaderboard"`
+}
+
+type standing struct {
+	Rank     int    `json:"rank"`
+	Nickname string `json:"nickname"`
+	Score    int    `json:"score"`
+	// Points were scored on the last question.
+	Points int `json:"points"`
+}
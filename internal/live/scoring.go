@@ -0,0 +1,48 @@
+package live
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxPoints is scored by a correct answer given the moment a question
+// opens. Slower correct answers score less, down to half of MaxPoints at the
+// time limit; wrong answers score nothing.
+const MaxPoints = 1000
+
+// Points returns the points for an answer given elapsed after its question
+// opened, with limit to answer it.
+func Points(correct bool, elapsed, limit time.Duration) int {
+	if !correct {
+		return 0
+	}
+	fraction := 0.0
+	if limit > 0 {
+		fraction = min(max(float64(elapsed)/float64(limit), 0), 1)
+	}
+	return int(math.Round(MaxPoints * (1 - fraction/2)))
+}
+
+// leaderboard ranks players by score. Players with equal scores share a rank
+// and are listed by nickname.
+func leaderboard(players []*player) []standing {
+	sorted := make([]*player, len(players))
+	copy(sorted, players)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].score != sorted[j].score {
+			return sorted[i].score > sorted[j].score
+		}
+		return strings.ToLower(sorted[i].nickname) < strings.ToLower(sorted[j].nickname)
+	})
+	standings := make([]standing, len(sorted))
+	for i, p := range sorted {
+		rank := i + 1
+		if i > 0 && p.score == sorted[i-1].score {
+			rank = standings[i-1].Rank
+		}
+		standings[i] = standing{Rank: rank, Nickname: p.nickname, Score: p.score, Points: p.points}
+	}
+	return standings
+}
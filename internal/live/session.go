@@ -0,0 +1,411 @@
+// Package live runs live quiz sessions: a host moves a group of players
+// through a quiz one question at a time, and everyone is kept up to date over
+// WebSocket connections.
+package live
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Corogura/quizmaker/internal/quizio"
+)
+
+const (
+	// DefaultTimeLimit is how long a question without a time limit of its
+	// own stays open.
+	DefaultTimeLimit = 20 * time.Second
+	// MaxNickname is the maximum length of a nickname in characters.
+	MaxNickname = 20
+	// hostTimeout is how long a session waits for its host to connect or
+	// reconnect before it ends.
+	hostTimeout = 5 * time.Minute
+)
+
+var (
+	ErrSessionEnded    = errors.New("session has ended")
+	ErrInvalidNickname = errors.New("nickname must be 1 to 20 characters")
+	ErrNicknameTaken   = errors.New("nickname is taken")
+	ErrInvalidHostKey  = errors.New("invalid host key")
+	ErrJoinExpected    = errors.New("first message must be a join message")
+)
+
+// Session is one live run of a quiz. All of its state is owned by the
+// goroutine started by Hub.Start; everything else talks to it through
+// do.
+type Session struct {
+	// Code is the join code players use to find the session.
+	Code   string
+	QuizID string
+
+	hostKey   string
+	questions []quizio.Question
+	events    chan func()
+	done      chan struct{}
+
+	host      *client
+	hostTimer *time.Timer
+	players   []*player
+	// current is the index of the latest question, or -1 before the first.
+	current       int
+	open          bool
+	openedAt      time.Time
+	questionTimer *time.Timer
+	ended         bool
+}
+
+// player is a participant. Players stay on the leaderboard while they are
+// disconnected, and can reconnect with their token.
+type player struct {
+	nickname string
+	token    string
+	client   *client
+	score    int
+	// answered, correct and points describe the answer to the current
+	// question.
+	answered bool
+	correct  bool
+	points   int
+}
+
+func newSession(code, quizID string, questions []quizio.Question) (*Session, error) {
+	hostKey, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		Code:      code,
+		QuizID:    quizID,
+		hostKey:   hostKey,
+		questions: questions,
+		events:    make(chan func()),
+		done:      make(chan struct{}),
+		current:   -1,
+	}, nil
+}
+
+// IsHostKey reports whether key is the key given to the host when the
+// session started.
+func (s *Session) IsHostKey(key string) bool {
+	return subtle.ConstantTimeCompare([]byte(key), []byte(s.hostKey)) == 1
+}
+
+// Done is closed when the session has ended.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// do runs f on the session's goroutine and waits until it has. It returns
+// false without running f if the session has ended.
+func (s *Session) do(f func()) bool {
+	ran := make(chan struct{})
+	select {
+	case s.events <- func() { f(); close(ran) }:
+		<-ran
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// run processes events until the session ends or ctx is cancelled.
+func (s *Session) run(ctx context.Context) {
+	s.hostTimer = time.NewTimer(hostTimeout)
+	defer s.shutdown()
+	for !s.ended {
+		var timeout <-chan time.Time
+		if s.questionTimer != nil {
+			timeout = s.questionTimer.C
+		}
+		select {
+		case f := <-s.events:
+			f()
+		case <-timeout:
+			s.closeQuestion()
+		case <-s.hostTimer.C:
+			if s.host == nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// shutdown disconnects everyone once the session has ended.
+func (s *Session) shutdown() {
+	close(s.done)
+	s.hostTimer.Stop()
+	if s.questionTimer != nil {
+		s.questionTimer.Stop()
+	}
+	s.disconnect(s.host, "session ended")
+	for _, p := range s.players {
+		s.disconnect(p.client, "session ended")
+	}
+}
+
+func (s *Session) attachHost(c *client) {
+	// A host that connects again, say from another tab, takes over.
+	s.disconnect(s.host, "host connected elsewhere")
+	s.host = c
+	s.hostTimer.Stop()
+	s.send(c, s.lobby())
+	if s.current >= 0 {
+		if s.open {
+			s.send(c, s.questionMessage())
+			s.send(c, s.answered())
+		} else {
+			s.send(c, s.leaderboard(TypeLeaderboard))
+		}
+	}
+}
+
+func (s *Session) attachPlayer(c *client, nickname, token string) error {
+	nickname = strings.Join(strings.Fields(nickname), " ")
+	if nickname == "" || utf8.RuneCountInString(nickname) > MaxNickname {
+		return ErrInvalidNickname
+	}
+	var p *player
+	for _, existing := range s.players {
+		if strings.EqualFold(existing.nickname, nickname) {
+			if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(existing.token)) != 1 {
+				return ErrNicknameTaken
+			}
+			p = existing
+			s.disconnect(p.client, "player connected elsewhere")
+		}
+	}
+	if p == nil {
+		token, err := randomToken()
+		if err != nil {
+			return err
+		}
+		p = &player{nickname: nickname, token: token}
+		s.players = append(s.players, p)
+	}
+	p.client = c
+	c.player = p
+	s.send(c, joinedMessage{Type: TypeJoined, Nickname: p.nickname, PlayerToken: p.token})
+	s.broadcast(s.lobby())
+	if s.open && !p.answered {
+		s.send(c, s.questionMessage())
+	}
+	return nil
+}
+
+// detach forgets a client whose connection has closed.
+func (s *Session) detach(c *client) {
+	switch {
+	case c == s.host:
+		s.host = nil
+		s.hostTimer.Reset(hostTimeout)
+	case c.player != nil && c.player.client == c:
+		c.player.client = nil
+		s.broadcast(s.lobby())
+	default:
+		return
+	}
+	s.disconnect(c, "")
+}
+
+func (s *Session) handleHost(c *client, msg incoming) {
+	if c != s.host {
+		return
+	}
+	switch msg.Type {
+	case TypeNext:
+		switch {
+		case s.open:
+			s.closeQuestion()
+		case s.current+1 < len(s.questions):
+			s.openQuestion(s.current + 1)
+		default:
+			s.finish()
+		}
+	case TypeEnd:
+		s.finish()
+	default:
+		s.send(c, errorMessage{Type: TypeError, Error: "unknown message type"})
+	}
+}
+
+func (s *Session) handlePlayer(c *client, msg incoming) {
+	p := c.player
+	if p == nil || p.client != c {
+		return
+	}
+	if msg.Type != TypeAnswer {
+		s.send(c, errorMessage{Type: TypeError, Error: "unknown message type"})
+		return
+	}
+	if !s.open {
+		s.send(c, errorMessage{Type: TypeError, Error: "no question is open"})
+		return
+	}
+	if p.answered {
+		s.send(c, errorMessage{Type: TypeError, Error: "question has already been answered"})
+		return
+	}
+	q := s.questions[s.current]
+	if q.HasChoices() && q.Choice(msg.Answer) == "" {
+		s.send(c, errorMessage{Type: TypeError, Error: "answer does not refer to a choice"})
+		return
+	}
+	p.answered = true
+	p.correct = q.Check(msg.Answer, msg.AnswerText)
+	p.points = Points(p.correct, time.Since(s.openedAt), s.timeLimit(q))
+	s.send(c, s.answered())
+	s.send(s.host, s.answered())
+	// Close the question early once everyone connected has answered.
+	for _, other := range s.players {
+		if other.client != nil && !other.answered {
+			return
+		}
+	}
+	s.closeQuestion()
+}
+
+func (s *Session) openQuestion(i int) {
+	s.current = i
+	s.open = true
+	s.openedAt = time.Now()
+	for _, p := range s.players {
+		p.answered, p.correct, p.points = false, false, 0
+	}
+	s.questionTimer = time.NewTimer(s.timeLimit(s.questions[i]))
+	s.broadcast(s.questionMessage())
+}
+
+func (s *Session) closeQuestion() {
+	if !s.open {
+		return
+	}
+	s.open = false
+	s.questionTimer.Stop()
+	s.questionTimer = nil
+	correctAnswer := s.questions[s.current].CorrectAnswerText()
+	for _, p := range s.players {
+		p.score += p.points
+		s.send(p.client, resultMessage{
+			Type:              TypeResult,
+			Answered:          p.answered,
+			Correct:           p.correct,
+			Points:            p.points,
+			Score:             p.score,
+			CorrectAnswerText: correctAnswer,
+		})
+	}
+	s.broadcast(s.leaderboard(TypeLeaderboard))
+}
+
+// finish ends the session with the final leaderboard.
+func (s *Session) finish() {
+	s.closeQuestion()
+	s.broadcast(s.leaderboard(TypeFinished))
+	s.ended = true
+}
+
+func (s *Session) timeLimit(q quizio.Question) time.Duration {
+	if q.TimeLimit > 0 {
+		return time.Duration(q.TimeLimit) * time.Second
+	}
+	return DefaultTimeLimit
+}
+
+func (s *Session) lobby() lobbyMessage {
+	msg := lobbyMessage{Type: TypeLobby, Code: s.Code, Players: []string{}}
+	for _, p := range s.players {
+		if p.client != nil {
+			msg.Players = append(msg.Players, p.nickname)
+		}
+	}
+	return msg
+}
+
+func (s *Session) questionMessage() questionMessage {
+	q := s.questions[s.current]
+	remaining := s.timeLimit(q) - time.Since(s.openedAt)
+	msg := questionMessage{
+		Type:             TypeQuestion,
+		Index:            s.current + 1,
+		Total:            len(s.questions),
+		QuestionType:     q.Type,
+		QuestionText:     q.Text,
+		RemainingSeconds: int(max(remaining.Round(time.Second), 0) / time.Second),
+	}
+	if q.HasChoices() {
+		msg.Choices = q.Choices
+	}
+	return msg
+}
+
+func (s *Session) answered() answeredMessage {
+	msg := answeredMessage{Type: TypeAnswered}
+	for _, p := range s.players {
+		if p.answered {
+			msg.Answered++
+		}
+		if p.client != nil || p.answered {
+			msg.Players++
+		}
+	}
+	return msg
+}
+
+func (s *Session) leaderboard(messageType string) leaderboardMessage {
+	msg := leaderboardMessage{Type: messageType, Leaderboard: leaderboard(s.players)}
+	if s.current >= 0 {
+		msg.CorrectAnswerText = s.questions[s.current].CorrectAnswerText()
+	}
+	return msg
+}
+
+// send queues msg for c. A client that can't keep up is disconnected rather
+// than allowed to hold up the session.
+func (s *Session) send(c *client, msg any) {
+	if c == nil || c.closed {
+		return
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+		s.detach(c)
+	}
+}
+
+func (s *Session) broadcast(msg any) {
+	s.send(s.host, msg)
+	for _, p := range s.players {
+		s.send(p.client, msg)
+	}
+}
+
+// disconnect closes the connection of c once the messages queued for it
+// have been written.
+func (s *Session) disconnect(c *client, reason string) {
+	if c == nil || c.closed {
+		return
+	}
+	c.closed = true
+	c.reason = reason
+	close(c.send)
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,119 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	data, err := format("Quiz Maker <noreply@example.com>", Message{
+		To:      "alice@example.com",
+		Subject: "Hello",
+		Body:    "Line one\nLine two\n",
+	}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "From: Quiz Maker <noreply@example.com>\r\n" +
+		"To: alice@example.com\r\n" +
+		"Subject: Hello\r\n" +
+		"Date: Wed, 01 May 2024 12:00:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"Line one\r\nLine two\r\n"
+	if string(data) != want {
+		t.Errorf("format() = %q, want %q", data, want)
+	}
+
+	if _, err := format("noreply@example.com", Message{To: "alice@example.com", Subject: "Hi\r\nBcc: eve@example.com"}, now); err == nil {
+		t.Error("format() accepted a subject with a line break")
+	}
+}
+
+func TestLogMailer(t *testing.T) {
+	var b bytes.Buffer
+	m := NewLogMailer("noreply@example.com", &b)
+	if err := m.Send(context.Background(), Message{To: "alice@example.com", Subject: "Hello", Body: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if !strings.HasPrefix(out, "From quizmaker ") || !strings.Contains(out, "\nTo: alice@example.com\n") || !strings.Contains(out, "\n\nHi\n") {
+		t.Errorf("LogMailer wrote %q", out)
+	}
+}
+
+// fakeSMTPServer accepts one message and sends what it received on the
+// returned channel.
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ready")
+		var transcript strings.Builder
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			transcript.WriteString(line + "\n")
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				tp.PrintfLine("250 localhost")
+			case line == "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, _ := bufio.NewReader(tp.DotReader()).ReadString(0)
+				transcript.WriteString(data)
+				tp.PrintfLine("250 ok")
+			case line == "QUIT":
+				tp.PrintfLine("221 bye")
+				received <- transcript.String()
+				return
+			default:
+				tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	m := SMTPMailer{Addr: addr, From: "Quiz Maker <noreply@example.com>"}
+	err := m.Send(context.Background(), Message{To: "alice@example.com", Subject: "Reset", Body: "Your link"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case transcript := <-received:
+		for _, want := range []string{"MAIL FROM:<noreply@example.com>", "RCPT TO:<alice@example.com>", "Subject: Reset", "Your link"} {
+			if !strings.Contains(transcript, want) {
+				t.Errorf("server didn't receive %q in:\n%s", want, transcript)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server received nothing")
+	}
+
+	if err := m.Send(context.Background(), Message{To: "not an address"}); err == nil {
+		t.Error("Send() accepted an invalid recipient")
+	}
+}
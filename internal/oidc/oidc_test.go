@@ -0,0 +1,264 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockProvider is an identity provider that logs in whoever asks as the
+// subject it is given.
+type mockProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu sync.Mutex
+	// codes are the authorization requests by the codes issued for them.
+	codes map[string]url.Values
+	// claims are added to every ID token, and may override the defaults.
+	claims jwt.MapClaims
+}
+
+const (
+	testClientID     = "quizmaker"
+	testClientSecret = "s3cret"
+	testRedirectURL  = "http://localhost:8080/auth/oidc/callback"
+)
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockProvider{t: t, key: key, codes: map[string]url.Values{}, claims: jwt.MapClaims{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                           m.server.URL,
+			"authorization_endpoint":           m.server.URL + "/authorize",
+			"token_endpoint":                   m.server.URL + "/token",
+			"jwks_uri":                         m.server.URL + "/jwks",
+			"code_challenge_methods_supported": []string{"S256"},
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		code := randomString()
+		m.mu.Lock()
+		m.codes[code] = query
+		m.mu.Unlock()
+		back, _ := url.Parse(query.Get("redirect_uri"))
+		back.RawQuery = url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+		http.Redirect(w, r, back.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", m.token)
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "mock-key",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	fail := func(reason string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": reason})
+	}
+	id, secret, _ := r.BasicAuth()
+	if id != testClientID || secret != testClientSecret {
+		fail("bad client credentials")
+		return
+	}
+	r.ParseForm()
+	m.mu.Lock()
+	request, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+	if !ok {
+		fail("unknown code")
+		return
+	}
+	if r.PostForm.Get("redirect_uri") != request.Get("redirect_uri") {
+		fail("redirect_uri doesn't match")
+		return
+	}
+	if request.Get("code_challenge_method") != "S256" || codeChallenge(r.PostForm.Get("code_verifier")) != request.Get("code_challenge") {
+		fail("PKCE verification failed")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"id_token":     m.idToken(request.Get("nonce")),
+	})
+}
+
+func (m *mockProvider) idToken(nonce string) string {
+	claims := jwt.MapClaims{
+		"iss":            m.server.URL,
+		"sub":            "user-123",
+		"aud":            testClientID,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"name":           "Alice",
+	}
+	m.mu.Lock()
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+	m.mu.Unlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "mock-key"
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return signed
+}
+
+func (m *mockProvider) provider() *Provider {
+	return NewProvider(Config{
+		Issuer:       m.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	}, m.server.Client())
+}
+
+// login follows the provider's authorization URL as a browser would, and
+// returns the query the user is sent back with.
+func (m *mockProvider) login(authURL string) url.Values {
+	m.t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	resp.Body.Close()
+	back, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return back.Query()
+}
+
+func TestLogin(t *testing.T) {
+	m := newMockProvider(t)
+	p := m.provider()
+	ctx := context.Background()
+	flow := NewFlow()
+	authURL, err := p.AuthCodeURL(ctx, flow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authURL, m.server.URL+"/authorize?") || !strings.Contains(authURL, "scope=openid+email+profile") {
+		t.Errorf("AuthCodeURL() = %s", authURL)
+	}
+	back := m.login(authURL)
+	if back.Get("state") != flow.State {
+		t.Fatalf("state = %q, want %q", back.Get("state"), flow.State)
+	}
+	claims, err := p.Exchange(ctx, back.Get("code"), flow)
+	if err != nil {
+		t.Fatalf("Exchange() error: %v", err)
+	}
+	want := Claims{Subject: "user-123", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+	if claims != want {
+		t.Errorf("Exchange() = %+v, want %+v", claims, want)
+	}
+
+	if _, err := p.Exchange(ctx, back.Get("code"), flow); err == nil {
+		t.Error("Exchange() accepted a code twice")
+	}
+	back = m.login(authURL)
+	wrongVerifier := flow
+	wrongVerifier.Verifier = NewFlow().Verifier
+	if _, err := p.Exchange(ctx, back.Get("code"), wrongVerifier); err == nil || !strings.Contains(err.Error(), "PKCE") {
+		t.Errorf("Exchange() with the wrong verifier error = %v", err)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	m := newMockProvider(t)
+	p := m.provider()
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		nonce  string
+		ok     bool
+	}{
+		{"valid", jwt.MapClaims{}, "n", true},
+		{"email_verified as a string", jwt.MapClaims{"email_verified": "true"}, "n", true},
+		{"wrong nonce", jwt.MapClaims{}, "other", false},
+		{"wrong audience", jwt.MapClaims{"aud": "someone-else"}, "n", false},
+		{"several audiences without azp", jwt.MapClaims{"aud": []string{testClientID, "other"}}, "n", false},
+		{"several audiences with azp", jwt.MapClaims{"aud": []string{testClientID, "other"}, "azp": testClientID}, "n", true},
+		{"wrong issuer", jwt.MapClaims{"iss": "https://evil.example.com"}, "n", false},
+		{"expired", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}, "n", false},
+		{"no subject", jwt.MapClaims{"sub": ""}, "n", false},
+	}
+	for _, tt := range tests {
+		m.mu.Lock()
+		m.claims = tt.claims
+		m.mu.Unlock()
+		_, err := p.VerifyIDToken(ctx, m.idToken("n"), tt.nonce)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: VerifyIDToken() error = %v, want ok = %v", tt.name, err, tt.ok)
+		}
+	}
+
+	// A token signed with another key, or as an HMAC with the public key,
+	// is rejected.
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": m.server.URL, "sub": "user-123", "aud": testClientID, "nonce": "n",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	forged.Header["kid"] = "mock-key"
+	signed, _ := forged.SignedString(other)
+	if _, err := p.VerifyIDToken(ctx, signed, "n"); err == nil {
+		t.Error("VerifyIDToken() accepted a token signed with another key")
+	}
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, forged.Claims)
+	hmac.Header["kid"] = "mock-key"
+	signed, _ = hmac.SignedString(m.key.PublicKey.N.Bytes())
+	if _, err := p.VerifyIDToken(ctx, signed, "n"); err == nil {
+		t.Error("VerifyIDToken() accepted an HMAC token")
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockProvider(t)
+	p := NewProvider(Config{Issuer: m.server.URL + "/", ClientID: testClientID}, m.server.Client())
+	if _, err := p.AuthCodeURL(context.Background(), NewFlow()); err == nil {
+		t.Error("AuthCodeURL() accepted a discovery document for another issuer")
+	}
+}
@@ -0,0 +1,179 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+func TestEncodeVersion(t *testing.T) {
+	tests := []struct {
+		length int
+		size   int
+	}{
+		{0, 21},
+		{14, 21},
+		{15, 25},
+		{106, 41},
+		{107, 45},
+		{MaxLength, 57},
+	}
+	for _, tt := range tests {
+		c, err := Encode(bytes.Repeat([]byte("a"), tt.length))
+		if err != nil {
+			t.Fatalf("Encode(%d bytes) error: %v", tt.length, err)
+		}
+		if c.Size != tt.size {
+			t.Errorf("Encode(%d bytes) size = %d, want %d", tt.length, c.Size, tt.size)
+		}
+	}
+	if _, err := Encode(bytes.Repeat([]byte("a"), MaxLength+1)); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Encode(%d bytes) error = %v, want ErrTooLong", MaxLength+1, err)
+	}
+}
+
+// TestRSRemainder checks the error correction of the data of "HELLO WORLD"
+// in version 1 at level M, a worked example of the standard.
+func TestRSRemainder(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsGenerator(len(want))); !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder() = %v, want %v", got, want)
+	}
+}
+
+func TestFunctionPatterns(t *testing.T) {
+	c := encode([]byte("otpauth://totp/Quiz%20Maker:alice@example.com"), 3, 0)
+	finder := []string{
+		"11111110",
+		"10000010",
+		"10111010",
+		"10111010",
+		"10111010",
+		"10000010",
+		"11111110",
+		"00000000",
+	}
+	corners := [][2]int{{0, 0}, {c.Size - 8, 0}, {0, c.Size - 8}}
+	for _, corner := range corners {
+		for y, row := range finder {
+			for x := range row {
+				// The pattern is mirrored to face the middle of the code.
+				fx, fy := x, y
+				if corner[0] > 0 {
+					fx = 7 - x
+				}
+				if corner[1] > 0 {
+					fy = 7 - y
+				}
+				want := finder[fy][fx] == '1'
+				if got := c.Dark(corner[0]+x, corner[1]+y); got != want {
+					t.Fatalf("finder at %v: module %d,%d = %v, want %v", corner, x, y, got, want)
+				}
+			}
+		}
+	}
+
+	// Level M with mask 0 has the format bits 101010000010010, read from
+	// the right of the top left finder and up from the bottom of it.
+	var format strings.Builder
+	for x := 0; x <= 8; x++ {
+		if x == 6 {
+			continue
+		}
+		format.WriteString(bit(c.Dark(x, 8)))
+	}
+	for y := 7; y >= 0; y-- {
+		if y == 6 {
+			continue
+		}
+		format.WriteString(bit(c.Dark(8, y)))
+	}
+	if got, want := format.String(), "101010000010010"; got != want {
+		t.Errorf("format bits = %s, want %s", got, want)
+	}
+}
+
+func bit(dark bool) string {
+	if dark {
+		return "1"
+	}
+	return "0"
+}
+
+func TestSVG(t *testing.T) {
+	c, err := Encode([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := c.SVG()
+	if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 29 29"`) {
+		t.Errorf("SVG() = %.80s...", svg)
+	}
+	// The top left module is part of a finder, drawn inside the quiet zone.
+	if !strings.Contains(svg, `d="M4 4h1v1h-1z`) {
+		t.Error("SVG() doesn't start with the top left module")
+	}
+}
+
+// TestDecode reads codes back with an independent decoder, in each version
+// and with each mask.
+func TestDecode(t *testing.T) {
+	for version := 1; version <= 10; version++ {
+		// The most bytes the version holds, so that every codeword is data.
+		length := (8*layouts[version].dataCodewords() - 4 - countBits(version)) / 8
+		data := make([]byte, length)
+		for i := range data {
+			data[i] = "otpauth://totp/Quiz%20Maker:alice@example.com?secret=ABCDEFGH"[i%61]
+		}
+		for mask := 0; mask < 8; mask++ {
+			t.Run(fmt.Sprintf("version %d mask %d", version, mask), func(t *testing.T) {
+				got, err := decode(encode(data, version, mask))
+				if err != nil {
+					t.Fatalf("decode() error: %v", err)
+				}
+				if got != string(data) {
+					t.Errorf("decode() = %q, want %q", got, data)
+				}
+			})
+		}
+	}
+}
+
+// decode draws a code with a quiet zone, four pixels to a module, and reads
+// it.
+func decode(c *Code) (string, error) {
+	const scale, quiet = 4, 4
+	size := (c.Size + 2*quiet) * scale
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			mx, my := x/scale-quiet, y/scale-quiet
+			dark := mx >= 0 && my >= 0 && mx < c.Size && my < c.Size && c.Dark(mx, my)
+			if dark {
+				img.SetGray(x, y, color.Gray{0})
+			} else {
+				img.SetGray(x, y, color.Gray{255})
+			}
+		}
+	}
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", err
+	}
+	result, err := qrcode.NewQRCodeReader().Decode(bitmap, map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_PURE_BARCODE:  true,
+		gozxing.DecodeHintType_CHARACTER_SET: "ISO-8859-1",
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.GetText(), nil
+}
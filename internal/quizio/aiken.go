@@ -0,0 +1,139 @@
+package quizio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	aikenChoice = regexp.MustCompile(`^([A-Z])[.)]\s+(.*)$`)
+	aikenAnswer = regexp.MustCompile(`^ANSWER:\s*([A-Z])\s*$`)
+)
+
+// ErrUnsupportedQuestion is returned by writers when a question has no
+// representation in the target format.
+var ErrUnsupportedQuestion = errors.New("question type not supported by format")
+
+// ParseAiken reads multiple choice questions in the Aiken format: the
+// question text, one "A. choice" line per choice and a closing "ANSWER: A"
+// line. Invalid questions are reported as a *RowError numbered by the line
+// the question starts on. The returned error is only set if r can't be read.
+func ParseAiken(r io.Reader) ([]Question, []*RowError, error) {
+	questions := []Question{}
+	rowErrors := []*RowError{}
+
+	var text []string
+	var letters []string
+	var choices []string
+	start := 0
+	broken := ""
+	reset := func() {
+		text, letters, choices, broken = nil, nil, nil, ""
+	}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		current := strings.TrimSpace(strings.TrimRight(scanner.Text(), "\r"))
+		if line == 1 {
+			current = strings.TrimPrefix(current, "\ufeff")
+		}
+		if current == "" {
+			continue
+		}
+		if len(text) == 0 && len(choices) == 0 && broken == "" {
+			start = line
+		}
+
+		if m := aikenAnswer.FindStringSubmatch(current); m != nil {
+			q, err := buildAikenQuestion(text, letters, choices, m[1])
+			if broken != "" {
+				err = errors.New(broken)
+			}
+			if err == nil {
+				err = q.Validate()
+			}
+			if err != nil {
+				rowErrors = append(rowErrors, &RowError{Row: start, Message: err.Error()})
+			} else {
+				questions = append(questions, q)
+			}
+			reset()
+			continue
+		}
+		if m := aikenChoice.FindStringSubmatch(current); m != nil && len(text) > 0 {
+			letters = append(letters, m[1])
+			choices = append(choices, strings.TrimSpace(m[2]))
+			continue
+		}
+		if len(choices) > 0 && broken == "" {
+			broken = "question text after choices; missing ANSWER line"
+		}
+		text = append(text, current)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(text) > 0 || len(choices) > 0 {
+		rowErrors = append(rowErrors, &RowError{Row: start, Message: "missing ANSWER line"})
+	}
+	return questions, rowErrors, nil
+}
+
+func buildAikenQuestion(text, letters, choices []string, answer string) (Question, error) {
+	if len(text) == 0 {
+		return Question{}, errors.New("question text is empty")
+	}
+	q := Question{
+		Type:    TypeMultipleChoice,
+		Text:    strings.Join(text, "\n"),
+		Choices: choices,
+	}
+	for i, letter := range letters {
+		if letter != string(rune('A'+i)) {
+			return Question{}, fmt.Errorf("choice %s is out of order", letter)
+		}
+		if letter == answer {
+			q.Answer = i + 1
+		}
+	}
+	if q.Answer == 0 {
+		return Question{}, fmt.Errorf("answer %s does not refer to a choice", answer)
+	}
+	return q, nil
+}
+
+// WriteAiken writes questions in the Aiken format. Aiken only has multiple
+// choice questions, so true/false questions are written as two choices and
+// any other question type makes WriteAiken fail with ErrUnsupportedQuestion
+// before anything is written.
+func WriteAiken(w io.Writer, questions []Question) error {
+	for i, q := range questions {
+		if !q.HasChoices() {
+			return fmt.Errorf("question %d: %w", i+1, ErrUnsupportedQuestion)
+		}
+	}
+	bw := bufio.NewWriter(w)
+	for _, q := range questions {
+		fmt.Fprintln(bw, strings.ReplaceAll(q.Text, "\n", " "))
+		letter := 'A'
+		answer := ""
+		for j, choice := range q.Choices {
+			if choice == "" {
+				continue
+			}
+			fmt.Fprintf(bw, "%c. %s\n", letter, strings.ReplaceAll(choice, "\n", " "))
+			if j+1 == q.Answer {
+				answer = string(letter)
+			}
+			letter++
+		}
+		fmt.Fprintf(bw, "ANSWER: %s\n\n", answer)
+	}
+	return bw.Flush()
+}
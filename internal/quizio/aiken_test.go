@@ -0,0 +1,57 @@
+package quizio
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAiken(t *testing.T) {
+	input := `What is the correct answer to this question?
+A. Is it this one?
+B. Maybe this answer?
+C) Possibly this one?
+ANSWER: B
+
+Which LMS has the most quiz import formats?
+A) Moodle
+B) ATutor
+ANSWER: E
+Broken question
+A. one
+ANSWER: A
+`
+	questions, rowErrors, err := ParseAiken(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseAiken() error = %v", err)
+	}
+	want := []Question{
+		{Type: TypeMultipleChoice, Text: "What is the correct answer to this question?", Choices: []string{"Is it this one?", "Maybe this answer?", "Possibly this one?"}, Answer: 2},
+	}
+	if !reflect.DeepEqual(questions, want) {
+		t.Errorf("ParseAiken() questions = %+v, want %+v", questions, want)
+	}
+	if len(rowErrors) != 2 || rowErrors[0].Row != 7 || rowErrors[1].Row != 11 {
+		t.Errorf("ParseAiken() row errors = %v", rowErrors)
+	}
+}
+
+func TestWriteAiken(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteAiken(&buf, []Question{
+		{Type: TypeMultipleChoice, Text: "Pick c", Choices: []string{"a", "", "c", "d"}, Answer: 3},
+	})
+	if err != nil {
+		t.Fatalf("WriteAiken() error = %v", err)
+	}
+	want := "Pick c\nA. a\nB. c\nC. d\nANSWER: B\n\n"
+	if buf.String() != want {
+		t.Errorf("WriteAiken() = %q, want %q", buf.String(), want)
+	}
+
+	err = WriteAiken(&buf, []Question{{Type: TypeShortAnswer, Text: "Name one", AcceptedAnswers: []string{"x"}}})
+	if err == nil {
+		t.Error("WriteAiken() accepted a short answer question")
+	}
+}
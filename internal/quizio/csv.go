@@ -0,0 +1,250 @@
+package quizio
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// RowError describes a problem with a single row of an imported file.
+type RowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+}
+
+// CSVReader reads questions from a spreadsheet export one row at a time.
+//
+// The first row is a header naming the columns: question, choice1..choiceN,
+// answer and the optional type and explanation. Column names are
+// case-insensitive and may appear in any order.
+//
+// Without a type column every row is a multiple choice question. The answer
+// column holds the 1-based correct choice for multiple choice and true/false
+// questions, the accepted answers separated by "|" for short answer
+// questions, and "value" or "value:tolerance" for numeric questions.
+type CSVReader struct {
+	r           *csv.Reader
+	question    int
+	answer      int
+	typ         int
+	explanation int
+	choices     []int
+}
+
+// NewCSVReader reads and checks the header row from r.
+func NewCSVReader(r io.Reader) (*CSVReader, error) {
+	cr := &CSVReader{
+		r:           csv.NewReader(r),
+		question:    -1,
+		answer:      -1,
+		typ:         -1,
+		explanation: -1,
+	}
+	cr.r.FieldsPerRecord = -1
+	cr.r.TrimLeadingSpace = true
+
+	header, err := cr.r.Read()
+	if err == io.EOF {
+		return nil, errors.New("file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read header: %w", err)
+	}
+
+	choiceCols := map[int]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "question":
+			cr.question = i
+		case name == "answer":
+			cr.answer = i
+		case name == "type":
+			cr.typ = i
+		case name == "explanation":
+			cr.explanation = i
+		case strings.HasPrefix(name, "choice"):
+			n, err := strconv.Atoi(strings.TrimPrefix(name, "choice"))
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("unknown column %q", header[i])
+			}
+			if n > MaxChoices {
+				return nil, fmt.Errorf("at most %d choice columns are supported", MaxChoices)
+			}
+			choiceCols[n] = i
+		case name == "":
+		default:
+			return nil, fmt.Errorf("unknown column %q", header[i])
+		}
+	}
+	if cr.question == -1 {
+		return nil, errors.New("missing question column")
+	}
+	if cr.answer == -1 {
+		return nil, errors.New("missing answer column")
+	}
+	for n := 1; n <= len(choiceCols); n++ {
+		col, ok := choiceCols[n]
+		if !ok {
+			return nil, fmt.Errorf("missing choice%d column", n)
+		}
+		cr.choices = append(cr.choices, col)
+	}
+	if cr.typ == -1 && len(cr.choices) < 2 {
+		return nil, errors.New("at least 2 choice columns are required")
+	}
+	return cr, nil
+}
+
+// Next returns the next question in the file. It returns io.EOF when there
+// are no more rows, and a *RowError when the current row is invalid; reading
+// can continue after a *RowError. Rows are numbered by the line they start
+// on, matching what a spreadsheet shows.
+func (cr *CSVReader) Next() (Question, error) {
+	for {
+		record, err := cr.r.Read()
+		if err == io.EOF {
+			return Question{}, io.EOF
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return Question{}, &RowError{Row: parseErr.StartLine, Message: parseErr.Err.Error()}
+			}
+			return Question{}, err
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+		row, _ := cr.r.FieldPos(0)
+
+		q := Question{
+			Type: TypeMultipleChoice,
+			Text: field(record, cr.question),
+		}
+		if cr.typ != -1 && field(record, cr.typ) != "" {
+			q.Type = QuestionType(strings.ToLower(field(record, cr.typ)))
+		}
+		if cr.explanation != -1 {
+			q.Explanation = field(record, cr.explanation)
+		}
+		if err := cr.parseAnswer(&q, record); err != nil {
+			return Question{}, &RowError{Row: row, Message: err.Error()}
+		}
+		if err := q.Validate(); err != nil {
+			return Question{}, &RowError{Row: row, Message: err.Error()}
+		}
+		return q, nil
+	}
+}
+
+func (cr *CSVReader) parseAnswer(q *Question, record []string) error {
+	answer := field(record, cr.answer)
+	switch q.Type {
+	case TypeShortAnswer:
+		for _, a := range strings.Split(answer, "|") {
+			if a = strings.TrimSpace(a); a != "" {
+				q.AcceptedAnswers = append(q.AcceptedAnswers, a)
+			}
+		}
+		return nil
+	case TypeNumeric:
+		value, tolerance, hasTolerance := strings.Cut(answer, ":")
+		var err error
+		q.NumericAnswer, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return fmt.Errorf("answer %q is not a number", answer)
+		}
+		if hasTolerance {
+			q.Tolerance, err = strconv.ParseFloat(strings.TrimSpace(tolerance), 64)
+			if err != nil {
+				return fmt.Errorf("tolerance %q is not a number", tolerance)
+			}
+		}
+		return nil
+	case TypeTrueFalse:
+		switch strings.ToLower(answer) {
+		case "1", "true", "t":
+			q.Choices, q.Answer = TrueFalse(q.Text, true).Choices, 1
+		case "2", "false", "f":
+			q.Choices, q.Answer = TrueFalse(q.Text, false).Choices, 2
+		default:
+			return fmt.Errorf("answer %q is not true or false", answer)
+		}
+		return nil
+	default:
+		for _, col := range cr.choices {
+			q.Choices = append(q.Choices, field(record, col))
+		}
+		var err error
+		q.Answer, err = strconv.Atoi(answer)
+		if err != nil {
+			return fmt.Errorf("answer %q is not a number", answer)
+		}
+		return nil
+	}
+}
+
+// WriteCSV writes questions in the format accepted by NewCSVReader.
+func WriteCSV(w io.Writer, questions []Question) error {
+	cw := csv.NewWriter(w)
+	header := []string{"type", "question"}
+	for i := 1; i <= MaxChoices; i++ {
+		header = append(header, fmt.Sprintf("choice%d", i))
+	}
+	header = append(header, "answer", "explanation")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, q := range questions {
+		record := []string{string(q.Type), q.Text}
+		for i := 1; i <= MaxChoices; i++ {
+			record = append(record, q.Choice(i))
+		}
+		var answer string
+		switch q.Type {
+		case TypeShortAnswer:
+			answer = strings.Join(q.AcceptedAnswers, "|")
+		case TypeNumeric:
+			answer = formatNumber(q.NumericAnswer)
+			if q.Tolerance != 0 {
+				answer += ":" + formatNumber(q.Tolerance)
+			}
+		default:
+			answer = strconv.Itoa(q.Answer)
+		}
+		record = append(record, answer, q.Explanation)
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func field(record []string, i int) string {
+	if i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+func isBlankRecord(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package quizio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSVReader(t *testing.T) {
+	input := `Question,Choice1,Choice2,Choice3,Answer,Explanation
+What is 2+2?,3,4,5,2,Basic arithmetic
+,a,b,c,1,
+Capital of France?,Paris,Rome,,1,
+
+Pick one,a,b,c,x,
+Pick another,a,,c,2,
+`
+	cr, err := NewCSVReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("NewCSVReader() error = %v", err)
+	}
+
+	var questions []Question
+	var rowErrors []int
+	for {
+		q, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			rowErrors = append(rowErrors, rowErr.Row)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		questions = append(questions, q)
+	}
+
+	if len(questions) != 2 {
+		t.Fatalf("got %d questions, want 2", len(questions))
+	}
+	if questions[0].Answer != 2 || questions[0].Explanation != "Basic arithmetic" {
+		t.Errorf("first question = %+v", questions[0])
+	}
+	wantRows := []int{3, 6, 7}
+	if len(rowErrors) != len(wantRows) {
+		t.Fatalf("got row errors %v, want %v", rowErrors, wantRows)
+	}
+	for i := range wantRows {
+		if rowErrors[i] != wantRows[i] {
+			t.Errorf("got row errors %v, want %v", rowErrors, wantRows)
+		}
+	}
+}
+
+func TestNewCSVReaderHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		wantErr bool
+	}{
+		{name: "Valid", header: "question,choice1,choice2,answer", wantErr: false},
+		{name: "Missing answer", header: "question,choice1,choice2", wantErr: true},
+		{name: "Missing choice", header: "question,choice1,choice3,answer", wantErr: true},
+		{name: "Too many choices", header: "question,choice1,choice2,choice3,choice4,choice5,answer", wantErr: true},
+		{name: "Unknown column", header: "question,choice1,choice2,answer,points", wantErr: true},
+		{name: "Empty", header: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCSVReader(strings.NewReader(tt.header))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewCSVReader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteCSVRoundTrip(t *testing.T) {
+	want := []Question{
+		{Type: TypeMultipleChoice, Text: "Which, with a comma?", Choices: []string{"a", "b", "c", "d"}, Answer: 3, Explanation: "Because \"c\""},
+		{Type: TypeMultipleChoice, Text: "Two choices", Choices: []string{"yes", "no", "", ""}, Answer: 1},
+		{Type: TypeTrueFalse, Text: "True?", Choices: []string{"True", "False"}, Answer: 2},
+		{Type: TypeShortAnswer, Text: "Name a colour", AcceptedAnswers: []string{"red", "blue"}},
+		{Type: TypeNumeric, Text: "Half of 5?", NumericAnswer: 2.5, Tolerance: 0.1},
+	}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, want); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	cr, err := NewCSVReader(&buf)
+	if err != nil {
+		t.Fatalf("NewCSVReader() error = %v", err)
+	}
+	for i := range want {
+		got, err := cr.Next()
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("question %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+	if _, err := cr.Next(); err != io.EOF {
+		t.Errorf("Next() error = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,388 @@
+package quizio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseGIFT reads questions in Moodle's GIFT format. Questions that can't be
+// represented in quizmaker, such as essays, matching questions or partial
+// credit answers, are reported as a *RowError numbered by the line the
+// question starts on rather than being dropped. The returned error is only
+// set if r can't be read.
+func ParseGIFT(r io.Reader) ([]Question, []*RowError, error) {
+	blocks, err := giftBlocks(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	questions := []Question{}
+	rowErrors := []*RowError{}
+	for _, b := range blocks {
+		q, err := parseGIFTQuestion(b.text)
+		if err == nil {
+			err = q.Validate()
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, &RowError{Row: b.line, Message: err.Error()})
+			continue
+		}
+		questions = append(questions, q)
+	}
+	return questions, rowErrors, nil
+}
+
+type textBlock struct {
+	line int
+	text string
+}
+
+// giftBlocks splits the input into blank-line separated questions, dropping
+// comments and category commands.
+func giftBlocks(r io.Reader) ([]textBlock, error) {
+	var blocks []textBlock
+	var current []string
+	start := 0
+	flush := func() {
+		if len(current) > 0 {
+			blocks = append(blocks, textBlock{line: start, text: strings.Join(current, "\n")})
+			current = nil
+		}
+	}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		trimmed := strings.TrimSpace(text)
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "//"), strings.HasPrefix(trimmed, "$CATEGORY:"):
+		default:
+			if len(current) == 0 {
+				start = line
+			}
+			current = append(current, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return blocks, nil
+}
+
+func parseGIFTQuestion(text string) (Question, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "::") {
+		end := indexUnescaped(text[2:], "::")
+		if end == -1 {
+			return Question{}, errors.New("unterminated question title")
+		}
+		text = strings.TrimSpace(text[2+end+2:])
+	}
+	if strings.HasPrefix(text, "[") {
+		if end := strings.Index(text, "]"); end != -1 {
+			text = strings.TrimSpace(text[end+1:])
+		}
+	}
+
+	open := indexUnescaped(text, "{")
+	if open == -1 {
+		return Question{}, errors.New("description items without answers are not supported")
+	}
+	closing := indexUnescaped(text[open:], "}")
+	if closing == -1 {
+		return Question{}, errors.New("missing closing brace")
+	}
+	closing += open
+	before := strings.TrimSpace(text[:open])
+	after := strings.TrimSpace(text[closing+1:])
+	questionText := unescapeGIFT(before)
+	if after != "" {
+		// Missing word format: the answer block stands in for a blank.
+		questionText = strings.TrimSpace(questionText + " _____ " + unescapeGIFT(after))
+	}
+
+	body, explanation := splitGIFTFeedback(strings.TrimSpace(text[open+1 : closing]))
+	q, err := parseGIFTAnswers(body)
+	if err != nil {
+		return Question{}, err
+	}
+	q.Text = questionText
+	q.Explanation = explanation
+	return q, nil
+}
+
+// splitGIFTFeedback separates the "####" general feedback from the answers.
+func splitGIFTFeedback(body string) (string, string) {
+	i := indexUnescaped(body, "####")
+	if i == -1 {
+		return body, ""
+	}
+	return strings.TrimSpace(body[:i]), unescapeGIFT(strings.TrimSpace(body[i+4:]))
+}
+
+func parseGIFTAnswers(body string) (Question, error) {
+	if body == "" {
+		return Question{}, errors.New("essay questions are not supported")
+	}
+	if strings.HasPrefix(body, "#") {
+		return parseGIFTNumeric(strings.TrimSpace(body[1:]))
+	}
+	keyword, _, _ := strings.Cut(body, "#")
+	switch strings.ToUpper(strings.TrimSpace(keyword)) {
+	case "T", "TRUE":
+		return TrueFalse("", true), nil
+	case "F", "FALSE":
+		return TrueFalse("", false), nil
+	}
+	if indexUnescaped(body, "->") != -1 {
+		return Question{}, errors.New("matching questions are not supported")
+	}
+
+	answers, err := splitGIFTAnswers(body)
+	if err != nil {
+		return Question{}, err
+	}
+	wrong := 0
+	for _, a := range answers {
+		if !a.correct {
+			wrong++
+		}
+	}
+	if wrong == 0 {
+		q := Question{Type: TypeShortAnswer}
+		for _, a := range answers {
+			q.AcceptedAnswers = append(q.AcceptedAnswers, a.text)
+		}
+		return q, nil
+	}
+	q := Question{Type: TypeMultipleChoice}
+	hasFeedback := false
+	for i, a := range answers {
+		q.Choices = append(q.Choices, a.text)
+		q.ChoiceFeedback = append(q.ChoiceFeedback, a.feedback)
+		hasFeedback = hasFeedback || a.feedback != ""
+		if a.correct {
+			if q.Answer != 0 {
+				return Question{}, errors.New("multiple correct choices are not supported")
+			}
+			q.Answer = i + 1
+		}
+	}
+	if q.Answer == 0 {
+		return Question{}, errors.New("no correct choice")
+	}
+	if !hasFeedback {
+		q.ChoiceFeedback = nil
+	}
+	return q, nil
+}
+
+type giftAnswer struct {
+	correct  bool
+	text     string
+	feedback string
+}
+
+// splitGIFTAnswers splits a choice list such as "=right#why ~wrong" into its
+// answers.
+func splitGIFTAnswers(body string) ([]giftAnswer, error) {
+	var answers []giftAnswer
+	var current *giftAnswer
+	var buf strings.Builder
+	finish := func() error {
+		if current == nil {
+			if strings.TrimSpace(buf.String()) != "" {
+				return errors.New("answers must start with = or ~")
+			}
+			return nil
+		}
+		raw := strings.TrimSpace(buf.String())
+		if strings.HasPrefix(raw, "%") {
+			end := strings.Index(raw[1:], "%")
+			if end == -1 {
+				return errors.New("unterminated answer weight")
+			}
+			weight := raw[1 : end+1]
+			if (current.correct && weight != "100") || (!current.correct && weight != "0") {
+				return errors.New("partial credit answers are not supported")
+			}
+			raw = strings.TrimSpace(raw[end+2:])
+		}
+		text, feedback := raw, ""
+		if i := indexUnescaped(raw, "#"); i != -1 {
+			text, feedback = strings.TrimSpace(raw[:i]), strings.TrimSpace(raw[i+1:])
+		}
+		current.text = unescapeGIFT(text)
+		current.feedback = unescapeGIFT(feedback)
+		answers = append(answers, *current)
+		buf.Reset()
+		return nil
+	}
+	for i := 0; i < len(body); i++ {
+		ch := body[i]
+		if ch == '\\' && i+1 < len(body) {
+			buf.WriteByte(ch)
+			buf.WriteByte(body[i+1])
+			i++
+			continue
+		}
+		if ch == '=' || ch == '~' {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			current = &giftAnswer{correct: ch == '='}
+			continue
+		}
+		buf.WriteByte(ch)
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	if len(answers) == 0 {
+		return nil, errors.New("no answers")
+	}
+	return answers, nil
+}
+
+func parseGIFTNumeric(body string) (Question, error) {
+	q := Question{Type: TypeNumeric}
+	if strings.HasPrefix(body, "=") {
+		answers, err := splitGIFTAnswers(body)
+		if err != nil {
+			return Question{}, err
+		}
+		if len(answers) != 1 {
+			return Question{}, errors.New("multiple numeric answers are not supported")
+		}
+		body = answers[0].text
+	} else if i := indexUnescaped(body, "#"); i != -1 {
+		body = strings.TrimSpace(body[:i])
+	}
+
+	if low, high, ok := strings.Cut(body, ".."); ok {
+		lo, err1 := strconv.ParseFloat(strings.TrimSpace(low), 64)
+		hi, err2 := strconv.ParseFloat(strings.TrimSpace(high), 64)
+		if err1 != nil || err2 != nil || hi < lo {
+			return Question{}, fmt.Errorf("invalid numeric range %q", body)
+		}
+		q.NumericAnswer = (lo + hi) / 2
+		q.Tolerance = (hi - lo) / 2
+		return q, nil
+	}
+	value, tolerance, hasTolerance := strings.Cut(body, ":")
+	var err error
+	q.NumericAnswer, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return Question{}, fmt.Errorf("invalid numeric answer %q", value)
+	}
+	if hasTolerance {
+		q.Tolerance, err = strconv.ParseFloat(strings.TrimSpace(tolerance), 64)
+		if err != nil {
+			return Question{}, fmt.Errorf("invalid tolerance %q", tolerance)
+		}
+	}
+	return q, nil
+}
+
+// WriteGIFT writes questions in Moodle's GIFT format.
+func WriteGIFT(w io.Writer, questions []Question) error {
+	bw := bufio.NewWriter(w)
+	for i, q := range questions {
+		fmt.Fprintf(bw, "::Q%d:: %s {", i+1, escapeGIFT(q.Text))
+		switch q.Type {
+		case TypeTrueFalse:
+			if q.Answer == 1 {
+				bw.WriteString("TRUE")
+			} else {
+				bw.WriteString("FALSE")
+			}
+		case TypeShortAnswer:
+			for _, a := range q.AcceptedAnswers {
+				fmt.Fprintf(bw, "\n\t=%s", escapeGIFT(a))
+			}
+			bw.WriteString("\n")
+		case TypeNumeric:
+			fmt.Fprintf(bw, "#%s", formatNumber(q.NumericAnswer))
+			if q.Tolerance != 0 {
+				fmt.Fprintf(bw, ":%s", formatNumber(q.Tolerance))
+			}
+		default:
+			for j, choice := range q.Choices {
+				if choice == "" {
+					continue
+				}
+				prefix := "~"
+				if j+1 == q.Answer {
+					prefix = "="
+				}
+				fmt.Fprintf(bw, "\n\t%s%s", prefix, escapeGIFT(choice))
+				if feedback := q.Feedback(j + 1); feedback != "" {
+					fmt.Fprintf(bw, "#%s", escapeGIFT(feedback))
+				}
+			}
+			bw.WriteString("\n")
+		}
+		if q.Explanation != "" {
+			fmt.Fprintf(bw, "####%s", escapeGIFT(q.Explanation))
+		}
+		bw.WriteString("}\n\n")
+	}
+	return bw.Flush()
+}
+
+var giftEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`~`, `\~`,
+	`=`, `\=`,
+	`#`, `\#`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`:`, `\:`,
+	"\n", `\n`,
+)
+
+func escapeGIFT(s string) string {
+	return giftEscaper.Replace(s)
+}
+
+func unescapeGIFT(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// indexUnescaped is strings.Index ignoring occurrences escaped with a
+// backslash.
+func indexUnescaped(s, substr string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], substr) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,85 @@
+package quizio
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGIFT(t *testing.T) {
+	input := `// A comment
+$CATEGORY: $course$/Arithmetic
+
+::Q1:: What is 2+2? {
+	=4#Right
+	~3#Too low
+	~5
+	####Count on your fingers.
+}
+
+Grant is buried in Grant's tomb.{TRUE}
+
+Who's buried in Grant's tomb?{=Grant =Ulysses S. Grant}
+
+What is the value of pi (to 3 decimal places)? {#3.1415:0.0005}
+
+Pick a number between 1 and 5. {#1..5}
+
+Two plus {=two ~three} equals four.
+
+Write an essay. {}
+
+::Match:: Match these. {=a -> b =c -> d}
+
+Partial credit {~%50%half =full ~none}
+
+The colon \: and brace \{ are escaped. {=yes ~no}
+`
+	questions, rowErrors, err := ParseGIFT(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseGIFT() error = %v", err)
+	}
+
+	want := []Question{
+		{Type: TypeMultipleChoice, Text: "What is 2+2?", Choices: []string{"4", "3", "5"}, Answer: 1, Explanation: "Count on your fingers.", ChoiceFeedback: []string{"Right", "Too low", ""}},
+		TrueFalse("Grant is buried in Grant's tomb.", true),
+		{Type: TypeShortAnswer, Text: "Who's buried in Grant's tomb?", AcceptedAnswers: []string{"Grant", "Ulysses S. Grant"}},
+		{Type: TypeNumeric, Text: "What is the value of pi (to 3 decimal places)?", NumericAnswer: 3.1415, Tolerance: 0.0005},
+		{Type: TypeNumeric, Text: "Pick a number between 1 and 5.", NumericAnswer: 3, Tolerance: 2},
+		{Type: TypeMultipleChoice, Text: "Two plus _____ equals four.", Choices: []string{"two", "three"}, Answer: 1},
+		{Type: TypeMultipleChoice, Text: "The colon : and brace { are escaped.", Choices: []string{"yes", "no"}, Answer: 1},
+	}
+	if !reflect.DeepEqual(questions, want) {
+		t.Errorf("ParseGIFT() questions =\n%+v\nwant\n%+v", questions, want)
+	}
+	wantRows := []int{21, 23, 25}
+	if len(rowErrors) != len(wantRows) {
+		t.Fatalf("got %d row errors %v, want rows %v", len(rowErrors), rowErrors, wantRows)
+	}
+	for i, row := range wantRows {
+		if rowErrors[i].Row != row {
+			t.Errorf("row error %d at row %d, want %d", i, rowErrors[i].Row, row)
+		}
+	}
+}
+
+func TestWriteGIFTRoundTrip(t *testing.T) {
+	want := []Question{
+		{Type: TypeMultipleChoice, Text: "Which = sign? {really}", Choices: []string{"a~b", "c#d", "e"}, Answer: 2, Explanation: "Because: reasons", ChoiceFeedback: []string{"", "Yes #2", "No"}},
+		TrueFalse("The sky is green.", false),
+		{Type: TypeShortAnswer, Text: "Name a colour.", AcceptedAnswers: []string{"red", "blue"}},
+		{Type: TypeNumeric, Text: "Half of 5?", NumericAnswer: 2.5, Tolerance: 0.1},
+	}
+	var buf bytes.Buffer
+	if err := WriteGIFT(&buf, want); err != nil {
+		t.Fatalf("WriteGIFT() error = %v", err)
+	}
+	got, rowErrors, err := ParseGIFT(&buf)
+	if err != nil || len(rowErrors) != 0 {
+		t.Fatalf("ParseGIFT() errors = %v, %v", rowErrors, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip =\n%+v\nwant\n%+v", got, want)
+	}
+}
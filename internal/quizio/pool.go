@@ -0,0 +1,79 @@
+package quizio
+
+import "math"
+
+// Section draws questions for an attempt from the questions of a quiz.
+type Section struct {
+	Title string `json:"title"`
+	// Tag limits the section to questions with this tag. An empty tag
+	// matches every question.
+	Tag string `json:"tag"`
+	// Draw is the number of questions drawn; 0 draws every matching
+	// question.
+	Draw int `json:"draw"`
+}
+
+// PoolQuestion is a question that can be drawn into an attempt.
+type PoolQuestion struct {
+	ID   string
+	Tags []string
+}
+
+// DrawQuestions picks the questions for one attempt and returns their IDs
+// for each section. Sections draw in order, each from the questions no
+// earlier section has taken, and the draw depends only on seed. Without
+// shuffle the drawn questions of a section keep their order in pool; with it
+// they are shuffled within the section. Without sections every question is
+// drawn into a single untitled section.
+func DrawQuestions(pool []PoolQuestion, sections []Section, seed uint64, shuffle bool) [][]string {
+	if len(sections) == 0 {
+		sections = []Section{{}}
+	}
+	taken := make([]bool, len(pool))
+	drawnIDs := make([][]string, len(sections))
+	for i, section := range sections {
+		var candidates []int
+		for j, q := range pool {
+			if !taken[j] && (section.Tag == "" || hasTag(q.Tags, section.Tag)) {
+				candidates = append(candidates, j)
+			}
+		}
+		// Streams count down from the top so they never meet the small
+		// streams used for shuffling questions and choices.
+		order := ShuffleOrder(len(candidates), seed, math.MaxUint64-uint64(i))
+		n := len(candidates)
+		if section.Draw > 0 && section.Draw < n {
+			n = section.Draw
+		}
+		drawn := make([]bool, len(candidates))
+		for _, idx := range order[:n] {
+			drawn[idx] = true
+		}
+		var picked []int
+		if shuffle {
+			for _, idx := range order[:n] {
+				picked = append(picked, candidates[idx])
+			}
+		} else {
+			for idx, j := range candidates {
+				if drawn[idx] {
+					picked = append(picked, j)
+				}
+			}
+		}
+		for _, j := range picked {
+			taken[j] = true
+			drawnIDs[i] = append(drawnIDs[i], pool[j].ID)
+		}
+	}
+	return drawnIDs
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package quizio
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func testPool() []PoolQuestion {
+	var pool []PoolQuestion
+	for i := 1; i <= 40; i++ {
+		q := PoolQuestion{ID: fmt.Sprint(i)}
+		if i <= 10 {
+			q.Tags = []string{"easy"}
+		}
+		pool = append(pool, q)
+	}
+	return pool
+}
+
+func TestDrawQuestions(t *testing.T) {
+	pool := testPool()
+	sections := []Section{{Title: "Warm-up", Tag: "easy", Draw: 3}, {Title: "Rest", Draw: 7}}
+
+	drawn := DrawQuestions(pool, sections, 1, false)
+	if !reflect.DeepEqual(drawn, DrawQuestions(pool, sections, 1, false)) {
+		t.Fatal("DrawQuestions() is not deterministic")
+	}
+	if len(drawn) != 2 || len(drawn[0]) != 3 || len(drawn[1]) != 7 {
+		t.Fatalf("drew %v, want 3 and 7 questions", drawn)
+	}
+	ids := append(drawn[0], drawn[1]...)
+	if len(ids) != 10 {
+		t.Fatalf("drew %d questions, want 10", len(ids))
+	}
+	seen := map[string]bool{}
+	for i, id := range ids {
+		if seen[id] {
+			t.Errorf("question %s drawn twice", id)
+		}
+		seen[id] = true
+		var n int
+		fmt.Sscan(id, &n)
+		if i < 3 && n > 10 {
+			t.Errorf("warm-up drew untagged question %s", id)
+		}
+		if i > 0 && i != 3 && !numberedBefore(ids[i-1], id) {
+			t.Errorf("questions %s and %s are out of order within a section", ids[i-1], id)
+		}
+	}
+	if reflect.DeepEqual(drawn, DrawQuestions(pool, sections, 2, false)) {
+		t.Error("different seeds drew the same questions")
+	}
+
+	shuffled := DrawQuestions(pool, sections, 1, true)
+	if !sameSet(drawn[0], shuffled[0]) || !sameSet(drawn[1], shuffled[1]) {
+		t.Error("shuffling changed which questions were drawn")
+	}
+}
+
+func TestDrawQuestionsDefaults(t *testing.T) {
+	pool := testPool()
+	all := DrawQuestions(pool, nil, 5, false)
+	if len(all) != 1 || len(all[0]) != len(pool) || all[0][0] != "1" || all[0][39] != "40" {
+		t.Errorf("without sections got %v, want every question in order", all)
+	}
+	// A section asking for more than is left gets what is left.
+	ids := DrawQuestions(pool, []Section{{Tag: "easy", Draw: 50}, {Tag: "missing", Draw: 2}}, 5, false)
+	if len(ids[0]) != 10 || len(ids[1]) != 0 {
+		t.Errorf("drew %d and %d questions, want the 10 tagged ones and none", len(ids[0]), len(ids[1]))
+	}
+}
+
+func numberedBefore(a, b string) bool {
+	var x, y int
+	fmt.Sscan(a, &x)
+	fmt.Sscan(b, &y)
+	return x < y
+}
+
+func sameSet(a, b []string) bool {
+	set := map[string]bool{}
+	for _, s := range a {
+		set[s] = true
+	}
+	for _, s := range b {
+		if !set[s] {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
@@ -0,0 +1,530 @@
+package quizio
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// MaxQTIPackageSize limits how much of an uploaded QTI package is read.
+// MaxQTIUncompressedSize limits how large its files are once uncompressed,
+// added together, so that a small package can't expand to fill memory.
+const (
+	MaxQTIPackageSize      = 32 << 20
+	MaxQTIUncompressedSize = 128 << 20
+)
+
+const (
+	qtiNamespace     = "http://www.imsglobal.org/xsd/imsqti_v2p1"
+	qtiItemResource  = "imsqti_item_xmlv2p1"
+	qtiTestResource  = "imsqti_test_xmlv2p1"
+	qtiResponseIdent = "RESPONSE"
+	// qtiMaxTitleLength is how many characters of a question's text make
+	// up its item title.
+	qtiMaxTitleLength = 80
+)
+
+// ItemError describes an item in a QTI package that couldn't be imported.
+type ItemError struct {
+	Item    string `json:"item"`
+	Message string `json:"message"`
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %s: %s", e.Item, e.Message)
+}
+
+// WriteQTI writes questions as an IMS QTI 2.1 content package: a zip file
+// holding an imsmanifest.xml, one assessmentTest and one assessmentItem per
+// question.
+func WriteQTI(w io.Writer, title string, questions []Question) error {
+	zw := zip.NewWriter(w)
+	var itemIDs []string
+	for i, q := range questions {
+		id := fmt.Sprintf("item%d", i+1)
+		itemIDs = append(itemIDs, id)
+		f, err := zw.Create("items/" + id + ".xml")
+		if err != nil {
+			return err
+		}
+		if err := writeQTIItem(f, id, q); err != nil {
+			return fmt.Errorf("question %d: %w", i+1, err)
+		}
+	}
+
+	f, err := zw.Create("test.xml")
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	fmt.Fprintf(bw, "%s<assessmentTest xmlns=%q identifier=\"test\" title=\"%s\">\n", xml.Header, qtiNamespace, escapeXML(title))
+	bw.WriteString("  <testPart identifier=\"part1\" navigationMode=\"nonlinear\" submissionMode=\"individual\">\n")
+	bw.WriteString("    <assessmentSection identifier=\"section1\" title=\"Questions\" visible=\"true\">\n")
+	for _, id := range itemIDs {
+		fmt.Fprintf(bw, "      <assessmentItemRef identifier=%q href=\"items/%s.xml\"/>\n", id, id)
+	}
+	bw.WriteString("    </assessmentSection>\n  </testPart>\n</assessmentTest>\n")
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	f, err = zw.Create("imsmanifest.xml")
+	if err != nil {
+		return err
+	}
+	bw = bufio.NewWriter(f)
+	fmt.Fprintf(bw, "%s<manifest xmlns=\"http://www.imsglobal.org/xsd/imscp_v1p1\" identifier=\"MANIFEST-1\">\n", xml.Header)
+	bw.WriteString("  <metadata>\n    <schema>IMS Content</schema>\n    <schemaversion>1.1</schemaversion>\n  </metadata>\n")
+	bw.WriteString("  <organizations/>\n  <resources>\n")
+	fmt.Fprintf(bw, "    <resource identifier=\"RES-test\" type=%q href=\"test.xml\">\n      <file href=\"test.xml\"/>\n", qtiTestResource)
+	for _, id := range itemIDs {
+		fmt.Fprintf(bw, "      <dependency identifierref=\"RES-%s\"/>\n", id)
+	}
+	bw.WriteString("    </resource>\n")
+	for _, id := range itemIDs {
+		fmt.Fprintf(bw, "    <resource identifier=\"RES-%s\" type=%q href=\"items/%s.xml\">\n      <file href=\"items/%s.xml\"/>\n    </resource>\n", id, qtiItemResource, id, id)
+	}
+	bw.WriteString("  </resources>\n</manifest>\n")
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeQTIItem(w io.Writer, id string, q Question) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s<assessmentItem xmlns=%q identifier=%q title=\"%s\" adaptive=\"false\" timeDependent=\"false\">\n", xml.Header, qtiNamespace, id, escapeXML(qtiTitle(q.Text)))
+	switch q.Type {
+	case TypeMultipleChoice, TypeTrueFalse:
+		fmt.Fprintf(bw, "  <responseDeclaration identifier=%q cardinality=\"single\" baseType=\"identifier\">\n", qtiResponseIdent)
+		fmt.Fprintf(bw, "    <correctResponse><value>choice%d</value></correctResponse>\n  </responseDeclaration>\n", q.Answer)
+	case TypeShortAnswer:
+		fmt.Fprintf(bw, "  <responseDeclaration identifier=%q cardinality=\"single\" baseType=\"string\">\n", qtiResponseIdent)
+		fmt.Fprintf(bw, "    <correctResponse><value>%s</value></correctResponse>\n    <mapping defaultValue=\"0\">\n", escapeXML(q.AcceptedAnswers[0]))
+		for _, a := range q.AcceptedAnswers {
+			fmt.Fprintf(bw, "      <mapEntry mapKey=\"%s\" mappedValue=\"1\"/>\n", escapeXML(a))
+		}
+		bw.WriteString("    </mapping>\n  </responseDeclaration>\n")
+	case TypeNumeric:
+		fmt.Fprintf(bw, "  <responseDeclaration identifier=%q cardinality=\"single\" baseType=\"float\">\n", qtiResponseIdent)
+		fmt.Fprintf(bw, "    <correctResponse><value>%s</value></correctResponse>\n  </responseDeclaration>\n", formatNumber(q.NumericAnswer))
+	default:
+		return ErrUnsupportedQuestion
+	}
+	bw.WriteString("  <outcomeDeclaration identifier=\"SCORE\" cardinality=\"single\" baseType=\"float\">\n")
+	bw.WriteString("    <defaultValue><value>0</value></defaultValue>\n  </outcomeDeclaration>\n")
+	if q.Explanation != "" {
+		bw.WriteString("  <outcomeDeclaration identifier=\"FEEDBACK\" cardinality=\"single\" baseType=\"identifier\"/>\n")
+	}
+
+	fmt.Fprintf(bw, "  <itemBody>\n    <p>%s</p>\n", escapeXML(q.Text))
+	switch q.Type {
+	case TypeMultipleChoice, TypeTrueFalse:
+		fmt.Fprintf(bw, "    <choiceInteraction responseIdentifier=%q shuffle=\"false\" maxChoices=\"1\">\n", qtiResponseIdent)
+		for i, choice := range q.Choices {
+			if choice == "" {
+				continue
+			}
+			fmt.Fprintf(bw, "      <simpleChoice identifier=\"choice%d\">%s</simpleChoice>\n", i+1, escapeXML(choice))
+		}
+		bw.WriteString("    </choiceInteraction>\n")
+	default:
+		fmt.Fprintf(bw, "    <p><textEntryInteraction responseIdentifier=%q/></p>\n", qtiResponseIdent)
+	}
+	bw.WriteString("  </itemBody>\n")
+
+	// Response processing is written out rather than referring to a
+	// template, since templates can't also set the outcome that shows the
+	// explanation.
+	bw.WriteString("  <responseProcessing>\n    <responseCondition>\n      <responseIf>\n")
+	switch q.Type {
+	case TypeShortAnswer:
+		fmt.Fprintf(bw, "        <isNull><variable identifier=%q/></isNull>\n", qtiResponseIdent)
+		bw.WriteString("        <setOutcomeValue identifier=\"SCORE\"><baseValue baseType=\"float\">0</baseValue></setOutcomeValue>\n")
+		bw.WriteString("      </responseIf>\n      <responseElse>\n")
+		fmt.Fprintf(bw, "        <setOutcomeValue identifier=\"SCORE\"><mapResponse identifier=%q/></setOutcomeValue>\n", qtiResponseIdent)
+		bw.WriteString("      </responseElse>\n")
+	case TypeNumeric:
+		tolerance := formatNumber(q.Tolerance)
+		fmt.Fprintf(bw, "        <equal toleranceMode=\"absolute\" tolerance=\"%s %s\">\n", tolerance, tolerance)
+		fmt.Fprintf(bw, "          <variable identifier=%q/>\n          <correct identifier=%q/>\n        </equal>\n", qtiResponseIdent, qtiResponseIdent)
+		bw.WriteString("        <setOutcomeValue identifier=\"SCORE\"><baseValue baseType=\"float\">1</baseValue></setOutcomeValue>\n")
+		bw.WriteString("      </responseIf>\n")
+	default:
+		fmt.Fprintf(bw, "        <match>\n          <variable identifier=%q/>\n          <correct identifier=%q/>\n        </match>\n", qtiResponseIdent, qtiResponseIdent)
+		bw.WriteString("        <setOutcomeValue identifier=\"SCORE\"><baseValue baseType=\"float\">1</baseValue></setOutcomeValue>\n")
+		bw.WriteString("      </responseIf>\n")
+	}
+	bw.WriteString("    </responseCondition>\n")
+	if q.Explanation != "" {
+		bw.WriteString("    <setOutcomeValue identifier=\"FEEDBACK\"><baseValue baseType=\"identifier\">explanation</baseValue></setOutcomeValue>\n")
+	}
+	bw.WriteString("  </responseProcessing>\n")
+	if q.Explanation != "" {
+		fmt.Fprintf(bw, "  <modalFeedback outcomeIdentifier=\"FEEDBACK\" identifier=\"explanation\" showHide=\"show\">%s</modalFeedback>\n", escapeXML(q.Explanation))
+	}
+	bw.WriteString("</assessmentItem>\n")
+	return bw.Flush()
+}
+
+// qtiTitle shortens question text to the first line and at most
+// qtiMaxTitleLength characters.
+func qtiTitle(text string) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	if r := []rune(title); len(r) > qtiMaxTitleLength {
+		title = strings.TrimSpace(string(r[:qtiMaxTitleLength-1])) + "…"
+	}
+	return title
+}
+
+// ParseQTI reads the questions from an IMS QTI 2.1 content package. Items are
+// returned in assessmentTest order when the package has a test, otherwise in
+// manifest order. Items using interactions quizmaker doesn't support are
+// reported as an *ItemError rather than dropped. The returned error is set if
+// the package itself can't be read.
+func ParseQTI(r io.Reader) ([]Question, []*ItemError, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxQTIPackageSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) > MaxQTIPackageSize {
+		return nil, nil, fmt.Errorf("package is larger than %d bytes", MaxQTIPackageSize)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a zip file: %w", err)
+	}
+	files := map[string]*zip.File{}
+	var uncompressed uint64
+	for _, f := range zr.File {
+		uncompressed += f.UncompressedSize64
+		if uncompressed > MaxQTIUncompressedSize {
+			return nil, nil, fmt.Errorf("package is larger than %d bytes uncompressed", MaxQTIUncompressedSize)
+		}
+		files[path.Clean(f.Name)] = f
+	}
+
+	var manifest struct {
+		Resources []struct {
+			Identifier string `xml:"identifier,attr"`
+			Type       string `xml:"type,attr"`
+			Href       string `xml:"href,attr"`
+		} `xml:"resources>resource"`
+	}
+	if err := decodeZipXML(files, "imsmanifest.xml", &manifest); err != nil {
+		return nil, nil, err
+	}
+
+	itemErrors := []*ItemError{}
+	var itemHrefs []string
+	var testHref string
+	for _, res := range manifest.Resources {
+		switch {
+		case strings.HasPrefix(res.Type, qtiItemResource):
+			itemHrefs = append(itemHrefs, path.Clean(res.Href))
+		case strings.HasPrefix(res.Type, qtiTestResource):
+			testHref = path.Clean(res.Href)
+		case strings.HasPrefix(res.Type, "imsqti"):
+			itemErrors = append(itemErrors, &ItemError{Item: res.Identifier, Message: fmt.Sprintf("resource type %q is not QTI 2.1", res.Type)})
+		}
+	}
+	if testHref != "" {
+		var test struct {
+			Refs []struct {
+				Href string `xml:"href,attr"`
+			} `xml:"testPart>assessmentSection>assessmentItemRef"`
+		}
+		if err := decodeZipXML(files, testHref, &test); err != nil {
+			return nil, nil, err
+		}
+		if len(test.Refs) > 0 {
+			itemHrefs = nil
+			for _, ref := range test.Refs {
+				itemHrefs = append(itemHrefs, path.Join(path.Dir(testHref), ref.Href))
+			}
+		}
+	}
+
+	questions := []Question{}
+	for _, href := range itemHrefs {
+		var item qtiItem
+		if err := decodeZipXML(files, href, &item); err != nil {
+			itemErrors = append(itemErrors, &ItemError{Item: href, Message: err.Error()})
+			continue
+		}
+		q, err := item.question()
+		if err == nil {
+			err = q.Validate()
+		}
+		if err != nil {
+			name := item.Identifier
+			if name == "" {
+				name = href
+			}
+			itemErrors = append(itemErrors, &ItemError{Item: name, Message: err.Error()})
+			continue
+		}
+		questions = append(questions, q)
+	}
+	return questions, itemErrors, nil
+}
+
+func decodeZipXML(files map[string]*zip.File, name string, v any) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	// The sizes in the zip headers were checked against the limit, so don't
+	// read more than they claim.
+	r := io.LimitReader(rc, int64(f.UncompressedSize64))
+	if err := xml.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return nil
+}
+
+// xmlNode keeps an element's raw content alongside its children so that
+// mixed text and markup can be flattened in document order.
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+	Nodes   []xmlNode  `xml:",any"`
+}
+
+func (n xmlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+type qtiItem struct {
+	Identifier           string `xml:"identifier,attr"`
+	ResponseDeclarations []struct {
+		Identifier      string   `xml:"identifier,attr"`
+		Cardinality     string   `xml:"cardinality,attr"`
+		BaseType        string   `xml:"baseType,attr"`
+		CorrectResponse []string `xml:"correctResponse>value"`
+		MapEntries      []struct {
+			Key   string  `xml:"mapKey,attr"`
+			Value float64 `xml:"mappedValue,attr"`
+		} `xml:"mapping>mapEntry"`
+	} `xml:"responseDeclaration"`
+	ItemBody           xmlNode   `xml:"itemBody"`
+	ResponseProcessing xmlNode   `xml:"responseProcessing"`
+	ModalFeedback      []xmlNode `xml:"modalFeedback"`
+}
+
+var qtiInteractions = map[string]bool{
+	"choiceInteraction":         true,
+	"textEntryInteraction":      true,
+	"extendedTextInteraction":   true,
+	"orderInteraction":          true,
+	"associateInteraction":      true,
+	"matchInteraction":          true,
+	"gapMatchInteraction":       true,
+	"inlineChoiceInteraction":   true,
+	"hottextInteraction":        true,
+	"hotspotInteraction":        true,
+	"selectPointInteraction":    true,
+	"graphicOrderInteraction":   true,
+	"sliderInteraction":         true,
+	"uploadInteraction":         true,
+	"drawingInteraction":        true,
+	"mediaInteraction":          true,
+	"customInteraction":         true,
+	"endAttemptInteraction":     true,
+	"positionObjectInteraction": true,
+}
+
+func (item qtiItem) question() (Question, error) {
+	var interactions []xmlNode
+	collectNodes(item.ItemBody, func(n xmlNode) bool { return qtiInteractions[n.XMLName.Local] }, &interactions)
+	if len(interactions) != 1 {
+		return Question{}, fmt.Errorf("items must have exactly 1 interaction, found %d", len(interactions))
+	}
+	interaction := interactions[0]
+
+	responseID := interaction.attr("responseIdentifier")
+	var correct []string
+	var mapKeys []string
+	var baseType, cardinality string
+	for _, rd := range item.ResponseDeclarations {
+		if rd.Identifier != responseID {
+			continue
+		}
+		correct = rd.CorrectResponse
+		baseType = rd.BaseType
+		cardinality = rd.Cardinality
+		for _, e := range rd.MapEntries {
+			if e.Value > 0 {
+				mapKeys = append(mapKeys, e.Key)
+			}
+		}
+	}
+	if cardinality != "" && cardinality != "single" {
+		return Question{}, fmt.Errorf("%s cardinality is not supported", cardinality)
+	}
+
+	q := Question{
+		Text: plainText(item.ItemBody.Inner, qtiInteractions),
+	}
+	for _, fb := range item.ModalFeedback {
+		if text := plainText(fb.Inner, nil); text != "" {
+			q.Explanation = text
+			break
+		}
+	}
+
+	switch interaction.XMLName.Local {
+	case "choiceInteraction":
+		if maxChoices := interaction.attr("maxChoices"); maxChoices != "" && maxChoices != "1" {
+			return Question{}, errors.New("choice interactions with more than 1 answer are not supported")
+		}
+		if len(correct) != 1 {
+			return Question{}, errors.New("missing correct response")
+		}
+		var prompt []xmlNode
+		collectNodes(interaction, func(n xmlNode) bool { return n.XMLName.Local == "prompt" }, &prompt)
+		for _, p := range prompt {
+			q.Text = strings.TrimSpace(q.Text + "\n" + plainText(p.Inner, nil))
+		}
+		for _, choice := range interaction.Nodes {
+			if choice.XMLName.Local != "simpleChoice" {
+				continue
+			}
+			q.Choices = append(q.Choices, plainText(choice.Inner, nil))
+			if choice.attr("identifier") == strings.TrimSpace(correct[0]) {
+				q.Answer = len(q.Choices)
+			}
+		}
+		q.Type = TypeMultipleChoice
+		if len(q.Choices) == 2 && strings.EqualFold(q.Choices[0], "true") && strings.EqualFold(q.Choices[1], "false") {
+			q.Type = TypeTrueFalse
+			q.Choices = []string{"True", "False"}
+		}
+		return q, nil
+	case "textEntryInteraction":
+		switch baseType {
+		case "float", "integer":
+			if len(correct) != 1 {
+				return Question{}, errors.New("missing correct response")
+			}
+			value, err := strconv.ParseFloat(strings.TrimSpace(correct[0]), 64)
+			if err != nil {
+				return Question{}, fmt.Errorf("invalid numeric answer %q", correct[0])
+			}
+			q.Type = TypeNumeric
+			q.NumericAnswer = value
+			q.Tolerance = qtiTolerance(item.ResponseProcessing)
+			return q, nil
+		case "string", "":
+			q.Type = TypeShortAnswer
+			seen := map[string]bool{}
+			for _, a := range append(correct, mapKeys...) {
+				a = strings.TrimSpace(a)
+				if a != "" && !seen[a] {
+					seen[a] = true
+					q.AcceptedAnswers = append(q.AcceptedAnswers, a)
+				}
+			}
+			return q, nil
+		default:
+			return Question{}, fmt.Errorf("%s text entry is not supported", baseType)
+		}
+	default:
+		return Question{}, fmt.Errorf("%s is not supported", interaction.XMLName.Local)
+	}
+}
+
+// qtiTolerance returns the absolute tolerance of the first equal check in
+// custom response processing, or 0 if there is none.
+func qtiTolerance(rp xmlNode) float64 {
+	var equals []xmlNode
+	collectNodes(rp, func(n xmlNode) bool { return n.XMLName.Local == "equal" }, &equals)
+	for _, eq := range equals {
+		if eq.attr("toleranceMode") != "absolute" {
+			continue
+		}
+		fields := strings.Fields(eq.attr("tolerance"))
+		if len(fields) == 0 {
+			continue
+		}
+		if t, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			return t
+		}
+	}
+	return 0
+}
+
+func collectNodes(n xmlNode, match func(xmlNode) bool, out *[]xmlNode) {
+	for _, child := range n.Nodes {
+		if match(child) {
+			*out = append(*out, child)
+			continue
+		}
+		collectNodes(child, match, out)
+	}
+}
+
+// plainText flattens XML content to text, skipping elements named in skip
+// and separating block elements with newlines.
+func plainText(inner string, skip map[string]bool) string {
+	d := xml.NewDecoder(strings.NewReader(inner))
+	d.Strict = false
+	var b strings.Builder
+	skipping := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skipping > 0 || skip[t.Name.Local] {
+				skipping++
+				continue
+			}
+			if t.Name.Local == "p" || t.Name.Local == "div" || t.Name.Local == "br" {
+				b.WriteString("\n")
+			}
+		case xml.EndElement:
+			if skipping > 0 {
+				skipping--
+			}
+		case xml.CharData:
+			if skipping == 0 {
+				b.Write(t)
+			}
+		}
+	}
+	lines := strings.Split(b.String(), "\n")
+	var out []string
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
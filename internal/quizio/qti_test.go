@@ -0,0 +1,115 @@
+package quizio
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteQTIRoundTrip(t *testing.T) {
+	want := []Question{
+		{Type: TypeMultipleChoice, Text: "Which <tag> & entity?", Choices: []string{"a", "b", "c"}, Answer: 2, Explanation: "Because b."},
+		TrueFalse("The sky is green.", false),
+		{Type: TypeShortAnswer, Text: "Name a colour.", AcceptedAnswers: []string{"red", "blue"}},
+		{Type: TypeNumeric, Text: "Half of 5?", NumericAnswer: 2.5, Tolerance: 0.1},
+	}
+	var buf bytes.Buffer
+	if err := WriteQTI(&buf, "My quiz", want); err != nil {
+		t.Fatalf("WriteQTI() error = %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	f, err := zr.Open("items/item1.xml")
+	if err != nil {
+		t.Fatalf("Open(item1) error = %v", err)
+	}
+	item, _ := io.ReadAll(f)
+	for _, s := range []string{
+		`title="Which &lt;tag&gt; &amp; entity?"`,
+		`<setOutcomeValue identifier="FEEDBACK"><baseValue baseType="identifier">explanation</baseValue></setOutcomeValue>`,
+		`<modalFeedback outcomeIdentifier="FEEDBACK" identifier="explanation" showHide="show">`,
+	} {
+		if !strings.Contains(string(item), s) {
+			t.Errorf("item1.xml doesn't contain %s:\n%s", s, item)
+		}
+	}
+
+	got, itemErrors, err := ParseQTI(&buf)
+	if err != nil || len(itemErrors) != 0 {
+		t.Fatalf("ParseQTI() errors = %v, %v", itemErrors, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseQTIUnsupportedItems(t *testing.T) {
+	files := map[string]string{
+		"imsmanifest.xml": `<?xml version="1.0"?>
+<manifest xmlns="http://www.imsglobal.org/xsd/imscp_v1p1">
+  <resources>
+    <resource identifier="r1" type="imsqti_item_xmlv2p1" href="choice.xml"/>
+    <resource identifier="r2" type="imsqti_item_xmlv2p1" href="order.xml"/>
+    <resource identifier="r3" type="imsqti_xmlv1p2" href="old.xml"/>
+  </resources>
+</manifest>`,
+		"choice.xml": `<?xml version="1.0"?>
+<assessmentItem xmlns="http://www.imsglobal.org/xsd/imsqti_v2p1" identifier="choice">
+  <responseDeclaration identifier="RESPONSE" cardinality="single" baseType="identifier">
+    <correctResponse><value>B</value></correctResponse>
+  </responseDeclaration>
+  <itemBody>
+    <choiceInteraction responseIdentifier="RESPONSE" maxChoices="1">
+      <prompt>What is <b>2</b>+2?</prompt>
+      <simpleChoice identifier="A">3</simpleChoice>
+      <simpleChoice identifier="B">4</simpleChoice>
+    </choiceInteraction>
+  </itemBody>
+</assessmentItem>`,
+		"order.xml": `<?xml version="1.0"?>
+<assessmentItem xmlns="http://www.imsglobal.org/xsd/imsqti_v2p1" identifier="order">
+  <itemBody><orderInteraction responseIdentifier="RESPONSE"/></itemBody>
+</assessmentItem>`,
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, _ := zw.Create(name)
+		f.Write([]byte(content))
+	}
+	zw.Close()
+
+	got, itemErrors, err := ParseQTI(&buf)
+	if err != nil {
+		t.Fatalf("ParseQTI() error = %v", err)
+	}
+	want := []Question{{Type: TypeMultipleChoice, Text: "What is 2+2?", Choices: []string{"3", "4"}, Answer: 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseQTI() = %+v, want %+v", got, want)
+	}
+	if len(itemErrors) != 2 || itemErrors[0].Item != "r3" || itemErrors[1].Item != "order" {
+		t.Errorf("ParseQTI() item errors = %v", itemErrors)
+	}
+}
+
+func TestParseQTIUncompressedLimit(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("imsmanifest.xml")
+	zeros := make([]byte, 1<<20)
+	for range MaxQTIUncompressedSize/len(zeros) + 1 {
+		f.Write(zeros)
+	}
+	zw.Close()
+	if buf.Len() > MaxQTIPackageSize {
+		t.Fatalf("package is %d bytes, want a small one", buf.Len())
+	}
+	if _, _, err := ParseQTI(&buf); err == nil || !strings.Contains(err.Error(), "uncompressed") {
+		t.Errorf("ParseQTI() error = %v, want too large uncompressed", err)
+	}
+}
@@ -0,0 +1,219 @@
+package quizio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxChoices is the number of choice columns stored per question.
+const MaxChoices = 4
+
+// QuestionType identifies how a question is answered and graded.
+type QuestionType string
+
+const (
+	TypeMultipleChoice QuestionType = "multiple_choice"
+	TypeTrueFalse      QuestionType = "true_false"
+	TypeShortAnswer    QuestionType = "short_answer"
+	TypeNumeric        QuestionType = "numeric"
+)
+
+// Question is the format-neutral representation of a quiz question used by
+// the importers and exporters in this package.
+//
+// Multiple choice and true/false questions use Choices and Answer; true/false
+// questions always have the choices "True" and "False". Short answer
+// questions use AcceptedAnswers and numeric questions use NumericAnswer and
+// Tolerance.
+type Question struct {
+	Type            QuestionType `json:"type"`
+	Text            string       `json:"question"`
+	Choices         []string     `json:"choices,omitempty"`
+	Answer          int          `json:"answer,omitempty"` // 1-based index into Choices
+	AcceptedAnswers []string     `json:"accepted_answers,omitempty"`
+	NumericAnswer   float64      `json:"numeric_answer,omitempty"`
+	Tolerance       float64      `json:"tolerance,omitempty"`
+	Explanation     string       `json:"explanation,omitempty"`
+	// ChoiceFeedback holds optional feedback shown after picking the
+	// choice with the same index.
+	ChoiceFeedback []string `json:"choice_feedback,omitempty"`
+	// Tags are used by quiz sections to select questions.
+	Tags []string `json:"tags,omitempty"`
+	// TimeLimit is the number of seconds a taker has to answer the question
+	// once it is opened, or 0 for no limit.
+	TimeLimit int `json:"time_limit_seconds,omitempty"`
+}
+
+// TrueFalse returns a true/false question whose correct answer is answer.
+func TrueFalse(text string, answer bool) Question {
+	q := Question{
+		Type:    TypeTrueFalse,
+		Text:    text,
+		Choices: []string{"True", "False"},
+		Answer:  1,
+	}
+	if !answer {
+		q.Answer = 2
+	}
+	return q
+}
+
+// Validate reports whether q can be stored as a quizmaker question.
+func (q Question) Validate() error {
+	if strings.TrimSpace(q.Text) == "" {
+		return errors.New("question text is empty")
+	}
+	if q.TimeLimit < 0 {
+		return errors.New("time limit must not be negative")
+	}
+	switch q.Type {
+	case TypeMultipleChoice:
+		return q.validateChoices()
+	case TypeTrueFalse:
+		if len(q.Choices) != 2 {
+			return errors.New("true/false questions have exactly 2 choices")
+		}
+		return q.validateChoices()
+	case TypeShortAnswer:
+		if len(q.ChoiceFeedback) > 0 {
+			return errors.New("choice feedback requires choices")
+		}
+		if len(q.AcceptedAnswers) == 0 {
+			return errors.New("at least 1 accepted answer is required")
+		}
+		for _, a := range q.AcceptedAnswers {
+			if strings.TrimSpace(a) == "" {
+				return errors.New("accepted answers must not be empty")
+			}
+			if strings.Contains(a, "\n") {
+				return errors.New("accepted answers must be a single line")
+			}
+		}
+		return nil
+	case TypeNumeric:
+		if len(q.ChoiceFeedback) > 0 {
+			return errors.New("choice feedback requires choices")
+		}
+		if math.IsNaN(q.NumericAnswer) || math.IsInf(q.NumericAnswer, 0) {
+			return errors.New("numeric answer must be a finite number")
+		}
+		if math.IsNaN(q.Tolerance) || q.Tolerance < 0 {
+			return errors.New("tolerance must not be negative")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported question type %q", q.Type)
+	}
+}
+
+func (q Question) validateChoices() error {
+	if len(q.ChoiceFeedback) > len(q.Choices) {
+		return errors.New("more choice feedback than choices")
+	}
+	if len(q.Choices) > MaxChoices {
+		return fmt.Errorf("at most %d choices are supported, got %d", MaxChoices, len(q.Choices))
+	}
+	filled := 0
+	for _, choice := range q.Choices {
+		if strings.TrimSpace(choice) != "" {
+			filled++
+		}
+	}
+	if filled < 2 {
+		return errors.New("at least 2 choices are required")
+	}
+	if q.Answer < 1 || q.Answer > len(q.Choices) {
+		return fmt.Errorf("answer %d does not refer to a choice", q.Answer)
+	}
+	if strings.TrimSpace(q.Choices[q.Answer-1]) == "" {
+		return fmt.Errorf("answer %d refers to an empty choice", q.Answer)
+	}
+	return nil
+}
+
+// Choice returns the 1-based choice i, or an empty string if it is not set.
+func (q Question) Choice(i int) string {
+	if i < 1 || i > len(q.Choices) {
+		return ""
+	}
+	return q.Choices[i-1]
+}
+
+// Feedback returns the feedback for the 1-based choice i, or an empty string
+// if there is none.
+func (q Question) Feedback(i int) string {
+	if i < 1 || i > len(q.ChoiceFeedback) {
+		return ""
+	}
+	return q.ChoiceFeedback[i-1]
+}
+
+// Check reports whether a response answers q correctly. Questions with
+// choices are answered with the 1-based choice number, other questions with
+// text. Short answers are compared ignoring case and surrounding space.
+func (q Question) Check(choice int, text string) bool {
+	switch q.Type {
+	case TypeShortAnswer:
+		text = strings.Join(strings.Fields(text), " ")
+		for _, a := range q.AcceptedAnswers {
+			if strings.EqualFold(text, strings.Join(strings.Fields(a), " ")) {
+				return true
+			}
+		}
+		return false
+	case TypeNumeric:
+		value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return false
+		}
+		// Allow for the rounding error of decimal answers like 0.1+0.2.
+		return math.Abs(value-q.NumericAnswer) <= q.Tolerance+1e-9*math.Max(1, math.Abs(q.NumericAnswer))
+	default:
+		return choice == q.Answer
+	}
+}
+
+// CorrectAnswerText describes the correct answer to q for showing to a
+// taker: the correct choice, the first accepted answer, or the numeric answer
+// with its tolerance.
+func (q Question) CorrectAnswerText() string {
+	switch q.Type {
+	case TypeShortAnswer:
+		if len(q.AcceptedAnswers) > 0 {
+			return q.AcceptedAnswers[0]
+		}
+		return ""
+	case TypeNumeric:
+		text := strconv.FormatFloat(q.NumericAnswer, 'f', -1, 64)
+		if q.Tolerance > 0 {
+			text += " ± " + strconv.FormatFloat(q.Tolerance, 'f', -1, 64)
+		}
+		return text
+	default:
+		return q.Choice(q.Answer)
+	}
+}
+
+// NormalizeTags lowercases tags, collapses their whitespace and drops empty
+// and repeated ones, so that tags compare equal however they were typed.
+func NormalizeTags(tags []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	return out
+}
+
+// HasChoices reports whether q is answered by picking one of its choices.
+func (q Question) HasChoices() bool {
+	return q.Type == TypeMultipleChoice || q.Type == TypeTrueFalse
+}
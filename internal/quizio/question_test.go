@@ -0,0 +1,41 @@
+package quizio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		question Question
+		choice   int
+		text     string
+		want     bool
+	}{
+		{name: "Right choice", question: Question{Type: TypeMultipleChoice, Answer: 2}, choice: 2, want: true},
+		{name: "Wrong choice", question: Question{Type: TypeMultipleChoice, Answer: 2}, choice: 1, want: false},
+		{name: "True/false", question: TrueFalse("", false), choice: 2, want: true},
+		{name: "Short answer", question: Question{Type: TypeShortAnswer, AcceptedAnswers: []string{"Ulysses S. Grant"}}, text: "  ulysses  s. grant ", want: true},
+		{name: "Wrong short answer", question: Question{Type: TypeShortAnswer, AcceptedAnswers: []string{"Grant"}}, text: "Lee", want: false},
+		{name: "Exact number", question: Question{Type: TypeNumeric, NumericAnswer: 0.3}, text: "0.30000000000000004", want: true},
+		{name: "Within tolerance", question: Question{Type: TypeNumeric, NumericAnswer: 3.14, Tolerance: 0.01}, text: "3.15", want: true},
+		{name: "Outside tolerance", question: Question{Type: TypeNumeric, NumericAnswer: 3.14, Tolerance: 0.01}, text: "3.2", want: false},
+		{name: "Not a number", question: Question{Type: TypeNumeric, NumericAnswer: 3}, text: "three", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.question.Check(tt.choice, tt.text); got != tt.want {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got := NormalizeTags([]string{" Chapter  1 ", "chapter 1", "", "Easy\n"})
+	want := []string{"chapter 1", "easy"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeTags() = %q, want %q", got, want)
+	}
+}
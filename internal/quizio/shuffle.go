@@ -0,0 +1,86 @@
+package quizio
+
+import "math/rand/v2"
+
+// ShuffleOrder returns a permutation of 0..n-1 that depends only on seed and
+// stream, so the same order can be rebuilt later from the stored seed.
+func ShuffleOrder(n int, seed, stream uint64) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	rng := rand.New(rand.NewPCG(seed, stream))
+	rng.Shuffle(n, func(i, j int) {
+		order[i], order[j] = order[j], order[i]
+	})
+	return order
+}
+
+// Shuffled returns a copy of questions in an order determined by seed, with
+// the choices of multiple choice questions shuffled as well. Empty choices
+// are dropped and Answer is updated to point at the moved correct choice.
+// True/false questions keep their order.
+func Shuffled(questions []Question, seed uint64) []Question {
+	order := ShuffleOrder(len(questions), seed, 0)
+	out := make([]Question, len(questions))
+	for i, idx := range order {
+		q := questions[idx]
+		if q.Type == TypeMultipleChoice {
+			q = shuffleChoices(q, seed, uint64(idx)+1)
+		}
+		out[i] = q
+	}
+	return out
+}
+
+func shuffleChoices(q Question, seed, stream uint64) Question {
+	return q.Reordered(q.ShuffledChoiceNumbers(seed, stream))
+}
+
+// ChoiceNumbers returns the 1-based numbers of the non-empty choices of q.
+func (q Question) ChoiceNumbers() []int {
+	var numbers []int
+	for i, choice := range q.Choices {
+		if choice != "" {
+			numbers = append(numbers, i+1)
+		}
+	}
+	return numbers
+}
+
+// ShuffledChoiceNumbers returns ChoiceNumbers in an order determined by seed
+// and stream. True/false questions keep their order.
+func (q Question) ShuffledChoiceNumbers(seed, stream uint64) []int {
+	numbers := q.ChoiceNumbers()
+	if q.Type == TypeTrueFalse {
+		return numbers
+	}
+	order := make([]int, len(numbers))
+	for i, idx := range ShuffleOrder(len(numbers), seed, stream) {
+		order[i] = numbers[idx]
+	}
+	return order
+}
+
+// Reordered returns a copy of q showing the 1-based choices listed in order,
+// with Answer and ChoiceFeedback following their choices. Choices missing
+// from order are dropped, and Answer is 0 if the correct choice is one of
+// them.
+func (q Question) Reordered(order []int) Question {
+	out := q
+	out.Choices = make([]string, len(order))
+	out.Answer = 0
+	if len(q.ChoiceFeedback) > 0 {
+		out.ChoiceFeedback = make([]string, len(order))
+	}
+	for i, n := range order {
+		out.Choices[i] = q.Choice(n)
+		if out.ChoiceFeedback != nil {
+			out.ChoiceFeedback[i] = q.Feedback(n)
+		}
+		if n == q.Answer {
+			out.Answer = i + 1
+		}
+	}
+	return out
+}
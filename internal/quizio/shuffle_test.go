@@ -0,0 +1,81 @@
+package quizio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShuffled(t *testing.T) {
+	var questions []Question
+	for i := 0; i < 20; i++ {
+		questions = append(questions, Question{
+			Type:    TypeMultipleChoice,
+			Text:    string(rune('a' + i)),
+			Choices: []string{"w", "x", "", "z"},
+			Answer:  4,
+		})
+	}
+	questions = append(questions, TrueFalse("tf", false))
+
+	first := Shuffled(questions, 42)
+	second := Shuffled(questions, 42)
+	if !reflect.DeepEqual(first, second) {
+		t.Fatal("Shuffled() is not deterministic for the same seed")
+	}
+	if reflect.DeepEqual(first, Shuffled(questions, 43)) {
+		t.Error("Shuffled() gave the same order for different seeds")
+	}
+
+	seen := map[string]bool{}
+	for _, q := range first {
+		seen[q.Text] = true
+		if q.Type == TypeTrueFalse {
+			if !reflect.DeepEqual(q.Choices, []string{"True", "False"}) || q.Answer != 2 {
+				t.Errorf("true/false question changed: %+v", q)
+			}
+			continue
+		}
+		if len(q.Choices) != 3 {
+			t.Errorf("got %d choices, want empty choice dropped: %+v", len(q.Choices), q)
+		}
+		if q.Choice(q.Answer) != "z" {
+			t.Errorf("answer points at %q, want \"z\"", q.Choice(q.Answer))
+		}
+	}
+	if len(seen) != len(questions) {
+		t.Errorf("Shuffled() lost questions: got %d distinct, want %d", len(seen), len(questions))
+	}
+}
+
+func TestReordered(t *testing.T) {
+	q := Question{
+		Type:           TypeMultipleChoice,
+		Choices:        []string{"a", "", "c", "d"},
+		Answer:         3,
+		ChoiceFeedback: []string{"fa", "", "fc"},
+	}
+	if got := q.ChoiceNumbers(); !reflect.DeepEqual(got, []int{1, 3, 4}) {
+		t.Fatalf("ChoiceNumbers() = %v, want [1 3 4]", got)
+	}
+	got := q.Reordered([]int{4, 3, 1})
+	if !reflect.DeepEqual(got.Choices, []string{"d", "c", "a"}) {
+		t.Errorf("Choices = %q", got.Choices)
+	}
+	if !reflect.DeepEqual(got.ChoiceFeedback, []string{"", "fc", "fa"}) {
+		t.Errorf("ChoiceFeedback = %q", got.ChoiceFeedback)
+	}
+	if got.Answer != 2 {
+		t.Errorf("Answer = %d, want 2", got.Answer)
+	}
+	if q.Answer != 3 || q.Choices[1] != "" {
+		t.Error("Reordered() modified the original question")
+	}
+
+	order := q.ShuffledChoiceNumbers(7, 3)
+	if !reflect.DeepEqual(order, q.ShuffledChoiceNumbers(7, 3)) {
+		t.Error("ShuffledChoiceNumbers() is not deterministic")
+	}
+	if len(order) != 3 {
+		t.Errorf("ShuffledChoiceNumbers() = %v, want the 3 non-empty choices", order)
+	}
+}
@@ -0,0 +1,69 @@
+package worksheet
+
+import (
+	"html/template"
+	"io"
+)
+
+var htmlTemplate = template.Must(template.New("worksheet").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>{{ .Heading }}</title>
+    <style>
+        @page { size: A4; margin: 20mm; }
+        body { font-family: "Courier New", Courier, monospace; font-size: 10pt; }
+        .page { break-after: page; min-height: 250mm; position: relative; }
+        .page:last-child { break-after: auto; }
+        .page h1 { font-size: 12pt; margin: 0 0 14pt 0; }
+        .line { margin: 0; min-height: 14pt; line-height: 14pt; white-space: pre-wrap; }
+        .bold { font-weight: bold; }
+        .footer { position: absolute; bottom: 0; width: 100%; text-align: center; }
+        @media screen {
+            .page { border-bottom: 1px dashed #999; padding-bottom: 24pt; margin-bottom: 24pt; }
+        }
+    </style>
+</head>
+<body>
+{{- range .Pages }}
+    <section class="page">
+        <h1>{{ $.Heading }}</h1>
+        {{- range .Lines }}
+        <p class="line{{ if .Bold }} bold{{ end }}" style="padding-left: {{ .Indent }}ch">{{ .Text }}</p>
+        {{- end }}
+        <p class="footer">Page {{ .Number }} of {{ $.Total }}</p>
+    </section>
+{{- end }}
+</body>
+</html>
+`))
+
+// WriteHTML writes the worksheet as a printable HTML document with the same
+// page breaks as WritePDF.
+func WriteHTML(w io.Writer, ws Worksheet) error {
+	type htmlLine struct {
+		Text   string
+		Indent int
+		Bold   bool
+	}
+	type htmlPage struct {
+		Number int
+		Lines  []htmlLine
+	}
+	data := struct {
+		Heading string
+		Total   int
+		Pages   []htmlPage
+	}{
+		Heading: ws.heading(),
+	}
+	for _, p := range ws.pages() {
+		hp := htmlPage{Number: p.number}
+		for _, l := range p.lines {
+			hp.Lines = append(hp.Lines, htmlLine{Text: l.text, Indent: l.indent, Bold: l.bold})
+		}
+		data.Pages = append(data.Pages, hp)
+	}
+	data.Total = len(data.Pages)
+	return htmlTemplate.Execute(w, data)
+}
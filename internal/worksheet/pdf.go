@@ -0,0 +1,129 @@
+package worksheet
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Page geometry in PDF points for an A4 page set in 10pt Courier, whose
+// glyphs are all 6pt wide.
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	marginLeft = 60
+	marginTop  = 60
+	fontSize   = 10
+	charWidth  = 6
+	leading    = 14
+)
+
+// WritePDF writes the worksheet as a PDF document. It uses the standard
+// Courier fonts with WinAnsiEncoding, so characters outside Windows-1252 are
+// printed as "?".
+func WritePDF(w io.Writer, ws Worksheet) error {
+	pages := ws.pages()
+	pdf := &pdfWriter{}
+	pdf.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-4 are the catalog, page tree and fonts; each page then
+	// takes two objects, the page and its content stream.
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	pdf.object("<< /Type /Catalog /Pages 2 0 R >>")
+	pdf.object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	pdf.object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	pdf.object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, p := range pages {
+		pdf.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+2*i))
+
+		var content bytes.Buffer
+		y := pageHeight - marginTop
+		writeText(&content, "F2", marginLeft, y, ws.heading())
+		y -= 2 * leading
+		for _, l := range p.lines {
+			if l.text != "" {
+				font := "F1"
+				if l.bold {
+					font = "F2"
+				}
+				writeText(&content, font, marginLeft+l.indent*charWidth, y, l.text)
+			}
+			y -= leading
+		}
+		footer := fmt.Sprintf("Page %d of %d", p.number, len(pages))
+		writeText(&content, "F1", (pageWidth-len(footer)*charWidth)/2, marginTop/2, footer)
+		pdf.object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := pdf.buf.Len()
+	fmt.Fprintf(&pdf.buf, "xref\n0 %d\n0000000000 65535 f \n", len(pdf.offsets)+1)
+	for _, off := range pdf.offsets {
+		fmt.Fprintf(&pdf.buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&pdf.buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(pdf.offsets)+1, xref)
+
+	bw := bufio.NewWriter(w)
+	if _, err := pdf.buf.WriteTo(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+// object appends the next numbered indirect object.
+func (p *pdfWriter) object(body string) {
+	p.offsets = append(p.offsets, p.buf.Len())
+	fmt.Fprintf(&p.buf, "%d 0 obj\n%s\nendobj\n", len(p.offsets), body)
+}
+
+func writeText(w *bytes.Buffer, font string, x, y int, text string) {
+	fmt.Fprintf(w, "BT /%s %d Tf %d %d Td (", font, fontSize, x, y)
+	for _, b := range winAnsi(text) {
+		switch b {
+		case '(', ')', '\\':
+			w.WriteByte('\\')
+			w.WriteByte(b)
+		default:
+			w.WriteByte(b)
+		}
+	}
+	w.WriteString(") Tj ET\n")
+}
+
+// winAnsiSpecials maps the characters Windows-1252 places in 0x80-0x9F.
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+func winAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			out = append(out, ' ')
+		case r >= 0x20 && r < 0x7F, r >= 0xA0 && r <= 0xFF:
+			out = append(out, byte(r))
+		default:
+			if b, ok := winAnsiSpecials[r]; ok {
+				out = append(out, b)
+			} else {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
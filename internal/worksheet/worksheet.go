@@ -0,0 +1,159 @@
+// Package worksheet lays out quizzes for printing, as HTML or PDF, either as
+// a worksheet for takers to fill in or as the matching answer key.
+package worksheet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Corogura/quizmaker/internal/quizio"
+)
+
+const (
+	// lineWidth is the number of characters that fit on a line in the
+	// monospaced font used by the PDF writer.
+	lineWidth = 76
+	// linesPerPage is the number of body lines on a page, excluding the
+	// page header and footer.
+	linesPerPage = 48
+)
+
+// Worksheet is a quiz prepared for printing.
+type Worksheet struct {
+	Title     string
+	Questions []quizio.Question
+	// AnswerKey prints the correct answers instead of blanks to fill in.
+	AnswerKey bool
+}
+
+type line struct {
+	text   string
+	indent int
+	bold   bool
+}
+
+type page struct {
+	number int
+	lines  []line
+}
+
+// heading returns the title printed at the top of every page.
+func (ws Worksheet) heading() string {
+	if ws.AnswerKey {
+		return ws.Title + " - Answer key"
+	}
+	return ws.Title
+}
+
+// pages lays the worksheet out on pages, starting a new page rather than
+// splitting a question unless the question is longer than a page.
+func (ws Worksheet) pages() []page {
+	var blocks [][]line
+	if !ws.AnswerKey {
+		blocks = append(blocks, []line{{text: "Name: ______________________________   Date: ______________"}, {}})
+	}
+	for i, q := range ws.Questions {
+		blocks = append(blocks, ws.questionLines(i+1, q))
+	}
+
+	pages := []page{{number: 1}}
+	for _, block := range blocks {
+		current := &pages[len(pages)-1]
+		if len(current.lines)+len(block) > linesPerPage && len(current.lines) > 0 {
+			pages = append(pages, page{number: len(pages) + 1})
+			current = &pages[len(pages)-1]
+		}
+		for _, l := range block {
+			if len(current.lines) == linesPerPage {
+				pages = append(pages, page{number: len(pages) + 1})
+				current = &pages[len(pages)-1]
+			}
+			current.lines = append(current.lines, l)
+		}
+	}
+	return pages
+}
+
+func (ws Worksheet) questionLines(number int, q quizio.Question) []line {
+	prefix := strconv.Itoa(number) + ". "
+	lines := wrap(prefix+q.Text, 0, len(prefix))
+	lines[0].bold = true
+	if ws.AnswerKey {
+		lines = append(lines, wrap("Answer: "+answerText(q), 3, 3+len("Answer: "))...)
+		if q.Explanation != "" {
+			lines = append(lines, wrap("Explanation: "+q.Explanation, 3, 3+len("Explanation: "))...)
+		}
+	} else if q.HasChoices() {
+		letter := 'A'
+		for _, choice := range q.Choices {
+			if choice == "" {
+				continue
+			}
+			lines = append(lines, wrap(fmt.Sprintf("[ ] %c) %s", letter, choice), 3, 10)...)
+			letter++
+		}
+	} else {
+		lines = append(lines, line{text: "Answer: ________________________________", indent: 3})
+	}
+	return append(lines, line{})
+}
+
+func answerText(q quizio.Question) string {
+	switch q.Type {
+	case quizio.TypeShortAnswer:
+		return strings.Join(q.AcceptedAnswers, " / ")
+	case quizio.TypeNumeric:
+		answer := strconv.FormatFloat(q.NumericAnswer, 'f', -1, 64)
+		if q.Tolerance != 0 {
+			answer += " (+/- " + strconv.FormatFloat(q.Tolerance, 'f', -1, 64) + ")"
+		}
+		return answer
+	default:
+		letter := 'A'
+		for i, choice := range q.Choices {
+			if choice == "" {
+				continue
+			}
+			if i+1 == q.Answer {
+				return fmt.Sprintf("%c) %s", letter, choice)
+			}
+			letter++
+		}
+		return ""
+	}
+}
+
+// wrap breaks text into lines of at most lineWidth characters including the
+// indent. Continuation lines are indented by hanging instead of indent.
+func wrap(text string, indent, hanging int) []line {
+	var lines []line
+	currentIndent := indent
+	emit := func(text string) {
+		lines = append(lines, line{text: text, indent: currentIndent})
+		currentIndent = hanging
+	}
+	for _, paragraph := range strings.Split(text, "\n") {
+		current := ""
+		for _, word := range strings.Fields(paragraph) {
+			if current != "" && currentIndent+utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) > lineWidth {
+				emit(current)
+				current = ""
+			}
+			if current != "" {
+				current += " " + word
+				continue
+			}
+			for utf8.RuneCountInString(word) > lineWidth-currentIndent {
+				runes := []rune(word)
+				room := lineWidth - currentIndent
+				emit(string(runes[:room]))
+				word = string(runes[room:])
+			}
+			current = word
+		}
+		emit(current)
+	}
+	return lines
+}
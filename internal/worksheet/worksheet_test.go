@@ -0,0 +1,123 @@
+package worksheet
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Corogura/quizmaker/internal/quizio"
+)
+
+func testQuestions(n int) []quizio.Question {
+	var questions []quizio.Question
+	for i := 0; i < n; i++ {
+		questions = append(questions, quizio.Question{
+			Type:    quizio.TypeMultipleChoice,
+			Text:    fmt.Sprintf("Question %d %s", i+1, strings.Repeat("lorem ipsum ", 10)),
+			Choices: []string{"alpha", "bravo", "charlie (correct)", "delta"},
+			Answer:  3,
+		})
+	}
+	return questions
+}
+
+func TestPages(t *testing.T) {
+	ws := Worksheet{Title: "Test", Questions: testQuestions(30)}
+	pages := ws.pages()
+	if len(pages) < 2 {
+		t.Fatalf("got %d pages, want the worksheet split over several", len(pages))
+	}
+	for _, p := range pages {
+		if len(p.lines) > linesPerPage {
+			t.Errorf("page %d has %d lines, want at most %d", p.number, len(p.lines), linesPerPage)
+		}
+		for _, l := range p.lines {
+			if l.indent+utf8.RuneCountInString(l.text) > lineWidth {
+				t.Errorf("line %q is wider than %d characters", l.text, lineWidth)
+			}
+		}
+		// Questions fit on a page, so each page should start with one.
+		if p.number > 1 && !p.lines[0].bold {
+			t.Errorf("page %d starts mid-question with %q", p.number, p.lines[0].text)
+		}
+	}
+}
+
+func TestWrapLongWord(t *testing.T) {
+	lines := wrap(strings.Repeat("x", 200), 3, 6)
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+	total := 0
+	for _, l := range lines {
+		if l.indent+utf8.RuneCountInString(l.text) > lineWidth {
+			t.Errorf("line is %d characters wide", l.indent+len(l.text))
+		}
+		total += len(l.text)
+	}
+	if total != 200 {
+		t.Errorf("wrapped %d characters, want 200", total)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	questions := testQuestions(2)
+	questions[0].Text = "<script>alert(1)</script>"
+
+	var worksheet, key bytes.Buffer
+	if err := WriteHTML(&worksheet, Worksheet{Title: "Quiz", Questions: questions}); err != nil {
+		t.Fatalf("WriteHTML() error = %v", err)
+	}
+	if err := WriteHTML(&key, Worksheet{Title: "Quiz", Questions: questions, AnswerKey: true}); err != nil {
+		t.Fatalf("WriteHTML() error = %v", err)
+	}
+	if strings.Contains(worksheet.String(), "<script>") {
+		t.Error("WriteHTML() did not escape question text")
+	}
+	if strings.Contains(worksheet.String(), "Answer: C)") {
+		t.Error("worksheet contains the answers")
+	}
+	if !strings.Contains(key.String(), "Answer: C) charlie (correct)") {
+		t.Error("answer key is missing the answers")
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	questions := testQuestions(30)
+	questions[0].Text = "Ünïcödé (parens) \\ and 日本"
+	var buf bytes.Buffer
+	if err := WritePDF(&buf, Worksheet{Title: "Quiz", Questions: questions}); err != nil {
+		t.Fatalf("WritePDF() error = %v", err)
+	}
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatal("WritePDF() output is not framed as a PDF")
+	}
+	want := append([]byte("(1. \xdcn\xefc\xf6d\xe9"), ` \(parens\) \\ and ??`...)
+	if !bytes.Contains(data, want) {
+		t.Error("WritePDF() did not encode or escape text")
+	}
+
+	// Every xref entry must point at the start of its object.
+	startxref := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(data)
+	if startxref == nil {
+		t.Fatal("missing startxref")
+	}
+	offset, _ := strconv.Atoi(string(startxref[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(data[offset:], -1)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		want := fmt.Sprintf("%d 0 obj", i+1)
+		if !bytes.HasPrefix(data[off:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q, want %q", i+1, data[off:off+10], want)
+		}
+	}
+	pages := len(Worksheet{Title: "Quiz", Questions: questions}.pages())
+	if got := bytes.Count(data, []byte("/Type /Page ")); got != pages {
+		t.Errorf("PDF has %d pages, want %d", got, pages)
+	}
+}
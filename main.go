@@ -3,34 +3,101 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	netmail "net/mail"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/Corogura/quizmaker/internal/auth"
 	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/Corogura/quizmaker/internal/feed"
+	"github.com/Corogura/quizmaker/internal/live"
+	"github.com/Corogura/quizmaker/internal/mail"
+	"github.com/Corogura/quizmaker/internal/oidc"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 
 	_ "github.com/tursodatabase/libsql-client-go/libsql"
 )
 
 type apiConfig struct {
-	db        *database.Queries
-	jwtSecret string
+	db      *database.Queries
+	dbConn  *sql.DB
+	jwtKeys *auth.KeySet
+	mailer  mail.Mailer
+	// publicURL is where the app is reached, for links in emails.
+	publicURL   string
+	revocations *auth.Revocations
+	// accessTokenTTL and refreshTokenTTL are how long the tokens issued at
+	// login last. Each use of a refresh token replaces it with a new one
+	// that lasts as long again.
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	// oidc is the identity provider users can log in with, or nil if there
+	// is none. oidcName is what the login button calls it.
+	oidc     *oidc.Provider
+	oidcName string
+	// adminEmails are the email addresses of the users who are admins.
+	adminEmails map[string]bool
+	// passwords hashes new passwords, which must follow passwordPolicy.
+	// dummyPasswordHash is checked against when logging in to an account
+	// that doesn't exist, so that it takes as long as a wrong password.
+	passwords         auth.PasswordHasher
+	passwordPolicy    *auth.PasswordPolicy
+	dummyPasswordHash string
+	live              *live.Hub
+	feed              *feed.Broker
 }
 
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 7 * 24 * time.Hour
+	// revocationsMaxAge is how long access token revocations made by other
+	// instances take to apply.
+	revocationsMaxAge = time.Minute
+	defaultBcryptCost = 12
+	// defaultMinPasswordLength is the least NIST allows. maxPasswordLength
+	// keeps hashing a password cheap enough; bcrypt allows less.
+	defaultMinPasswordLength = 8
+	maxPasswordLength        = 256
+)
+
 func main() {
 	_ = godotenv.Load(".env")
 
-	jwtSecret := os.Getenv("JWT_SECRET")
+	jwtKeys, err := keySetFromEnv()
+	if err != nil {
+		panic("Invalid JWT keys: " + err.Error())
+	}
 	port := fmt.Sprintf(":%s", os.Getenv("PORT"))
 	if port == "" {
 		port = ":8080" // Default port if not set
 	}
+	publicURL := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	if publicURL == "" {
+		publicURL = "http://localhost" + port
+	}
+	mailer, err := mailerFromEnv()
+	if err != nil {
+		panic("Invalid mail settings: " + err.Error())
+	}
+	passwords := passwordHasherFromEnv()
+	passwordPolicy, err := passwordPolicyFromEnv(passwords)
+	if err != nil {
+		panic("Invalid password policy: " + err.Error())
+	}
+	dummyPasswordHash, err := passwords.Hash("quizmaker-dummy-password")
+	if err != nil {
+		panic("Couldn't hash password: " + err.Error())
+	}
 	dbURL := os.Getenv("DATABASE_URL")
 	if dbURL == "" {
 		panic("DATABASE_URL environment variable is not set")
@@ -42,28 +109,100 @@ func main() {
 	defer db.Close()
 	dbQueries := database.New(db)
 	cfg := apiConfig{
-		db:        dbQueries,
-		jwtSecret: jwtSecret,
+		db:                dbQueries,
+		dbConn:            db,
+		jwtKeys:           jwtKeys,
+		mailer:            mailer,
+		publicURL:         publicURL,
+		revocations:       auth.NewRevocations(revocationStore{db: dbQueries}, revocationsMaxAge),
+		accessTokenTTL:    durationEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL),
+		refreshTokenTTL:   durationEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL),
+		oidc:              oidcProviderFromEnv(publicURL),
+		oidcName:          os.Getenv("OIDC_NAME"),
+		adminEmails:       adminEmailsFromEnv(),
+		passwords:         passwords,
+		passwordPolicy:    passwordPolicy,
+		dummyPasswordHash: dummyPasswordHash,
+		live:              live.NewHub(),
+		feed:              feed.NewBroker(),
 	}
 	r := gin.Default()
+	// Client IP addresses are used for login lockouts and rate limits, so
+	// X-Forwarded-For is only believed from the proxies named.
+	if err := r.SetTrustedProxies(trustedProxiesFromEnv()); err != nil {
+		panic("Invalid trusted proxies: " + err.Error())
+	}
 	r.LoadHTMLFiles("static/quiz.html")
 	// ---------- Register routes ----------
 	r.POST("/users/create", cfg.handlerUsersCreate)
 	r.POST("/users/login", cfg.handlerUsersLogin)
+	r.POST("/users/login/2fa", cfg.handlerLoginTwoFactor)
+	r.POST("/users/login/oidc", cfg.handlerLoginOIDC)
+	r.GET("/auth/oidc", cfg.handlerOIDCConfig)
+	r.GET("/auth/oidc/login", cfg.handlerOIDCLogin)
+	r.GET("/auth/oidc/callback", cfg.handlerOIDCCallback)
+	r.POST("/users/guest", cfg.handlerUsersGuest)
+	r.POST("/users/upgrade", cfg.handlerUsersUpgrade)
 	r.PUT("/users/password", cfg.handlerUpdatePassword)
+	r.PUT("/users/display-name", cfg.handlerUpdateDisplayName)
 	r.GET("/users/refresh", cfg.handlerRefreshJWT)
 	r.PUT("/users/revoke", cfg.handlerRevokeRefreshToken)
 	r.GET("/users/validate", cfg.handlerValidateJWT)
+	r.POST("/users/verify-email/request", cfg.handlerRequestEmailVerification)
+	r.POST("/users/verify-email", cfg.handlerVerifyEmail)
+	r.POST("/users/reset-password/request", cfg.handlerRequestPasswordReset)
+	r.POST("/users/reset-password", cfg.handlerResetPassword)
+	r.GET("/.well-known/jwks.json", cfg.handlerJWKS)
+	r.GET("/users/sessions", cfg.handlerGetSessions)
+	r.DELETE("/users/sessions", cfg.handlerRevokeAllSessions)
+	r.DELETE("/users/sessions/:id", cfg.handlerRevokeSession)
+	r.GET("/users/2fa", cfg.handlerGetTwoFactor)
+	r.POST("/users/2fa/totp", cfg.handlerEnrollTOTP)
+	r.POST("/users/2fa/totp/confirm", cfg.handlerConfirmTOTP)
+	r.POST("/users/2fa/totp/disable", cfg.handlerDisableTOTP)
+	r.POST("/users/2fa/recovery-codes", cfg.handlerRegenerateRecoveryCodes)
+	r.GET("/users/api-keys", cfg.handlerGetAPIKeys)
+	r.POST("/users/api-keys", cfg.handlerCreateAPIKey)
+	r.DELETE("/users/api-keys/:id", cfg.handlerDeleteAPIKey)
+	r.POST("/admin/unlock", cfg.handlerAdminUnlock)
+	r.GET("/admin/audit-events", cfg.handlerAdminAuditEvents)
+	r.POST("/takers", cfg.handlerTakersCreate)
 	r.POST("/quizzes", cfg.handlerQuizzesCreate)
 	r.POST("/quizzes/:path", cfg.handlerQuestionsCreate)
 	r.DELETE("/quizzes/:path", cfg.handlerQuizzesDelete)
 	r.DELETE("/quizzes/:path/questions/:question_number", cfg.handlerQuestionsDelete)
+	r.PUT("/quizzes/:path/questions/:question_number", cfg.handlerQuestionsUpdate)
+	r.POST("/quizzes/:path/questions/:question_number/answer", cfg.handlerQuestionsAnswer)
 	r.PUT("/quizzes/:path", cfg.handlerUpdateQuizTitle)
 	r.StaticFile("/", "./static/index.html")
 	r.GET("/quizzes", cfg.handlerGetAllQuizzesForUser)
 	r.GET("/quizzes/:path", cfg.handlerServeQuizPage)
 	r.GET("/quizzes/:path/questions", cfg.handlerGetAllQuestionsInQuiz)
 	r.GET("/quizzes/:path/owner", cfg.handlerChechOwnerOfQuiz)
+	r.POST("/quizzes/:path/questions/csv", cfg.handlerQuestionsImportCSV)
+	r.GET("/quizzes/:path/questions/csv", cfg.handlerQuestionsExportCSV)
+	r.POST("/quizzes/:path/questions/gift", cfg.handlerQuestionsImportGIFT)
+	r.GET("/quizzes/:path/questions/gift", cfg.handlerQuestionsExportGIFT)
+	r.POST("/quizzes/:path/questions/aiken", cfg.handlerQuestionsImportAiken)
+	r.GET("/quizzes/:path/questions/aiken", cfg.handlerQuestionsExportAiken)
+	r.POST("/quizzes/:path/questions/qti", cfg.handlerQuestionsImportQTI)
+	r.GET("/quizzes/:path/questions/qti", cfg.handlerQuestionsExportQTI)
+	r.GET("/quizzes/:path/print", cfg.handlerPrintQuiz)
+	r.GET("/quizzes/:path/settings", cfg.handlerGetQuizSettings)
+	r.PUT("/quizzes/:path/settings", cfg.handlerUpdateQuizSettings)
+	r.GET("/quizzes/:path/sections", cfg.handlerGetQuizSections)
+	r.PUT("/quizzes/:path/sections", cfg.handlerUpdateQuizSections)
+	r.POST("/quizzes/:path/attempts", cfg.handlerAttemptsStart)
+	r.GET("/attempts/:id", cfg.handlerAttemptsGet)
+	r.POST("/attempts/:id/answers", cfg.handlerAttemptsAnswer)
+	r.POST("/attempts/:id/questions/:position/open", cfg.handlerAttemptsOpenQuestion)
+	r.POST("/attempts/:id/finish", cfg.handlerAttemptsFinish)
+	r.POST("/quizzes/:path/live", cfg.handlerLiveStart)
+	r.GET("/live/:code/host", cfg.handlerLiveHost)
+	r.GET("/live/:code/play", cfg.handlerLivePlay)
+	r.StaticFile("/live", "./static/live.html")
+	r.GET("/quizzes/:path/events", cfg.handlerQuizEvents)
+	r.GET("/quizzes/:path/leaderboard", cfg.handlerGetLeaderboard)
 	r.Static("/static", "./static")
 	// ---------- End of routes ----------
 
@@ -71,6 +210,9 @@ func main() {
 		Addr:    port,
 		Handler: r,
 	}
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go cfg.finishExpiredAttempts(background)
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("ListenAndServe(): %s", err)
@@ -81,6 +223,11 @@ func main() {
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 	<-quit
 	log.Println("Shutting down server...")
+	stopBackground()
+	// Live sessions run over hijacked connections, which Shutdown doesn't
+	// wait for, and event streams never end on their own, so end them first.
+	cfg.live.Close()
+	cfg.feed.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -89,3 +236,199 @@ func main() {
 	}
 	log.Println("Server exiting gracefully")
 }
+
+// durationEnv reads a duration such as "15m" from an environment variable,
+// or returns fallback if it is not set.
+func durationEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		panic(fmt.Sprintf("%s environment variable is not a valid duration: %q", name, value))
+	}
+	return d
+}
+
+// trustedProxiesFromEnv reads the addresses or CIDR ranges of the proxies in
+// front of the server from TRUSTED_PROXIES, separated by commas. With none,
+// a client's address is the one it connected from.
+func trustedProxiesFromEnv() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// intEnv reads a whole number from an environment variable, or returns
+// fallback if it is not set.
+func intEnv(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		panic(fmt.Sprintf("%s environment variable is not a valid number: %q", name, value))
+	}
+	return n
+}
+
+// passwordHasherFromEnv returns the hasher new passwords are hashed with.
+// PASSWORD_HASH is argon2id, the default, or bcrypt. Argon2id takes
+// ARGON2_TIME passes over ARGON2_MEMORY KiB with ARGON2_THREADS threads,
+// and bcrypt has a cost of BCRYPT_COST. Passwords hashed otherwise are
+// hashed again when their users next log in.
+func passwordHasherFromEnv() auth.PasswordHasher {
+	switch algorithm := os.Getenv("PASSWORD_HASH"); algorithm {
+	case "", "argon2id":
+		defaults := auth.DefaultArgon2idHasher
+		threads := intEnv("ARGON2_THREADS", int(defaults.Threads))
+		if threads > 255 {
+			panic("ARGON2_THREADS environment variable must be at most 255")
+		}
+		return auth.Argon2idHasher{
+			Time:    uint32(intEnv("ARGON2_TIME", int(defaults.Time))),
+			Memory:  uint32(intEnv("ARGON2_MEMORY", int(defaults.Memory))),
+			Threads: uint8(threads),
+		}
+	case "bcrypt":
+		cost := intEnv("BCRYPT_COST", defaultBcryptCost)
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			panic(fmt.Sprintf("BCRYPT_COST environment variable must be from %d to %d", bcrypt.MinCost, bcrypt.MaxCost))
+		}
+		return auth.BcryptHasher{Cost: cost}
+	default:
+		panic(fmt.Sprintf("PASSWORD_HASH environment variable is not argon2id or bcrypt: %q", algorithm))
+	}
+}
+
+// passwordPolicyFromEnv returns the policy new passwords must follow. They
+// must be at least PASSWORD_MIN_LENGTH characters long, and not be among
+// the common passwords built in or those listed one per line in
+// BREACHED_PASSWORDS_FILE.
+func passwordPolicyFromEnv(hasher auth.PasswordHasher) (*auth.PasswordPolicy, error) {
+	maxLength := maxPasswordLength
+	if _, ok := hasher.(auth.BcryptHasher); ok {
+		maxLength = auth.BcryptMaxLength
+	}
+	minLength := intEnv("PASSWORD_MIN_LENGTH", defaultMinPasswordLength)
+	if minLength > maxLength {
+		return nil, fmt.Errorf("PASSWORD_MIN_LENGTH is more than the most allowed, %d", maxLength)
+	}
+	path := os.Getenv("BREACHED_PASSWORDS_FILE")
+	if path == "" {
+		return auth.NewPasswordPolicy(minLength, maxLength, nil)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return auth.NewPasswordPolicy(minLength, maxLength, f)
+}
+
+// keySetFromEnv loads the keys tokens are signed and verified with.
+// JWT_SIGNING_KEY is a PEM file with the Ed25519 or RSA private key to sign
+// with. JWT_SECRET is an HS256 secret, which signs if there is no signing
+// key and otherwise only verifies, so tokens signed with it stay valid
+// while moving to a key pair. JWT_VERIFY_KEYS lists, separated by commas,
+// PEM files with keys that only verify, such as the key rotated out.
+func keySetFromEnv() (*auth.KeySet, error) {
+	var keys []auth.Key
+	if path := os.Getenv("JWT_SIGNING_KEY"); path != "" {
+		key, err := readPEMKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		key, err := auth.HMACKey([]byte(secret))
+		if err != nil {
+			return nil, fmt.Errorf("JWT_SECRET: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("neither JWT_SIGNING_KEY nor JWT_SECRET is set")
+	}
+	for _, path := range strings.Split(os.Getenv("JWT_VERIFY_KEYS"), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		key, err := readPEMKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return auth.NewKeySet(keys[0], keys[1:]...)
+}
+
+func readPEMKey(path string) (auth.Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return auth.Key{}, err
+	}
+	key, err := auth.ParsePEMKey(data)
+	if err != nil {
+		return auth.Key{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// oidcProviderFromEnv returns the identity provider users can log in with:
+// OIDC_ISSUER is its issuer URL and OIDC_CLIENT_ID and OIDC_CLIENT_SECRET
+// the credentials it registered the app with. The secret may be left out for
+// a public client. Without an issuer, there is none.
+func oidcProviderFromEnv(publicURL string) *oidc.Provider {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil
+	}
+	clientID := os.Getenv("OIDC_CLIENT_ID")
+	if clientID == "" {
+		panic("OIDC_CLIENT_ID environment variable is not set")
+	}
+	return oidc.NewProvider(oidc.Config{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  publicURL + "/auth/oidc/callback",
+	}, nil)
+}
+
+// mailerFromEnv returns the mailer emails are sent with. SMTP_ADDR is the
+// host and port of an SMTP server, with SMTP_USERNAME and SMTP_PASSWORD if
+// it needs them. Without a server, emails are appended to MAIL_FILE, or
+// written to the log if that isn't set either. MAIL_FROM is the sender.
+func mailerFromEnv() (mail.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Quiz Maker <noreply@localhost>"
+	}
+	if _, err := netmail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("MAIL_FROM: %w", err)
+	}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		return mail.SMTPMailer{
+			Addr:     addr,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	}
+	if path := os.Getenv("MAIL_FILE"); path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		return mail.NewLogMailer(from, f), nil
+	}
+	return mail.NewLogMailer(from, log.Writer()), nil
+}
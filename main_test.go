@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/auth"
+	"github.com/Corogura/quizmaker/internal/database"
+	"github.com/Corogura/quizmaker/internal/feed"
+	"github.com/Corogura/quizmaker/internal/live"
+	"github.com/Corogura/quizmaker/internal/mail"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	_ "modernc.org/sqlite"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestConfig returns a config backed by a new SQLite database with every
+// migration applied. Passwords are hashed at the lowest bcrypt cost so that
+// tests stay quick.
+func newTestConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrate(t, db)
+
+	key, err := auth.HMACKey([]byte(strings.Repeat("s", auth.MinSecretLength)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtKeys, err := auth.NewKeySet(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passwords := auth.BcryptHasher{Cost: bcrypt.MinCost}
+	policy, err := auth.NewPasswordPolicy(defaultMinPasswordLength, auth.BcryptMaxLength, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dummyPasswordHash, err := passwords.Hash("quizmaker-dummy-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := database.New(db)
+	cfg := &apiConfig{
+		db:                queries,
+		dbConn:            db,
+		jwtKeys:           jwtKeys,
+		mailer:            mail.NewLogMailer("Quiz Maker <noreply@localhost>", io.Discard),
+		publicURL:         "http://localhost:8080",
+		revocations:       auth.NewRevocations(revocationStore{db: queries}, revocationsMaxAge),
+		accessTokenTTL:    defaultAccessTokenTTL,
+		refreshTokenTTL:   defaultRefreshTokenTTL,
+		adminEmails:       map[string]bool{},
+		passwords:         passwords,
+		passwordPolicy:    policy,
+		dummyPasswordHash: dummyPasswordHash,
+		live:              live.NewHub(),
+		feed:              feed.NewBroker(),
+	}
+	t.Cleanup(cfg.live.Close)
+	t.Cleanup(cfg.feed.Close)
+	return cfg
+}
+
+// migrate applies the up half of each goose migration in sql/schema.
+func migrate(t *testing.T, db *sql.DB) {
+	t.Helper()
+	paths, err := filepath.Glob("sql/schema/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, _, _ := strings.Cut(string(data), "-- +goose Down")
+		if _, err := db.Exec(up); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	}
+}
+
+// createTestUser creates a user with the email and password.
+func createTestUser(t *testing.T, cfg *apiConfig, email, password string) database.User {
+	t.Helper()
+	hashedPassword, err := cfg.passwords.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	user := database.CreateUserParams{
+		ID:        uuid.NewString(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Email:     email,
+		HashedPw:  hashedPassword,
+	}
+	if err := cfg.db.CreateUser(t.Context(), user); err != nil {
+		t.Fatal(err)
+	}
+	created, err := cfg.db.GetUser(t.Context(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+// doRequest sends a request to the handler with an optional bearer token
+// and JSON body, and decodes the JSON response into a map.
+func doRequest(t *testing.T, h http.Handler, method, target, token string, body any) (int, map[string]any) {
+	t.Helper()
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, target, reqBody)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var response map[string]any
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s %s: invalid JSON response %q", method, target, w.Body.String())
+		}
+	}
+	return w.Code, response
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Corogura/quizmaker/internal/database"
+)
+
+// revocationStore keeps the revoked access tokens in the database.
+type revocationStore struct {
+	db *database.Queries
+}
+
+func (s revocationStore) RevokeToken(ctx context.Context, id string, expiresAt time.Time) error {
+	now := time.Now().UTC()
+	// Expired revocations are cleaned up as new ones arrive.
+	if err := s.db.DeleteExpiredRevokedTokens(ctx, now.Format(time.RFC3339)); err != nil {
+		return err
+	}
+	return s.db.RevokeToken(ctx, database.RevokeTokenParams{
+		ID:        id,
+		RevokedAt: now.Format(time.RFC3339),
+		ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func (s revocationStore) RevokedTokens(ctx context.Context, now time.Time) (map[string]time.Time, error) {
+	rows, err := s.db.GetRevokedTokens(ctx, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	revoked := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		expiresAt, err := time.Parse(time.RFC3339, row.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		revoked[row.ID] = expiresAt
+	}
+	return revoked, nil
+}
+
+// revokeSessionAccessTokens revokes the access tokens issued to a session
+// that have not expired yet, so that logging it out takes effect at once.
+func (cfg *apiConfig) revokeSessionAccessTokens(ctx context.Context, familyID string, now time.Time) error {
+	rows, err := cfg.db.GetSessionAccessTokens(ctx, database.GetSessionAccessTokensParams{
+		FamilyID:        familyID,
+		AccessExpiresAt: now.Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := cfg.revokeAccessToken(ctx, row.AccessTokenID, row.AccessExpiresAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeOtherAccessTokens revokes the unexpired access tokens of every
+// session of a user except keep, which may be empty to revoke them all.
+func (cfg *apiConfig) revokeOtherAccessTokens(ctx context.Context, userID, keep string, now time.Time) error {
+	rows, err := cfg.db.GetOtherSessionAccessTokens(ctx, database.GetOtherSessionAccessTokensParams{
+		UserID:          userID,
+		FamilyID:        keep,
+		AccessExpiresAt: now.Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := cfg.revokeAccessToken(ctx, row.AccessTokenID, row.AccessExpiresAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) revokeAccessToken(ctx context.Context, id, expiresAt string) error {
+	t, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return err
+	}
+	return cfg.revocations.Revoke(ctx, id, t)
+}
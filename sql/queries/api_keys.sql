@@ -0,0 +1,32 @@
+-- name: CountUserAPIKeys :one
+SELECT COUNT(*) FROM api_keys WHERE user_id = ?;
+
+-- name: CreateAPIKey :exec
+INSERT INTO api_keys (id, user_id, name, hashed_key, scopes, created_at, expires_at)
+VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?
+);
+
+-- name: DeleteAPIKey :execrows
+DELETE FROM api_keys WHERE id = ? AND user_id = ?;
+
+-- name: DeleteUserAPIKeys :execrows
+DELETE FROM api_keys WHERE user_id = ?;
+
+-- name: GetAPIKey :one
+SELECT * FROM api_keys WHERE id = ?;
+
+-- name: GetUserAPIKeys :many
+SELECT * FROM api_keys WHERE user_id = ? ORDER BY created_at DESC;
+
+-- name: UseAPIKey :exec
+-- Records that a key was used, at most once a minute so that scripts
+-- don't cause a write on every request.
+UPDATE api_keys SET last_used_at = ?
+WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?);
@@ -0,0 +1,119 @@
+-- name: CreateAttempt :execrows
+-- Starts an attempt, unless its user has used up the quiz's attempts or
+-- started one within its cooldown. No rows are affected if so. Checking in
+-- the same statement keeps attempts started at once from all getting in.
+-- Attempts without a user aren't limited.
+INSERT INTO attempts (id, created_at, updated_at, quiz_id, user_id, seed, question_order, shuffle_choices, sections, deadline, taker_id)
+SELECT
+    sqlc.arg(id),
+    sqlc.arg(created_at),
+    sqlc.arg(updated_at),
+    quizzes.id,
+    sqlc.narg(user_id),
+    sqlc.arg(seed),
+    sqlc.arg(question_order),
+    sqlc.arg(shuffle_choices),
+    sqlc.arg(sections),
+    sqlc.narg(deadline),
+    sqlc.narg(taker_id)
+FROM quizzes
+WHERE quizzes.id = sqlc.arg(quiz_id)
+    AND (
+        sqlc.narg(user_id) IS NULL
+        OR (
+            (quizzes.max_attempts IS NULL
+                OR (SELECT COUNT(*) FROM attempts
+                    WHERE attempts.quiz_id = quizzes.id AND attempts.user_id = sqlc.narg(user_id)) < quizzes.max_attempts)
+            AND (quizzes.attempt_cooldown_seconds IS NULL
+                OR NOT EXISTS (SELECT 1 FROM attempts
+                    WHERE attempts.quiz_id = quizzes.id AND attempts.user_id = sqlc.narg(user_id)
+                        AND CAST(strftime('%s', attempts.created_at) AS INTEGER) + quizzes.attempt_cooldown_seconds
+                            > CAST(strftime('%s', sqlc.arg(created_at)) AS INTEGER)))
+        )
+    );
+
+-- name: MoveExpiredGuestAttempts :exec
+-- Moves the attempts that guests who expired before the given time made at
+-- other users' quizzes to the nickname takers made for them.
+UPDATE attempts SET taker_id = user_id, user_id = NULL
+WHERE user_id IN (
+    SELECT id FROM users WHERE guest_expires_at IS NOT NULL AND guest_expires_at < ?
+) AND quiz_id NOT IN (SELECT id FROM quizzes WHERE quizzes.user_id = attempts.user_id);
+
+-- name: GetAttempt :one
+SELECT * FROM attempts WHERE id = ?;
+
+-- name: GetOpenAttempt :one
+-- The latest unfinished attempt at a quiz by a user or nickname taker.
+SELECT * FROM attempts
+WHERE quiz_id = ? AND finished_at IS NULL AND (user_id = ? OR taker_id = ?)
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: GetExpiredAttempts :many
+-- Unfinished attempts whose deadline is earlier than the one given.
+SELECT * FROM attempts
+WHERE finished_at IS NULL AND deadline < ?
+ORDER BY deadline;
+
+-- name: FinishAttempt :execrows
+-- No rows are affected if the attempt was already finished.
+UPDATE attempts SET finished_at = ?, updated_at = ?, score = ? WHERE id = ? AND finished_at IS NULL;
+
+-- name: CreateAttemptAnswer :execrows
+-- Questions are answered once; a second answer to the same question changes
+-- no rows.
+INSERT INTO attempt_answers (attempt_id, question_id, created_at, answer, answer_text, correct)
+VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?
+)
+ON CONFLICT (attempt_id, question_id) DO NOTHING;
+
+-- name: GetAttemptAnswers :many
+SELECT * FROM attempt_answers WHERE attempt_id = ?;
+
+-- name: CreateAttemptQuestionTimer :exec
+INSERT INTO attempt_question_timers (attempt_id, question_id, opened_at, deadline)
+VALUES (
+    ?,
+    ?,
+    ?,
+    ?
+)
+ON CONFLICT (attempt_id, question_id) DO NOTHING;
+
+-- name: GetAttemptQuestionTimers :many
+SELECT * FROM attempt_question_timers WHERE attempt_id = ?;
+
+-- name: GetUserAttemptStats :one
+SELECT COUNT(*) AS attempts, CAST(COALESCE(MAX(created_at), '') AS TEXT) AS last_started_at
+FROM attempts WHERE quiz_id = ? AND user_id = ?;
+
+-- name: GetQuizLeaderboard :many
+-- Each taker's best finished attempt, ranked by score and then by how quickly
+-- it was completed. A taker is either a user or a nickname taker; name is
+-- the user's display name or the taker's nickname, and empty for users
+-- without a display name.
+SELECT best.user_id, best.taker_id, CAST(COALESCE(users.display_name, takers.nickname, '') AS TEXT) AS name,
+    best.score, best.total, best.seconds_taken, best.finished_at
+FROM (
+    SELECT user_id, taker_id, score, finished_at,
+        CAST(json_array_length(question_order) AS INTEGER) AS total,
+        CAST(strftime('%s', finished_at) - strftime('%s', created_at) AS INTEGER) AS seconds_taken,
+        ROW_NUMBER() OVER (
+            PARTITION BY COALESCE(user_id, taker_id)
+            ORDER BY score DESC, strftime('%s', finished_at) - strftime('%s', created_at), finished_at
+        ) AS taker_rank
+    FROM attempts
+    WHERE quiz_id = ? AND COALESCE(user_id, taker_id) IS NOT NULL AND finished_at IS NOT NULL
+) AS best
+LEFT JOIN users ON users.id = best.user_id
+LEFT JOIN takers ON takers.id = best.taker_id
+WHERE best.taker_rank = 1
+ORDER BY best.score DESC, best.seconds_taken, best.finished_at
+LIMIT ?;
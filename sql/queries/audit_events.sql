@@ -0,0 +1,14 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (id, created_at, event, user_id, email, ip, detail)
+VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?
+);
+
+-- name: GetAuditEvents :many
+SELECT * FROM audit_events ORDER BY created_at DESC LIMIT ?;
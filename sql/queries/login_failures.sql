@@ -0,0 +1,38 @@
+-- name: DeleteExpiredLoginFailures :exec
+DELETE FROM login_failures WHERE last_failed_at < ?;
+
+-- name: DeleteLoginFailures :execrows
+DELETE FROM login_failures WHERE key = ?;
+
+-- name: GetLoginFailures :one
+SELECT * FROM login_failures WHERE key = ?;
+
+-- name: LockLogin :exec
+UPDATE login_failures SET locked_until = ? WHERE key = ?;
+
+-- name: RefundLoginAttempt :exec
+-- Takes back a login attempt that succeeded, and the lockout counting it
+-- started, unless a later attempt has started another one.
+UPDATE login_failures
+SET failures = MAX(failures - 1, 0),
+    locked_until = CASE
+        WHEN locked_until = sqlc.arg(locked_until) THEN NULL
+        ELSE locked_until
+    END
+WHERE key = sqlc.arg(key);
+
+-- name: StartLoginAttempt :one
+-- Counts a login attempt before its password is checked, so that attempts
+-- made at the same time can't all get past a lockout. Nothing is counted,
+-- and no row returned, while the key is locked out. The count starts again
+-- from one if the last attempt was before forget_before.
+INSERT INTO login_failures (key, failures, last_failed_at)
+VALUES (sqlc.arg(key), 1, sqlc.arg(now))
+ON CONFLICT (key) DO UPDATE SET
+    failures = CASE
+        WHEN login_failures.last_failed_at < sqlc.arg(forget_before) THEN 1
+        ELSE login_failures.failures + 1
+    END,
+    last_failed_at = excluded.last_failed_at
+WHERE login_failures.locked_until IS NULL OR login_failures.locked_until <= sqlc.arg(now)
+RETURNING failures;
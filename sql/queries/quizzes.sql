@@ -10,8 +10,19 @@ VALUES (
 );
 
 -- name: CreateQuizQuestions :exec
-INSERT INTO quiz_questions (id, quiz_id, question_number, question_text, choice1, choice2, choice3, choice4, answer)
+INSERT INTO quiz_questions (id, quiz_id, question_number, question_text, choice1, choice2, choice3, choice4, answer, explanation, question_type, accepted_answers, numeric_answer, numeric_tolerance, choice1_feedback, choice2_feedback, choice3_feedback, choice4_feedback, tags, time_limit_seconds)
 VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
     ?,
     ?,
     ?,
@@ -49,4 +60,61 @@ UPDATE quizzes SET title = ?, updated_at = ? WHERE id = ?;
 SELECT * FROM quizzes WHERE user_id = ? AND deleted_at IS NULL ORDER BY updated_at DESC;
 
 -- name: GetAllQuestionsInQuiz :many
-SELECT * FROM quiz_questions WHERE quiz_id = ? AND deleted_at IS NULL ORDER BY question_number ASC;
\ No newline at end of file
+SELECT * FROM quiz_questions WHERE quiz_id = ? AND deleted_at IS NULL ORDER BY question_number ASC;
+
+-- name: UpdateQuizQuestion :exec
+UPDATE quiz_questions
+SET question_text = ?,
+    choice1 = ?,
+    choice2 = ?,
+    choice3 = ?,
+    choice4 = ?,
+    answer = ?,
+    explanation = ?,
+    question_type = ?,
+    accepted_answers = ?,
+    numeric_answer = ?,
+    numeric_tolerance = ?,
+    choice1_feedback = ?,
+    choice2_feedback = ?,
+    choice3_feedback = ?,
+    choice4_feedback = ?,
+    tags = ?,
+    time_limit_seconds = ?
+WHERE id = ?;
+-- name: GetQuizSettings :one
+SELECT shuffle_questions, shuffle_choices, time_limit_seconds, opens_at, closes_at, max_attempts, attempt_cooldown_seconds,
+    leaderboard_enabled, leaderboard_show_names, leaderboard_size
+FROM quizzes WHERE id = ?;
+
+-- name: UpdateQuizSettings :exec
+UPDATE quizzes
+SET shuffle_questions = ?,
+    shuffle_choices = ?,
+    time_limit_seconds = ?,
+    opens_at = ?,
+    closes_at = ?,
+    max_attempts = ?,
+    attempt_cooldown_seconds = ?,
+    leaderboard_enabled = ?,
+    leaderboard_show_names = ?,
+    leaderboard_size = ?,
+    updated_at = ?
+WHERE id = ?;
+
+-- name: GetQuizSections :many
+SELECT * FROM quiz_sections WHERE quiz_id = ? ORDER BY position ASC;
+
+-- name: DeleteQuizSections :exec
+DELETE FROM quiz_sections WHERE quiz_id = ?;
+
+-- name: CreateQuizSection :exec
+INSERT INTO quiz_sections (id, quiz_id, position, title, tag, draw_count)
+VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?
+);
@@ -0,0 +1,18 @@
+-- name: DeleteExpiredRateLimits :exec
+DELETE FROM rate_limits WHERE window_started_at < ?;
+
+-- name: CountRateLimited :one
+-- Counts a request, starting a new window from one if the last one started
+-- before window_start.
+INSERT INTO rate_limits (key, count, window_started_at)
+VALUES (sqlc.arg(key), 1, sqlc.arg(now))
+ON CONFLICT (key) DO UPDATE SET
+    count = CASE
+        WHEN rate_limits.window_started_at < sqlc.arg(window_start) THEN 1
+        ELSE rate_limits.count + 1
+    END,
+    window_started_at = CASE
+        WHEN rate_limits.window_started_at < sqlc.arg(window_start) THEN excluded.window_started_at
+        ELSE rate_limits.window_started_at
+    END
+RETURNING count;
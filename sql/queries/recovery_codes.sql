@@ -0,0 +1,18 @@
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (id, user_id, hashed_code, created_at)
+VALUES (
+    ?,
+    ?,
+    ?,
+    ?
+);
+
+-- name: GetUnusedRecoveryCodes :many
+SELECT * FROM recovery_codes WHERE user_id = ? AND used_at IS NULL;
+
+-- name: UseRecoveryCode :execrows
+-- Marks a recovery code as used. No rows are affected if it already was.
+UPDATE recovery_codes SET used_at = ? WHERE id = ? AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes WHERE user_id = ?;
@@ -1,6 +1,12 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, family_id, session_started_at, user_agent, ip, access_token_id, access_expires_at)
 VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
     ?,
     ?,
     ?,
@@ -15,8 +21,12 @@ SELECT
     users.created_at,
     users.updated_at,
     users.email,
+    users.guest_expires_at,
     refresh_tokens.expires_at AS token_expires_at,
-    refresh_tokens.revoked_at AS token_revoked_at
+    refresh_tokens.revoked_at AS token_revoked_at,
+    refresh_tokens.used_at AS token_used_at,
+    refresh_tokens.family_id AS token_family_id,
+    refresh_tokens.session_started_at AS token_session_started_at
 FROM users
 JOIN refresh_tokens
     ON users.id = refresh_tokens.user_id
@@ -25,4 +35,50 @@ WHERE refresh_tokens.token = ?;
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens
 SET updated_at = ?, revoked_at = ?
-WHERE token = ?;
\ No newline at end of file
+WHERE token = ?;
+
+-- name: UseRefreshToken :execrows
+-- Marks a refresh token as exchanged for a new one. No rows are affected if
+-- it was already used or revoked.
+UPDATE refresh_tokens
+SET updated_at = ?, used_at = ?
+WHERE token = ? AND used_at IS NULL AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET updated_at = ?, revoked_at = ?
+WHERE family_id = ? AND revoked_at IS NULL;
+
+-- name: GetUserSessions :many
+-- A session is a family of refresh tokens; its current token is the one not
+-- yet exchanged for another.
+SELECT family_id, session_started_at, created_at AS last_used_at, user_agent, ip
+FROM refresh_tokens
+WHERE user_id = ? AND used_at IS NULL AND revoked_at IS NULL AND expires_at > ?
+ORDER BY created_at DESC;
+
+-- name: RevokeUserSession :execrows
+UPDATE refresh_tokens
+SET updated_at = ?, revoked_at = ?
+WHERE user_id = ? AND family_id = ? AND revoked_at IS NULL;
+
+-- name: RevokeOtherUserSessions :exec
+-- Revokes every session of a user but the one given, which may be empty to
+-- revoke them all.
+UPDATE refresh_tokens
+SET updated_at = ?, revoked_at = ?
+WHERE user_id = ? AND family_id != ? AND revoked_at IS NULL;
+
+-- name: GetSessionAccessTokens :many
+-- Returns the access tokens issued with the refresh tokens of a session
+-- that have not expired.
+SELECT access_token_id, access_expires_at
+FROM refresh_tokens
+WHERE family_id = ? AND access_token_id != '' AND access_expires_at > ?;
+
+-- name: GetOtherSessionAccessTokens :many
+-- Returns the unexpired access tokens of every session of a user but the
+-- one given, which may be empty to return them all.
+SELECT access_token_id, access_expires_at
+FROM refresh_tokens
+WHERE user_id = ? AND family_id != ? AND access_token_id != '' AND access_expires_at > ?;
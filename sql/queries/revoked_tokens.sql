@@ -0,0 +1,26 @@
+-- name: RevokeToken :exec
+INSERT INTO revoked_tokens (id, revoked_at, expires_at)
+VALUES (
+    ?,
+    ?,
+    ?
+)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: GetRevokedTokens :many
+SELECT id, expires_at FROM revoked_tokens WHERE expires_at > ?;
+
+-- name: DeleteExpiredRevokedTokens :exec
+-- Expired tokens are rejected anyway, so they need not be remembered.
+DELETE FROM revoked_tokens WHERE expires_at <= ?;
+
+-- name: UseToken :execrows
+-- Marks a single-use token as used by revoking it. No rows are affected if
+-- it already was.
+INSERT INTO revoked_tokens (id, revoked_at, expires_at)
+VALUES (
+    ?,
+    ?,
+    ?
+)
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,24 @@
+-- name: CreateTaker :exec
+INSERT INTO takers (id, created_at, expires_at, nickname)
+VALUES (
+    ?,
+    ?,
+    ?,
+    ?
+);
+
+-- name: GetTaker :one
+SELECT * FROM takers WHERE id = ?;
+
+-- name: CreateTakersForExpiredGuests :exec
+-- Makes a nickname taker, with the same ID, of each guest that expired
+-- before the given time and took other users' quizzes, so that those
+-- attempts can be kept once the guest is deleted.
+INSERT INTO takers (id, created_at, expires_at, nickname)
+SELECT users.id, users.created_at, users.guest_expires_at, COALESCE(users.display_name, '')
+FROM users
+WHERE users.guest_expires_at IS NOT NULL AND users.guest_expires_at < ?
+    AND EXISTS (
+        SELECT 1 FROM attempts JOIN quizzes ON quizzes.id = attempts.quiz_id
+        WHERE attempts.user_id = users.id AND quizzes.user_id != users.id
+    );
@@ -0,0 +1,17 @@
+-- name: CreateUserIdentity :exec
+INSERT INTO user_identities (id, user_id, issuer, subject, email, created_at, last_login_at)
+VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?
+);
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities WHERE issuer = ? AND subject = ?;
+
+-- name: UpdateUserIdentityLogin :exec
+UPDATE user_identities SET email = ?, last_login_at = ? WHERE id = ?;
@@ -21,4 +21,86 @@ SELECT * FROM users WHERE email = ?;
 UPDATE users
 SET hashed_pw = ?, updated_at = ?
 WHERE id = ?;
---
\ No newline at end of file
+--
+
+-- name: CreateGuestUser :exec
+INSERT INTO users (id, created_at, updated_at, email, hashed_pw, guest_expires_at)
+VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    '',
+    ?
+);
+--
+
+-- name: UpgradeGuestUser :exec
+UPDATE users
+SET email = ?, hashed_pw = ?, guest_expires_at = NULL, updated_at = ?
+WHERE id = ? AND guest_expires_at IS NOT NULL;
+--
+
+-- name: DeleteExpiredGuestUsers :exec
+DELETE FROM users
+WHERE guest_expires_at IS NOT NULL AND guest_expires_at < ?;
+--
+
+-- name: VerifyUserEmail :execrows
+-- Marks the user's email as verified if it is still the address given.
+UPDATE users
+SET email_verified_at = ?, updated_at = ?
+WHERE id = ? AND email = ?;
+--
+
+-- name: SetUserTotpSecret :execrows
+-- Starts setting up two-factor authentication with a new secret, unless it
+-- is already enabled.
+UPDATE users
+SET totp_secret = ?, totp_last_step = 0, updated_at = ?
+WHERE id = ? AND totp_enabled_at IS NULL;
+--
+
+-- name: EnableUserTotp :execrows
+-- Enables two-factor authentication if the secret is still the one being
+-- set up.
+UPDATE users
+SET totp_enabled_at = ?, totp_last_step = ?, updated_at = ?
+WHERE id = ? AND totp_secret = ? AND totp_enabled_at IS NULL;
+--
+
+-- name: UseUserTotpStep :execrows
+-- Records the time step of a code as used. No rows are affected if a code
+-- of that step or a later one already was.
+UPDATE users
+SET totp_last_step = ?
+WHERE id = ? AND totp_last_step < ?;
+--
+
+-- name: DisableUserTotp :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0, updated_at = ?
+WHERE id = ?;
+--
+
+-- name: RehashUserPassword :exec
+-- Replaces the hash of a password with a new one, unless the password has
+-- changed since the old one was checked.
+UPDATE users
+SET hashed_pw = ?
+WHERE id = ? AND hashed_pw = ?;
+--
+
+-- name: UpdateDisplayName :exec
+UPDATE users
+SET display_name = ?, updated_at = ?
+WHERE id = ?;
+--
+
+-- name: ResetUserPassword :execrows
+-- Sets a new password, unless the password has changed since the reset
+-- was asked for.
+UPDATE users
+SET hashed_pw = ?, updated_at = ?
+WHERE id = ? AND hashed_pw = ?;
+--
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE quiz_questions
+ADD COLUMN explanation TEXT;
+
+-- +goose Down
+ALTER TABLE quiz_questions
+DROP COLUMN explanation;
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE quiz_questions
+ADD COLUMN question_type TEXT NOT NULL DEFAULT 'multiple_choice';
+ALTER TABLE quiz_questions
+ADD COLUMN accepted_answers TEXT;
+ALTER TABLE quiz_questions
+ADD COLUMN numeric_answer REAL;
+ALTER TABLE quiz_questions
+ADD COLUMN numeric_tolerance REAL;
+
+-- +goose Down
+ALTER TABLE quiz_questions
+DROP COLUMN numeric_tolerance;
+ALTER TABLE quiz_questions
+DROP COLUMN numeric_answer;
+ALTER TABLE quiz_questions
+DROP COLUMN accepted_answers;
+ALTER TABLE quiz_questions
+DROP COLUMN question_type;
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE quiz_questions
+ADD COLUMN choice1_feedback TEXT;
+ALTER TABLE quiz_questions
+ADD COLUMN choice2_feedback TEXT;
+ALTER TABLE quiz_questions
+ADD COLUMN choice3_feedback TEXT;
+ALTER TABLE quiz_questions
+ADD COLUMN choice4_feedback TEXT;
+
+-- +goose Down
+ALTER TABLE quiz_questions
+DROP COLUMN choice4_feedback;
+ALTER TABLE quiz_questions
+DROP COLUMN choice3_feedback;
+ALTER TABLE quiz_questions
+DROP COLUMN choice2_feedback;
+ALTER TABLE quiz_questions
+DROP COLUMN choice1_feedback;
@@ -0,0 +1,41 @@
+-- +goose Up
+ALTER TABLE quizzes
+ADD COLUMN shuffle_questions BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE quizzes
+ADD COLUMN shuffle_choices BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE attempts(
+    id TEXT PRIMARY KEY,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    quiz_id TEXT NOT NULL,
+    user_id TEXT,
+    seed INTEGER NOT NULL,
+    -- JSON array of question IDs in the order they are shown.
+    question_order TEXT NOT NULL,
+    shuffle_choices BOOLEAN NOT NULL,
+    finished_at TEXT,
+    score INTEGER,
+    FOREIGN KEY (quiz_id) REFERENCES quizzes(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE attempt_answers(
+    attempt_id TEXT NOT NULL,
+    question_id TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    answer INTEGER NOT NULL,
+    answer_text TEXT NOT NULL,
+    correct BOOLEAN NOT NULL,
+    PRIMARY KEY (attempt_id, question_id),
+    FOREIGN KEY (attempt_id) REFERENCES attempts(id) ON DELETE CASCADE,
+    FOREIGN KEY (question_id) REFERENCES quiz_questions(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE attempt_answers;
+DROP TABLE attempts;
+ALTER TABLE quizzes
+DROP COLUMN shuffle_choices;
+ALTER TABLE quizzes
+DROP COLUMN shuffle_questions;
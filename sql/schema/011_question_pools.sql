@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE quiz_questions
+ADD COLUMN tags TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE quiz_sections(
+    id TEXT PRIMARY KEY,
+    quiz_id TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    tag TEXT NOT NULL,
+    draw_count INTEGER NOT NULL,
+    FOREIGN KEY (quiz_id) REFERENCES quizzes(id) ON DELETE CASCADE
+);
+
+-- JSON array of the sections used by the attempt and how many questions
+-- each drew, in question_order order.
+ALTER TABLE attempts
+ADD COLUMN sections TEXT NOT NULL DEFAULT '[]';
+
+-- +goose Down
+ALTER TABLE attempts
+DROP COLUMN sections;
+DROP TABLE quiz_sections;
+ALTER TABLE quiz_questions
+DROP COLUMN tags;
@@ -0,0 +1,26 @@
+-- +goose Up
+ALTER TABLE quizzes
+ADD COLUMN time_limit_seconds INTEGER;
+ALTER TABLE quiz_questions
+ADD COLUMN time_limit_seconds INTEGER;
+ALTER TABLE attempts
+ADD COLUMN deadline TEXT;
+
+CREATE TABLE attempt_question_timers(
+    attempt_id TEXT NOT NULL,
+    question_id TEXT NOT NULL,
+    opened_at TEXT NOT NULL,
+    deadline TEXT NOT NULL,
+    PRIMARY KEY (attempt_id, question_id),
+    FOREIGN KEY (attempt_id) REFERENCES attempts(id) ON DELETE CASCADE,
+    FOREIGN KEY (question_id) REFERENCES quiz_questions(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE attempt_question_timers;
+ALTER TABLE attempts
+DROP COLUMN deadline;
+ALTER TABLE quiz_questions
+DROP COLUMN time_limit_seconds;
+ALTER TABLE quizzes
+DROP COLUMN time_limit_seconds;
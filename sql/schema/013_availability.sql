@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE quizzes
+ADD COLUMN opens_at TEXT;
+ALTER TABLE quizzes
+ADD COLUMN closes_at TEXT;
+ALTER TABLE quizzes
+ADD COLUMN max_attempts INTEGER;
+ALTER TABLE quizzes
+ADD COLUMN attempt_cooldown_seconds INTEGER;
+
+-- +goose Down
+ALTER TABLE quizzes
+DROP COLUMN attempt_cooldown_seconds;
+ALTER TABLE quizzes
+DROP COLUMN max_attempts;
+ALTER TABLE quizzes
+DROP COLUMN closes_at;
+ALTER TABLE quizzes
+DROP COLUMN opens_at;
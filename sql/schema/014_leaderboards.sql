@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE quizzes
+ADD COLUMN leaderboard_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE quizzes
+ADD COLUMN leaderboard_show_names BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE quizzes
+ADD COLUMN leaderboard_size INTEGER NOT NULL DEFAULT 10;
+
+-- Serves both the leaderboard and the per-user attempt limits.
+CREATE INDEX attempts_quiz_user ON attempts(quiz_id, user_id);
+
+-- +goose Down
+DROP INDEX attempts_quiz_user;
+ALTER TABLE quizzes
+DROP COLUMN leaderboard_size;
+ALTER TABLE quizzes
+DROP COLUMN leaderboard_show_names;
+ALTER TABLE quizzes
+DROP COLUMN leaderboard_enabled;
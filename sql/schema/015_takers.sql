@@ -0,0 +1,49 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+CREATE TABLE takers(
+    id TEXT PRIMARY KEY,
+    created_at TEXT NOT NULL,
+    expires_at TEXT NOT NULL,
+    nickname TEXT NOT NULL
+);
+
+ALTER TABLE attempts
+ADD COLUMN taker_id TEXT REFERENCES takers(id) ON DELETE SET NULL;
+
+-- +goose Down
+-- SQLite can't drop a column with a foreign key, so attempts is rebuilt
+-- without it. Foreign keys are off meanwhile, or dropping the old table
+-- would delete the answers and timers of its attempts. That can't be done
+-- inside a transaction, hence NO TRANSACTION above. Attempts by takers are
+-- deleted rather than kept without an owner, which would let anyone with
+-- their IDs open them.
+PRAGMA foreign_keys = OFF;
+DELETE FROM attempt_answers
+WHERE attempt_id IN (SELECT id FROM attempts WHERE taker_id IS NOT NULL);
+DELETE FROM attempt_question_timers
+WHERE attempt_id IN (SELECT id FROM attempts WHERE taker_id IS NOT NULL);
+CREATE TABLE attempts_without_takers(
+    id TEXT PRIMARY KEY,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    quiz_id TEXT NOT NULL,
+    user_id TEXT,
+    seed INTEGER NOT NULL,
+    question_order TEXT NOT NULL,
+    shuffle_choices BOOLEAN NOT NULL,
+    finished_at TEXT,
+    score INTEGER,
+    sections TEXT NOT NULL DEFAULT '[]',
+    deadline TEXT,
+    FOREIGN KEY (quiz_id) REFERENCES quizzes(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+INSERT INTO attempts_without_takers (id, created_at, updated_at, quiz_id, user_id, seed, question_order, shuffle_choices, finished_at, score, sections, deadline)
+SELECT id, created_at, updated_at, quiz_id, user_id, seed, question_order, shuffle_choices, finished_at, score, sections, deadline
+FROM attempts
+WHERE taker_id IS NULL;
+DROP TABLE attempts;
+ALTER TABLE attempts_without_takers RENAME TO attempts;
+CREATE INDEX attempts_quiz_user ON attempts(quiz_id, user_id);
+PRAGMA foreign_keys = ON;
+DROP TABLE takers;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN guest_expires_at TEXT;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN guest_expires_at;
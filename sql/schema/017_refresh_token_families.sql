@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE refresh_tokens
+ADD COLUMN family_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens
+ADD COLUMN used_at TEXT;
+
+-- Tokens issued before rotation each start a family of their own.
+UPDATE refresh_tokens SET family_id = token;
+
+CREATE INDEX refresh_tokens_family ON refresh_tokens(family_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_family;
+ALTER TABLE refresh_tokens
+DROP COLUMN used_at;
+ALTER TABLE refresh_tokens
+DROP COLUMN family_id;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE refresh_tokens
+ADD COLUMN session_started_at TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens
+ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens
+ADD COLUMN ip TEXT NOT NULL DEFAULT '';
+
+UPDATE refresh_tokens SET session_started_at = created_at;
+
+CREATE INDEX refresh_tokens_user ON refresh_tokens(user_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_user;
+ALTER TABLE refresh_tokens
+DROP COLUMN ip;
+ALTER TABLE refresh_tokens
+DROP COLUMN user_agent;
+ALTER TABLE refresh_tokens
+DROP COLUMN session_started_at;
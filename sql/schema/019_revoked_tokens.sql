@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE revoked_tokens (
+    id TEXT PRIMARY KEY,
+    revoked_at TEXT NOT NULL,
+    expires_at TEXT NOT NULL
+);
+
+CREATE INDEX revoked_tokens_expires ON revoked_tokens(expires_at);
+
+ALTER TABLE refresh_tokens
+ADD COLUMN access_token_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens
+ADD COLUMN access_expires_at TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+DROP COLUMN access_expires_at;
+ALTER TABLE refresh_tokens
+DROP COLUMN access_token_id;
+DROP INDEX revoked_tokens_expires;
+DROP TABLE revoked_tokens;
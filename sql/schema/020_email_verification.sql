@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN email_verified_at TEXT;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN email_verified_at;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- totp_secret is set when two-factor authentication is being set up, and
+-- totp_enabled_at once the user has confirmed it with a code.
+-- totp_last_step is the time step of the last code used, so no code works
+-- twice.
+ALTER TABLE users
+ADD COLUMN totp_secret TEXT;
+ALTER TABLE users
+ADD COLUMN totp_enabled_at TEXT;
+ALTER TABLE users
+ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE recovery_codes (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    hashed_code TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    used_at TEXT,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX recovery_codes_user ON recovery_codes(user_id);
+
+-- +goose Down
+DROP INDEX recovery_codes_user;
+DROP TABLE recovery_codes;
+ALTER TABLE users
+DROP COLUMN totp_last_step;
+ALTER TABLE users
+DROP COLUMN totp_enabled_at;
+ALTER TABLE users
+DROP COLUMN totp_secret;
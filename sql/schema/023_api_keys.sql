@@ -0,0 +1,21 @@
+-- +goose Up
+-- api_keys are keys users create for scripts. A key is looked up by the
+-- short ID at its start, and its secret is stored hashed. scopes is a
+-- space-separated list of what the key may do.
+CREATE TABLE api_keys (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    hashed_key TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    expires_at TEXT,
+    last_used_at TEXT,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX api_keys_user ON api_keys(user_id);
+
+-- +goose Down
+DROP INDEX api_keys_user;
+DROP TABLE api_keys;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- login_failures counts the failed logins in a row for each email address
+-- and IP address, by key, to slow down password guessing. Addresses are
+-- counted whether or not they belong to a user, so that being locked out
+-- doesn't tell who has an account.
+CREATE TABLE login_failures (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failed_at TEXT NOT NULL,
+    locked_until TEXT
+);
+
+CREATE INDEX login_failures_last_failed_at ON login_failures(last_failed_at);
+
+-- audit_events records security events for admins to review. They outlive
+-- the users they are about.
+CREATE TABLE audit_events (
+    id TEXT PRIMARY KEY,
+    created_at TEXT NOT NULL,
+    event TEXT NOT NULL,
+    user_id TEXT,
+    email TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    detail TEXT NOT NULL
+);
+
+CREATE INDEX audit_events_created_at ON audit_events(created_at);
+
+-- +goose Down
+DROP INDEX audit_events_created_at;
+DROP TABLE audit_events;
+DROP INDEX login_failures_last_failed_at;
+DROP TABLE login_failures;
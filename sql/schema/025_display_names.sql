@@ -0,0 +1,9 @@
+-- +goose Up
+-- display_name is what a user is called on leaderboards that show names.
+-- Their email address is never shown there.
+ALTER TABLE users
+ADD COLUMN display_name TEXT;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN display_name;
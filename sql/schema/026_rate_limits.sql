@@ -0,0 +1,14 @@
+-- +goose Up
+-- rate_limits counts requests by key, such as guest accounts created from
+-- one IP address, in a window that starts with the first request.
+CREATE TABLE rate_limits (
+    key TEXT PRIMARY KEY,
+    count INTEGER NOT NULL,
+    window_started_at TEXT NOT NULL
+);
+
+CREATE INDEX rate_limits_window_started_at ON rate_limits(window_started_at);
+
+-- +goose Down
+DROP INDEX rate_limits_window_started_at;
+DROP TABLE rate_limits;